@@ -71,6 +71,27 @@ type requestLog struct {
 	duration  time.Duration
 }
 
+// assertPhases asserts that the first request of the single worker opened new connection with the expected phases measured
+// and the second request reused the connection.
+func assertPhases(t *testing.T, rs []*dnsbench.ResultStats, wantNewConnPhases ...dnsbench.Phase) {
+	t.Helper()
+	require.Len(t, rs, 1)
+	require.NotNil(t, rs[0].PhaseHists)
+	hists := rs[0].PhaseHists
+
+	assert.Len(t, hists.NewConn, len(wantNewConnPhases), "new connection phases: %v", hists.NewConn)
+	for _, p := range wantNewConnPhases {
+		if assert.Contains(t, hists.NewConn, p, "new connection phase %s", p) {
+			assert.EqualValues(t, 1, hists.NewConn[p].TotalCount())
+			assert.Positive(t, hists.NewConn[p].Max())
+		}
+	}
+	assert.Len(t, hists.ReusedConn, 1, "reused connection phases: %v", hists.ReusedConn)
+	if assert.Contains(t, hists.ReusedConn, dnsbench.PhaseFirstByte) {
+		assert.EqualValues(t, 1, hists.ReusedConn[dnsbench.PhaseFirstByte].TotalCount())
+	}
+}
+
 func assertRequestLogStructure(t *testing.T, reader io.Reader) {
 	t.Helper()
 	pattern := `.*worker:\[(.*)\] reqid:\[(.*)\] qname:\[(.*)\] qtype:\[(.*)\] respid:\[(.*)\] rcode:\[(.*)\] respflags:\[(.*)\] err:\[(.*)\] duration:\[(.*)\]$`
@@ -136,3 +157,6 @@ func assertRequestLogStructure(t *testing.T, reader io.Reader) {
 
 // A returns an A record from rr. It panics on errors.
 func A(rr string) *dns.A { r, _ := dns.NewRR(rr); return r.(*dns.A) }
+
+// AAAA returns an AAAA record from rr. It panics on errors.
+func AAAA(rr string) *dns.AAAA { r, _ := dns.NewRR(rr); return r.(*dns.AAAA) }
@@ -8,6 +8,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -16,6 +17,7 @@ import (
 	"time"
 
 	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/stretchr/testify/suite"
 	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
 )
@@ -146,6 +148,63 @@ func (suite *DoHTestSuite) TestBenchmark_Run_get() {
 	suite.Equal(fmt.Sprintf("Using 1 hostnames\nBenchmarking %s/dns-query via http/1.1 (GET) with 2 concurrent requests \n", ts.URL), buf.String())
 }
 
+func (suite *DoHTestSuite) TestBenchmark_Run_json() {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("Accept") != "application/dns-json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		query := r.URL.Query()
+		w.Header().Set("Content-Type", "application/dns-json")
+		switch query.Get("name") {
+		case "example.org.":
+			if query.Get("type") != "A" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"Status":0,"RD":true,"RA":true,"Question":[{"name":"example.org","type":1}],`+
+				`"Answer":[{"name":"example.org","type":1,"TTL":300,"data":"127.0.0.1"}]}`)
+		case "nxdomain.org.":
+			fmt.Fprint(w, `{"Status":3,"RD":true,"RA":true,"Question":[{"name":"nxdomain.org.","type":1}],`+
+				`"Authority":[{"name":"org.","type":6,"TTL":300,"data":"a0.org.afilias-nst.info. hostmaster.donuts.email. 1 7200 900 1209600 3600"}]}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	buf := bytes.Buffer{}
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org", "nxdomain.org", "error.org"},
+		Types:          []string{"A"},
+		Server:         ts.URL,
+		Concurrency:    1,
+		Count:          1,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+		DohMethod:      dnsbench.JSONHTTPMethod,
+		Writer:         &buf,
+	}
+
+	rs, err := bench.Run(context.Background())
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+	suite.EqualValues(3, rs[0].Counters.Total)
+	suite.EqualValues(1, rs[0].Counters.Success)
+	suite.EqualValues(1, rs[0].Counters.Negative)
+	suite.EqualValues(1, rs[0].Counters.IOError)
+	suite.EqualValues(0, rs[0].Counters.IDmismatch)
+	suite.Equal(map[int]int64{dns.RcodeSuccess: 1, dns.RcodeNameError: 1}, rs[0].Codes)
+	suite.Equal(map[int]int64{http.StatusOK: 2, http.StatusInternalServerError: 1}, rs[0].DoHStatusCodes)
+	suite.Equal(fmt.Sprintf("Using 3 hostnames\nBenchmarking %s/dns-query via http/1.1 (JSON) with 1 concurrent requests \n", ts.URL), buf.String())
+}
+
 func (suite *DoHTestSuite) TestBenchmark_Run_http1() {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		bd, err := io.ReadAll(r.Body)
@@ -383,6 +442,8 @@ func (suite *DoHTestSuite) TestBenchmark_Run_separate_connections() {
 	tests := []struct {
 		name                    string
 		separateConnections     bool
+		qperConn                int64
+		connPerQuery            bool
 		wantNumberOfConnections int
 	}{
 		{
@@ -395,6 +456,21 @@ func (suite *DoHTestSuite) TestBenchmark_Run_separate_connections() {
 			separateConnections:     false,
 			wantNumberOfConnections: 1,
 		},
+		{
+			name:                    "query per connection",
+			qperConn:                1,
+			wantNumberOfConnections: 10,
+		},
+		{
+			name:                    "connection per query",
+			connPerQuery:            true,
+			wantNumberOfConnections: 10,
+		},
+		{
+			name:                    "two queries per connection",
+			qperConn:                2,
+			wantNumberOfConnections: 5,
+		},
 	}
 	for _, tt := range tests {
 		suite.Run(tt.name, func() {
@@ -470,6 +546,8 @@ func (suite *DoHTestSuite) TestBenchmark_Run_separate_connections() {
 				DohMethod:                 dnsbench.PostHTTPMethod,
 				Writer:                    &buf,
 				SeparateWorkerConnections: tt.separateConnections,
+				QperConn:                  tt.qperConn,
+				ConnPerQuery:              tt.connPerQuery,
 				Insecure:                  true,
 			}
 
@@ -490,3 +568,404 @@ func (suite *DoHTestSuite) TestBenchmark_Run_separate_connections() {
 		})
 	}
 }
+
+func (suite *DoHTestSuite) TestBenchmark_Run_phase_timings() {
+	tests := []struct {
+		name              string
+		protocol          string
+		wantNewConnPhases []dnsbench.Phase
+	}{
+		{
+			name:              "HTTP/1.1",
+			protocol:          dnsbench.HTTP1Proto,
+			wantNewConnPhases: []dnsbench.Phase{dnsbench.PhaseConnect, dnsbench.PhaseTLSHandshake, dnsbench.PhaseFirstByte},
+		},
+		{
+			name:              "HTTP/2",
+			protocol:          dnsbench.HTTP2Proto,
+			wantNewConnPhases: []dnsbench.Phase{dnsbench.PhaseConnect, dnsbench.PhaseTLSHandshake, dnsbench.PhaseFirstByte},
+		},
+		{
+			name:              "HTTP/3",
+			protocol:          dnsbench.HTTP3Proto,
+			wantNewConnPhases: []dnsbench.Phase{dnsbench.PhaseQUICHandshake, dnsbench.PhaseFirstByte},
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			cert, err := tls.LoadX509KeyPair("testdata/test.crt", "testdata/test.key")
+			suite.Require().NoError(err)
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				bd, err := io.ReadAll(r.Body)
+				if err != nil {
+					panic(err)
+				}
+				msg := dns.Msg{}
+				if err := msg.Unpack(bd); err != nil {
+					panic(err)
+				}
+				msg.Response = true
+				msg.Answer = append(msg.Answer, A("example.org. IN A 127.0.0.1"))
+				pack, err := msg.Pack()
+				if err != nil {
+					panic(err)
+				}
+				w.Write(pack)
+			})
+
+			var url string
+			if tt.protocol == dnsbench.HTTP3Proto {
+				conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+				suite.Require().NoError(err)
+				server := http3.Server{Handler: handler, TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}})}
+				go server.Serve(conn)
+				defer server.Close()
+				url = "https://" + conn.LocalAddr().String()
+			} else {
+				ts := httptest.NewUnstartedServer(handler)
+				ts.EnableHTTP2 = tt.protocol == dnsbench.HTTP2Proto
+				ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+				ts.StartTLS()
+				defer ts.Close()
+				url = ts.URL
+			}
+
+			bench := dnsbench.Benchmark{
+				Server:         url,
+				DohProtocol:    tt.protocol,
+				Queries:        []string{"example.org"},
+				Types:          []string{"A"},
+				Concurrency:    1,
+				Count:          2,
+				Probability:    1,
+				WriteTimeout:   1 * time.Second,
+				ReadTimeout:    3 * time.Second,
+				ConnectTimeout: 1 * time.Second,
+				RequestTimeout: 5 * time.Second,
+				Recurse:        true,
+				Insecure:       true,
+				PhaseTimings:   true,
+				Writer:         io.Discard,
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			suite.Require().NoError(err, "expected no error from benchmark run")
+			suite.EqualValues(2, rs[0].Counters.Success)
+			assertPhases(suite.T(), rs, tt.wantNewConnPhases...)
+		})
+	}
+}
+
+func (suite *DoHTestSuite) TestBenchmark_Run_conn_stats() {
+	tests := []struct {
+		name     string
+		protocol string
+	}{
+		{
+			name:     "HTTP/1.1",
+			protocol: dnsbench.HTTP1Proto,
+		},
+		{
+			name:     "HTTP/2",
+			protocol: dnsbench.HTTP2Proto,
+		},
+		{
+			name:     "HTTP/3",
+			protocol: dnsbench.HTTP3Proto,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			cert, err := tls.LoadX509KeyPair("testdata/test.crt", "testdata/test.key")
+			suite.Require().NoError(err)
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				bd, err := io.ReadAll(r.Body)
+				if err != nil {
+					panic(err)
+				}
+				msg := dns.Msg{}
+				if err := msg.Unpack(bd); err != nil {
+					panic(err)
+				}
+				msg.Response = true
+				msg.Answer = append(msg.Answer, A("example.org. IN A 127.0.0.1"))
+				pack, err := msg.Pack()
+				if err != nil {
+					panic(err)
+				}
+				w.Write(pack)
+			})
+
+			var url string
+			if tt.protocol == dnsbench.HTTP3Proto {
+				conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+				suite.Require().NoError(err)
+				server := http3.Server{Handler: handler, TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}})}
+				go server.Serve(conn)
+				defer server.Close()
+				url = "https://" + conn.LocalAddr().String()
+			} else {
+				ts := httptest.NewUnstartedServer(handler)
+				ts.EnableHTTP2 = tt.protocol == dnsbench.HTTP2Proto
+				ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+				ts.StartTLS()
+				defer ts.Close()
+				url = ts.URL
+			}
+
+			bench := dnsbench.Benchmark{
+				Server:          url,
+				DohProtocol:     tt.protocol,
+				Queries:         []string{"example.org"},
+				Types:           []string{"A"},
+				Concurrency:     1,
+				Count:           4,
+				Probability:     1,
+				WriteTimeout:    1 * time.Second,
+				ReadTimeout:     3 * time.Second,
+				ConnectTimeout:  1 * time.Second,
+				RequestTimeout:  5 * time.Second,
+				HistMax:         time.Second,
+				QperConn:        2,
+				Recurse:         true,
+				Insecure:        true,
+				ConnectionStats: true,
+				Writer:          io.Discard,
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			suite.Require().NoError(err, "expected no error from benchmark run")
+			suite.EqualValues(4, rs[0].Counters.Success)
+			conns := rs[0].Conns
+			suite.Require().NotNil(conns)
+			suite.EqualValues(2, conns.Opened, "new connection should be opened after every 2 queries")
+			suite.EqualValues(2, conns.Reused)
+			suite.EqualValues(0, conns.ClosedByServer, "connections closed by the client should not be counted")
+			suite.EqualValues(0, conns.ResetOnError, "connections closed by the client should not be counted")
+			suite.EqualValues(2, conns.FirstQuery.TotalCount())
+			suite.EqualValues(2, conns.ReusedQuery.TotalCount())
+		})
+	}
+}
+
+func (suite *DoHTestSuite) TestBenchmark_Run_doh_analytics() {
+	tests := []struct {
+		name      string
+		protocol  string
+		wantProto string
+	}{
+		{
+			name:      "HTTP/1.1",
+			protocol:  dnsbench.HTTP1Proto,
+			wantProto: "HTTP/1.1",
+		},
+		{
+			name:      "HTTP/2",
+			protocol:  dnsbench.HTTP2Proto,
+			wantProto: "HTTP/2.0",
+		},
+		{
+			name:      "HTTP/3",
+			protocol:  dnsbench.HTTP3Proto,
+			wantProto: "HTTP/3.0",
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			cert, err := tls.LoadX509KeyPair("testdata/test.crt", "testdata/test.key")
+			suite.Require().NoError(err)
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				bd, err := base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+				if err != nil {
+					panic(err)
+				}
+				msg := dns.Msg{}
+				if err := msg.Unpack(bd); err != nil {
+					panic(err)
+				}
+				msg.Response = true
+				msg.Answer = append(msg.Answer, A("example.org. IN A 127.0.0.1"))
+				pack, err := msg.Pack()
+				if err != nil {
+					panic(err)
+				}
+				w.Header().Set("Cache-Control", "public, max-age=300")
+				w.Header().Set("Age", "20")
+				w.Write(pack)
+			})
+
+			var url string
+			if tt.protocol == dnsbench.HTTP3Proto {
+				conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+				suite.Require().NoError(err)
+				server := http3.Server{Handler: handler, TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}})}
+				go server.Serve(conn)
+				defer server.Close()
+				url = "https://" + conn.LocalAddr().String()
+			} else {
+				ts := httptest.NewUnstartedServer(handler)
+				ts.EnableHTTP2 = tt.protocol == dnsbench.HTTP2Proto
+				ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+				ts.StartTLS()
+				defer ts.Close()
+				url = ts.URL
+			}
+
+			bench := dnsbench.Benchmark{
+				Server:         url,
+				DohProtocol:    tt.protocol,
+				DohMethod:      dnsbench.GetHTTPMethod,
+				Queries:        []string{"example.org"},
+				Types:          []string{"A"},
+				Concurrency:    1,
+				Count:          4,
+				Probability:    1,
+				WriteTimeout:   1 * time.Second,
+				ReadTimeout:    3 * time.Second,
+				ConnectTimeout: 1 * time.Second,
+				RequestTimeout: 5 * time.Second,
+				HistMax:        time.Second,
+				QperConn:       2,
+				Recurse:        true,
+				Insecure:       true,
+				DoHAnalytics:   true,
+				Writer:         io.Discard,
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			suite.Require().NoError(err, "expected no error from benchmark run")
+			suite.EqualValues(4, rs[0].Counters.Success)
+			d := rs[0].DoH
+			suite.Require().NotNil(d)
+			suite.Equal(map[string]int64{tt.wantProto: 4}, d.Protocols)
+			suite.EqualValues(0, d.Fallback)
+			suite.EqualValues(4, d.CacheControl)
+			suite.EqualValues(300, d.MaxAge.Max())
+			suite.EqualValues(4, d.Cached)
+			suite.EqualValues(20, d.Age.Max())
+			suite.EqualValues(4, d.HeaderSize.TotalCount())
+			suite.EqualValues(2, d.ReusedConn)
+			suite.EqualValues(2, d.StreamsPerConn.TotalCount(), "new connection should be opened after every 2 queries")
+			suite.EqualValues(2, d.StreamsPerConn.Max())
+		})
+	}
+}
+
+func (suite *DoHTestSuite) TestBenchmark_Run_request_customization() {
+	tests := []struct {
+		name        string
+		server      string
+		method      string
+		queryParams []string
+		wantQuery   string
+	}{
+		{
+			name:        "GET",
+			server:      "/dns-query?tenant=a",
+			method:      dnsbench.GetHTTPMethod,
+			queryParams: []string{"b=c"},
+			wantQuery:   "b=c&tenant=a",
+		},
+		{
+			name:        "POST",
+			server:      "/dns-query?tenant=a",
+			method:      dnsbench.PostHTTPMethod,
+			queryParams: []string{"b=c"},
+			wantQuery:   "b=c&tenant=a",
+		},
+		{
+			name:      "GET with URI template",
+			server:    "/tenant/a{?dns}",
+			method:    dnsbench.GetHTTPMethod,
+			wantQuery: "",
+		},
+		{
+			name:      "POST with URI template",
+			server:    "/tenant/a{?dns}",
+			method:    dnsbench.PostHTTPMethod,
+			wantQuery: "",
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			cert, err := tls.LoadX509KeyPair("testdata/test.crt", "testdata/test.key")
+			suite.Require().NoError(err)
+
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("User-Agent") != "dnspyre-test" ||
+					r.Host != "tenant.example.org" || r.TLS.ServerName != "localhost" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				var bd []byte
+				var err error
+				query := r.URL.Query()
+				if r.Method == http.MethodGet {
+					bd, err = base64.RawURLEncoding.DecodeString(query.Get("dns"))
+					if err != nil {
+						panic(err)
+					}
+					query.Del("dns")
+				} else {
+					bd, err = io.ReadAll(r.Body)
+					if err != nil {
+						panic(err)
+					}
+				}
+				if query.Encode() != tt.wantQuery {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				msg := dns.Msg{}
+				if err := msg.Unpack(bd); err != nil {
+					panic(err)
+				}
+				msg.Response = true
+				msg.Answer = append(msg.Answer, A("example.org. IN A 127.0.0.1"))
+				pack, err := msg.Pack()
+				if err != nil {
+					panic(err)
+				}
+				w.Write(pack)
+			}))
+			ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+			ts.StartTLS()
+			defer ts.Close()
+
+			bench := dnsbench.Benchmark{
+				Server:         ts.URL + tt.server,
+				DohMethod:      tt.method,
+				DoHHeaders:     []string{"Authorization: Bearer token", "User-Agent: dnspyre-test", "Host: tenant.example.org"},
+				DoHQueryParams: tt.queryParams,
+				TLSServerName:  "localhost",
+				CACertFile:     "testdata/test.crt",
+				Queries:        []string{"example.org"},
+				Types:          []string{"A"},
+				Concurrency:    1,
+				Count:          2,
+				Probability:    1,
+				WriteTimeout:   1 * time.Second,
+				ReadTimeout:    3 * time.Second,
+				ConnectTimeout: 1 * time.Second,
+				RequestTimeout: 5 * time.Second,
+				Recurse:        true,
+				Writer:         io.Discard,
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			suite.Require().NoError(err, "expected no error from benchmark run")
+			suite.Require().Len(rs, 1, "expected results from one worker")
+			suite.EqualValues(2, rs[0].Counters.Success)
+			suite.Equal(map[int]int64{http.StatusOK: 2}, rs[0].DoHStatusCodes)
+		})
+	}
+}
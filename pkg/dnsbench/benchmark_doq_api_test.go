@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -69,10 +70,54 @@ func (suite *DoQTestSuite) TestBenchmark_Run() {
 	suite.Equal(fmt.Sprintf("Using 1 hostnames\nBenchmarking %s via quic with 2 concurrent requests \n", server.addr), buf.String())
 }
 
+func (suite *DoQTestSuite) TestBenchmark_Run_socket_options() {
+	if runtime.GOOS != "linux" {
+		suite.T().Skip("socket options are supported only on Linux")
+	}
+	server := newDoQServer(func(_ quic.Connection, r *dns.Msg) *dns.Msg {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		return ret
+	})
+	server.start()
+	defer server.stop()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         "quic://" + server.addr,
+		DSCP:           46,
+		Concurrency:    2,
+		Count:          2,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Insecure:       true,
+		Writer:         io.Discard,
+	}
+
+	rs, err := bench.Run(context.Background())
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 2)
+	for _, r := range rs {
+		suite.EqualValues(2, r.Counters.Success)
+	}
+	settings := bench.SocketSettings()
+	suite.Require().Len(settings, 1)
+	suite.Contains([]string{"udp4", "udp6"}, settings[0].Network)
+	suite.Equal(46, settings[0].DSCP)
+}
+
 func (suite *DoQTestSuite) TestBenchmark_Run_separate_connections() {
 	tests := []struct {
 		name                    string
 		separateConnections     bool
+		qperConn                int64
+		connPerQuery            bool
 		wantNumberOfConnections int
 	}{
 		{
@@ -85,6 +130,21 @@ func (suite *DoQTestSuite) TestBenchmark_Run_separate_connections() {
 			separateConnections:     false,
 			wantNumberOfConnections: 1,
 		},
+		{
+			name:                    "query per connection",
+			qperConn:                1,
+			wantNumberOfConnections: 10,
+		},
+		{
+			name:                    "connection per query",
+			connPerQuery:            true,
+			wantNumberOfConnections: 10,
+		},
+		{
+			name:                    "two queries per connection",
+			qperConn:                2,
+			wantNumberOfConnections: 5,
+		},
 	}
 	for _, tt := range tests {
 		suite.Run(tt.name, func() {
@@ -122,6 +182,8 @@ func (suite *DoQTestSuite) TestBenchmark_Run_separate_connections() {
 				DohMethod:                 dnsbench.PostHTTPMethod,
 				Writer:                    &buf,
 				SeparateWorkerConnections: tt.separateConnections,
+				QperConn:                  tt.qperConn,
+				ConnPerQuery:              tt.connPerQuery,
 				Insecure:                  true,
 			}
 
@@ -224,14 +286,113 @@ func (suite *DoQTestSuite) TestBenchmark_Run_error() {
 	suite.EqualValues(2, rs[1].Counters.IOError, "there should be errors")
 }
 
+func (suite *DoQTestSuite) TestBenchmark_Run_phase_timings() {
+	server := newDoQServer(func(_ quic.Connection, r *dns.Msg) *dns.Msg {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		return ret
+	})
+	server.start()
+	defer server.stop()
+
+	bench := dnsbench.Benchmark{
+		Server:         "quic://" + server.addr,
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Concurrency:    1,
+		Count:          2,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Recurse:        true,
+		Insecure:       true,
+		PhaseTimings:   true,
+		Writer:         io.Discard,
+	}
+
+	rs, err := bench.Run(context.Background())
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.EqualValues(2, rs[0].Counters.Success)
+	assertPhases(suite.T(), rs, dnsbench.PhaseQUICHandshake, dnsbench.PhaseFirstByte)
+}
+
+func (suite *DoQTestSuite) TestBenchmark_Run_tls_session_resumption() {
+	tests := []struct {
+		name        string
+		zeroRTT     bool
+		wantZeroRTT int64
+	}{
+		{
+			name: "session resumption",
+		},
+		{
+			name:        "0-RTT",
+			zeroRTT:     true,
+			wantZeroRTT: 2,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			var used0RTT atomic.Int64
+			server := newDoQServer(func(c quic.Connection, r *dns.Msg) *dns.Msg {
+				if c.ConnectionState().Used0RTT {
+					used0RTT.Add(1)
+				}
+				ret := new(dns.Msg)
+				ret.SetReply(r)
+				ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+				return ret
+			})
+			server.allow0RTT = true
+			server.start()
+			defer server.stop()
+
+			bench := dnsbench.Benchmark{
+				Queries:              []string{"example.org"},
+				Types:                []string{"A"},
+				Server:               "quic://" + server.addr,
+				Concurrency:          1,
+				Count:                3,
+				Probability:          1,
+				WriteTimeout:         1 * time.Second,
+				ReadTimeout:          3 * time.Second,
+				ConnectTimeout:       1 * time.Second,
+				RequestTimeout:       5 * time.Second,
+				Recurse:              true,
+				Insecure:             true,
+				QperConn:             1,
+				TLSSessionResumption: true,
+				ZeroRTT:              tt.zeroRTT,
+				Writer:               io.Discard,
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			suite.Require().NoError(err, "expected no error from benchmark run")
+			suite.Require().Len(rs, 1)
+			suite.EqualValues(3, rs[0].Counters.Success)
+			suite.Require().NotNil(rs[0].Handshakes)
+			suite.EqualValues(1, rs[0].Handshakes.Full.TotalCount())
+			suite.EqualValues(2, rs[0].Handshakes.Resumed.TotalCount())
+			suite.Equal(tt.wantZeroRTT, rs[0].Handshakes.ZeroRTT)
+			suite.Equal(tt.wantZeroRTT, used0RTT.Load())
+		})
+	}
+}
+
 type doqHandler func(conn quic.Connection, req *dns.Msg) *dns.Msg
 
 // doqServer is a DoQ test DNS server.
 type doqServer struct {
-	addr     string
-	listener *quic.Listener
-	closed   atomic.Bool
-	handler  doqHandler
+	addr      string
+	listener  io.Closer
+	closed    atomic.Bool
+	handler   doqHandler
+	allow0RTT bool
 }
 
 func newDoQServer(f doqHandler) *doqServer {
@@ -240,15 +401,27 @@ func newDoQServer(f doqHandler) *doqServer {
 }
 
 func (d *doqServer) start() {
-	listener, err := quic.ListenAddr("localhost:0", generateTLSConfig(), nil)
-	if err != nil {
-		panic(err)
+	var accept func(context.Context) (quic.Connection, error)
+	if d.allow0RTT {
+		listener, err := quic.ListenAddrEarly("localhost:0", generateTLSConfig(), &quic.Config{Allow0RTT: true})
+		if err != nil {
+			panic(err)
+		}
+		d.listener, d.addr = listener, listener.Addr().String()
+		accept = func(ctx context.Context) (quic.Connection, error) {
+			return listener.Accept(ctx)
+		}
+	} else {
+		listener, err := quic.ListenAddr("localhost:0", generateTLSConfig(), nil)
+		if err != nil {
+			panic(err)
+		}
+		d.listener, d.addr = listener, listener.Addr().String()
+		accept = listener.Accept
 	}
-	d.listener = listener
-	d.addr = listener.Addr().String()
 	go func() {
 		for {
-			conn, err := listener.Accept(context.Background())
+			conn, err := accept(context.Background())
 			if err != nil {
 				if !d.closed.Load() {
 					panic(err)
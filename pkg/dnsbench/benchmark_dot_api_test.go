@@ -6,6 +6,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"io"
 	"os"
 	"testing"
 	"time"
@@ -196,3 +197,157 @@ func (suite *DoTTestSuite) TestBenchmark_Run_error() {
 	suite.EqualValues(2, rs[1].Counters.Total, "there should be executions")
 	suite.EqualValues(2, rs[1].Counters.IOError, "there should be errors")
 }
+
+func (suite *DoTTestSuite) TestBenchmark_Run_phase_timings() {
+	cert, err := tls.LoadX509KeyPair("testdata/test.crt", "testdata/test.key")
+	suite.Require().NoError(err)
+
+	server := NewServer(dnsbench.TLSTransport, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer server.Close()
+
+	bench := dnsbench.Benchmark{
+		Server:         server.Addr,
+		DOT:            true,
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Concurrency:    1,
+		Count:          2,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Recurse:        true,
+		Insecure:       true,
+		PhaseTimings:   true,
+		Writer:         io.Discard,
+	}
+
+	rs, err := bench.Run(context.Background())
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.EqualValues(2, rs[0].Counters.Success)
+	assertPhases(suite.T(), rs, dnsbench.PhaseConnect, dnsbench.PhaseTLSHandshake, dnsbench.PhaseFirstByte)
+}
+
+func (suite *DoTTestSuite) TestBenchmark_Run_tls_session_resumption() {
+	cert, err := tls.LoadX509KeyPair("testdata/test.crt", "testdata/test.key")
+	suite.Require().NoError(err)
+
+	server := NewServer(dnsbench.TLSTransport, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer server.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:              []string{"example.org"},
+		Types:                []string{"A"},
+		Server:               server.Addr,
+		DOT:                  true,
+		Concurrency:          1,
+		Count:                3,
+		Probability:          1,
+		WriteTimeout:         1 * time.Second,
+		ReadTimeout:          3 * time.Second,
+		ConnectTimeout:       1 * time.Second,
+		RequestTimeout:       5 * time.Second,
+		Recurse:              true,
+		Insecure:             true,
+		QperConn:             1,
+		TLSSessionResumption: true,
+		Writer:               io.Discard,
+	}
+
+	rs, err := bench.Run(context.Background())
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1)
+	suite.EqualValues(3, rs[0].Counters.Success)
+	suite.Require().NotNil(rs[0].Handshakes)
+	suite.EqualValues(1, rs[0].Handshakes.Full.TotalCount())
+	suite.EqualValues(2, rs[0].Handshakes.Resumed.TotalCount())
+	suite.Zero(rs[0].Handshakes.ZeroRTT)
+}
+
+func (suite *DoTTestSuite) TestBenchmark_Run_mutual_tls() {
+	tests := []struct {
+		name          string
+		clientCert    bool
+		tlsMaxVersion string
+		wantSuccess   int64
+	}{
+		{
+			name:        "client certificate",
+			clientCert:  true,
+			wantSuccess: 2,
+		},
+		{
+			name: "missing client certificate",
+		},
+		{
+			name:          "TLS version not supported by server",
+			clientCert:    true,
+			tlsMaxVersion: "1.2",
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			cert, err := tls.LoadX509KeyPair("testdata/test.crt", "testdata/test.key")
+			suite.Require().NoError(err)
+			pem, err := os.ReadFile("testdata/test.crt")
+			suite.Require().NoError(err)
+			clientCAs := x509.NewCertPool()
+			suite.Require().True(clientCAs.AppendCertsFromPEM(pem))
+
+			server := NewServer(dnsbench.TLSTransport, &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				ClientCAs:    clientCAs,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				MinVersion:   tls.VersionTLS13,
+			}, func(w dns.ResponseWriter, r *dns.Msg) {
+				ret := new(dns.Msg)
+				ret.SetReply(r)
+				ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+				w.WriteMsg(ret)
+			})
+			defer server.Close()
+
+			bench := dnsbench.Benchmark{
+				Queries:        []string{"example.org"},
+				Types:          []string{"A"},
+				Server:         server.Addr,
+				DOT:            true,
+				Concurrency:    1,
+				Count:          2,
+				Probability:    1,
+				WriteTimeout:   1 * time.Second,
+				ReadTimeout:    3 * time.Second,
+				ConnectTimeout: 1 * time.Second,
+				RequestTimeout: 5 * time.Second,
+				Recurse:        true,
+				CACertFile:     "testdata/test.crt",
+				TLSMaxVersion:  tt.tlsMaxVersion,
+				Writer:         io.Discard,
+			}
+			if tt.clientCert {
+				bench.ClientCertFile = "testdata/test.crt"
+				bench.ClientKeyFile = "testdata/test.key"
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			suite.Require().NoError(err, "expected no error from benchmark run")
+			suite.Require().Len(rs, 1, "expected results from one worker")
+			suite.EqualValues(tt.wantSuccess, rs[0].Counters.Success)
+			suite.EqualValues(2-tt.wantSuccess, rs[0].Counters.IOError)
+		})
+	}
+}
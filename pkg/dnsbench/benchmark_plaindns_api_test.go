@@ -5,12 +5,16 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unicode"
 
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/suite"
@@ -238,6 +242,169 @@ func (suite *PlainDNSTestSuite) TestBenchmark_Run_edns0_ednsopt() {
 	assertResult(suite.T(), rs)
 }
 
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_header() {
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		suite.Equal(dns.OpcodeNotify, r.Opcode)
+		suite.True(r.CheckingDisabled)
+		suite.True(r.AuthenticatedData)
+		suite.False(r.RecursionDesired)
+		suite.EqualValues(dns.ClassCHAOS, r.Question[0].Qclass)
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		// wait some time to actually have some observable duration
+		time.Sleep(time.Millisecond * 500)
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:           []string{"example.org"},
+		Types:             []string{"A", "AAAA"},
+		Server:            s.Addr,
+		TCP:               false,
+		Concurrency:       2,
+		Count:             1,
+		Probability:       1,
+		WriteTimeout:      1 * time.Second,
+		ReadTimeout:       3 * time.Second,
+		ConnectTimeout:    1 * time.Second,
+		RequestTimeout:    5 * time.Second,
+		Rcodes:            true,
+		Recurse:           false,
+		CheckingDisabled:  true,
+		AuthenticatedData: true,
+		Opcode:            "NOTIFY",
+		Class:             "CH",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	assertResult(suite.T(), rs)
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_0x20() {
+	type args struct {
+		swapCase bool
+	}
+	tests := []struct {
+		name             string
+		args             args
+		wantCaseMismatch int64
+	}{
+		{
+			name:             "case preserved",
+			wantCaseMismatch: 0,
+		},
+		{
+			name:             "case not preserved",
+			args:             args{swapCase: true},
+			wantCaseMismatch: 2,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+				suite.True(strings.EqualFold("example.org.", r.Question[0].Name))
+
+				ret := new(dns.Msg)
+				ret.SetReply(r)
+				if tt.args.swapCase {
+					ret.Question[0].Name = strings.Map(func(r rune) rune {
+						if unicode.IsUpper(r) {
+							return unicode.ToLower(r)
+						}
+						return unicode.ToUpper(r)
+					}, ret.Question[0].Name)
+				}
+				ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+				w.WriteMsg(ret)
+			})
+			defer s.Close()
+
+			bench := dnsbench.Benchmark{
+				Queries:           []string{"example.org"},
+				Types:             []string{"A", "AAAA"},
+				Server:            s.Addr,
+				TCP:               false,
+				Concurrency:       2,
+				Count:             1,
+				Probability:       1,
+				WriteTimeout:      1 * time.Second,
+				ReadTimeout:       3 * time.Second,
+				ConnectTimeout:    1 * time.Second,
+				RequestTimeout:    5 * time.Second,
+				Rcodes:            true,
+				Recurse:           true,
+				CaseRandomization: true,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := bench.Run(ctx)
+
+			suite.Require().NoError(err, "expected no error from benchmark run")
+			suite.Require().Len(rs, 2, "expected results from two workers")
+			for _, r := range rs {
+				suite.EqualValues(2, r.Counters.Total, "there should be executions")
+				suite.Equal(tt.wantCaseMismatch, r.Counters.CaseMismatch, "unexpected number of case mismatches")
+			}
+		})
+	}
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_expectations() {
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if r.Question[0].Qtype == dns.TypeA {
+			ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		}
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	expectationsFile := suite.T().TempDir() + "/expectations"
+	suite.Require().NoError(os.WriteFile(expectationsFile, []byte("# test expectations\nexample.org|type=AAAA|min-answers=1\n"), 0o600))
+
+	bench := dnsbench.Benchmark{
+		Queries:          []string{"example.org|type=A|rcode=NOERROR|answer=example.org. IN A 127.0.0.2"},
+		ExpectationsFile: expectationsFile,
+		Types:            []string{"A", "AAAA"},
+		Server:           s.Addr,
+		TCP:              false,
+		Concurrency:      2,
+		Count:            1,
+		Probability:      1,
+		WriteTimeout:     1 * time.Second,
+		ReadTimeout:      3 * time.Second,
+		ConnectTimeout:   1 * time.Second,
+		RequestTimeout:   5 * time.Second,
+		Rcodes:           true,
+		Recurse:          true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 2, "expected results from two workers")
+	for _, r := range rs {
+		suite.EqualValues(2, r.Counters.Total, "there should be executions")
+		suite.EqualValues(2, r.Counters.WrongAnswer, "there should be wrong answers")
+		suite.Equal(map[string]int64{"example.org.": 2}, r.WrongAnswers)
+	}
+}
+
 func (suite *PlainDNSTestSuite) TestBenchmark_Run_probability() {
 	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
 		ret := new(dns.Msg)
@@ -762,3 +929,453 @@ func (suite *PlainDNSTestSuite) TestBenchmark_RandomRequestDelay() {
 	assertResult(suite.T(), rs)
 	suite.InDelta(4*time.Second, benchDuration, float64(2*time.Second))
 }
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_retries() {
+	var requests atomic.Int64
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		if requests.Add(1)%2 == 1 {
+			// drop every first attempt, so that the request has to be retried
+			return
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         s.Addr,
+		TCP:            false,
+		Concurrency:    1,
+		Count:          2,
+		Probability:    1,
+		WriteTimeout:   100 * time.Millisecond,
+		ReadTimeout:    100 * time.Millisecond,
+		ConnectTimeout: 100 * time.Millisecond,
+		RequestTimeout: 200 * time.Millisecond,
+		Retries:        2,
+		RetryBackoff:   10 * time.Millisecond,
+		Rcodes:         true,
+		Recurse:        true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+	suite.EqualValues(2, rs[0].Counters.Total, "there should be executions")
+	suite.EqualValues(2, rs[0].Counters.Success, "retried requests should succeed")
+	suite.EqualValues(0, rs[0].Counters.IOError, "there should be no IO errors")
+	suite.EqualValues(2, rs[0].Counters.Retries, "each request should be retried once")
+	suite.EqualValues(2, rs[0].Hist.TotalCount(), "end-to-end latency should be recorded once per request")
+	suite.Require().NotNil(rs[0].AttemptHist)
+	suite.EqualValues(4, rs[0].AttemptHist.TotalCount(), "latency of each attempt should be recorded")
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_tcpFallback() {
+	handler := func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if w.RemoteAddr().Network() == dnsbench.UDPTransport {
+			ret.Truncated = true
+		} else {
+			ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		}
+		w.WriteMsg(ret)
+	}
+	s := NewServer(dnsbench.UDPTransport, nil, handler)
+	defer s.Close()
+
+	ch := make(chan bool)
+	tcpServer := &dns.Server{Net: dnsbench.TCPTransport, Addr: s.Addr, NotifyStartedFunc: func() { close(ch) }, Handler: dns.HandlerFunc(handler)}
+	go func() {
+		if err := tcpServer.ListenAndServe(); err != nil {
+			panic(err)
+		}
+	}()
+	<-ch
+	defer tcpServer.Shutdown()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A", "AAAA"},
+		Server:         s.Addr,
+		TCP:            false,
+		Concurrency:    2,
+		Count:          1,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		TCPFallback:    true,
+		Rcodes:         true,
+		Recurse:        true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 2, "expected results from two workers")
+	for _, r := range rs {
+		suite.EqualValues(2, r.Counters.Total, "there should be executions")
+		suite.EqualValues(2, r.Counters.TCPFallback, "truncated responses should be retried over TCP")
+		suite.EqualValues(0, r.Counters.Truncated, "responses received over TCP should not be truncated")
+		suite.EqualValues(2, r.Counters.Success, "responses received over TCP should be successful")
+		suite.Require().NotNil(r.AttemptHist)
+		suite.EqualValues(4, r.AttemptHist.TotalCount(), "latency of each attempt should be recorded")
+	}
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_pipeline() {
+	l, err := net.Listen(dnsbench.TCPTransport, "127.0.0.1:0")
+	suite.Require().NoError(err)
+	defer l.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		co := &dns.Conn{Conn: c}
+		defer co.Close()
+
+		// wait for both pipelined queries and respond in the reverse order
+		var reqs []*dns.Msg
+		for len(reqs) < 2 {
+			r, err := co.ReadMsg()
+			if err != nil {
+				return
+			}
+			reqs = append(reqs, r)
+		}
+		for i := len(reqs) - 1; i >= 0; i-- {
+			ret := new(dns.Msg)
+			ret.SetReply(reqs[i])
+			if reqs[i].Question[0].Qtype == dns.TypeA {
+				ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+			} else {
+				ret.Answer = append(ret.Answer, AAAA("example.org. IN AAAA ::1"))
+			}
+			if err := co.WriteMsg(ret); err != nil {
+				return
+			}
+		}
+	}()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A", "AAAA"},
+		Server:         l.Addr().String(),
+		TCP:            true,
+		Concurrency:    1,
+		Count:          1,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Pipeline:       2,
+		Rcodes:         true,
+		Recurse:        true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+	suite.EqualValues(2, rs[0].Counters.Total, "there should be executions")
+	suite.EqualValues(2, rs[0].Counters.Success, "out of order responses should be matched to the queries")
+	suite.EqualValues(0, rs[0].Counters.IDmismatch, "there should be no ID mismatches")
+	suite.EqualValues(map[string]int64{"A": 1, "AAAA": 1}, rs[0].Qtypes)
+	suite.Require().NotNil(rs[0].PipelineDepth)
+	suite.EqualValues(2, rs[0].PipelineDepth.Max(), "both queries should be in-flight at once")
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_pipeline_without_write_timeout() {
+	server := NewServer(dnsbench.TCPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer server.Close()
+
+	// WriteTimeout is not set, so the write deadline of the pipelined queries is derived from RequestTimeout
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         server.Addr,
+		TCP:            true,
+		Concurrency:    2,
+		Count:          5,
+		Probability:    1,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Pipeline:       4,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 2, "expected results from two workers")
+	for _, r := range rs {
+		suite.EqualValues(5, r.Counters.Total, "there should be executions")
+		suite.EqualValues(5, r.Counters.Success, "pipelined writes should not time out")
+		suite.EqualValues(0, r.Counters.IOError, "there should be no I/O errors")
+	}
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_pipeline_read_timeout() {
+	l, err := net.Listen(dnsbench.TCPTransport, "127.0.0.1:0")
+	suite.Require().NoError(err)
+	defer l.Close()
+
+	var conns atomic.Int32
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns.Add(1)
+			go func() {
+				co := &dns.Conn{Conn: c}
+				defer co.Close()
+				// only the first query of each connection is answered, the server then stops answering without closing the connection
+				for answered := false; ; answered = true {
+					r, err := co.ReadMsg()
+					if err != nil {
+						return
+					}
+					if answered {
+						continue
+					}
+					ret := new(dns.Msg)
+					ret.SetReply(r)
+					ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+					co.WriteMsg(ret)
+				}
+			}()
+		}
+	}()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         l.Addr().String(),
+		TCP:            true,
+		Concurrency:    1,
+		Count:          6,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    1 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 200 * time.Millisecond,
+		Pipeline:       2,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+	suite.EqualValues(6, rs[0].Counters.Total, "there should be executions")
+	// the connection, which stopped answering, fails after the read deadline and the next queries are sent over new connection
+	suite.Greater(conns.Load(), int32(1), "new connection should be dialed after the read deadline")
+	suite.Greater(rs[0].Counters.Success, int64(1), "first query of the new connection should be answered")
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_conn_stats() {
+	tests := []struct {
+		name               string
+		serverCloses       bool
+		wantSuccess        int64
+		wantOpened         int64
+		wantReused         int64
+		wantClosedByServer int64
+	}{
+		{
+			name:        "connection kept open",
+			wantSuccess: 3,
+			wantOpened:  1,
+			wantReused:  2,
+		},
+		{
+			name:               "connection closed by server after response",
+			serverCloses:       true,
+			wantSuccess:        2,
+			wantOpened:         2,
+			wantReused:         1,
+			wantClosedByServer: 1,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			server := NewServer(dnsbench.TCPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+				ret := new(dns.Msg)
+				ret.SetReply(r)
+				ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+				w.WriteMsg(ret)
+				if tt.serverCloses {
+					w.Close()
+				}
+			})
+			defer server.Close()
+
+			bench := dnsbench.Benchmark{
+				Queries:         []string{"example.org"},
+				Types:           []string{"A"},
+				Server:          server.Addr,
+				TCP:             true,
+				Concurrency:     1,
+				Count:           3,
+				Probability:     1,
+				WriteTimeout:    1 * time.Second,
+				ReadTimeout:     3 * time.Second,
+				ConnectTimeout:  1 * time.Second,
+				RequestTimeout:  5 * time.Second,
+				HistMax:         time.Second,
+				Recurse:         true,
+				ConnectionStats: true,
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			suite.Require().NoError(err, "expected no error from benchmark run")
+			suite.Require().Len(rs, 1, "expected results from one worker")
+			suite.EqualValues(tt.wantSuccess, rs[0].Counters.Success)
+			suite.EqualValues(3-tt.wantSuccess, rs[0].Counters.IOError)
+			conns := rs[0].Conns
+			suite.Require().NotNil(conns)
+			suite.EqualValues(tt.wantOpened, conns.Opened)
+			suite.EqualValues(tt.wantReused, conns.Reused)
+			suite.EqualValues(tt.wantClosedByServer, conns.ClosedByServer)
+			suite.EqualValues(0, conns.ResetOnError)
+			suite.EqualValues(tt.wantOpened, conns.FirstQuery.TotalCount())
+			suite.EqualValues(tt.wantSuccess-tt.wantOpened, conns.ReusedQuery.TotalCount())
+		})
+	}
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_udpEngine() {
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:          []string{"example.org"},
+		Types:            []string{"A", "AAAA"},
+		Server:           s.Addr,
+		Concurrency:      2,
+		Count:            50,
+		Probability:      1,
+		WriteTimeout:     1 * time.Second,
+		ReadTimeout:      3 * time.Second,
+		ConnectTimeout:   1 * time.Second,
+		RequestTimeout:   5 * time.Second,
+		UDPEngine:        true,
+		UDPEngineSockets: 2,
+		MaxOutstanding:   20,
+		Rcodes:           true,
+		Recurse:          true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 2, "expected results from two workers")
+	for _, r := range rs {
+		suite.EqualValues(100, r.Counters.Total, "there should be executions")
+		suite.EqualValues(100, r.Counters.Success, "all responses should be matched to the queries")
+		suite.EqualValues(0, r.Counters.IDmismatch, "there should be no ID mismatches")
+		suite.EqualValues(0, r.Counters.Late, "there should be no late responses")
+		suite.EqualValues(0, r.Counters.Duplicate, "there should be no duplicate responses")
+		suite.EqualValues(map[string]int64{"A": 50, "AAAA": 50}, r.Qtypes)
+	}
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_udpEngine_late_and_duplicate() {
+	pc, err := net.ListenPacket(dnsbench.UDPTransport, "127.0.0.1:0")
+	suite.Require().NoError(err)
+	defer pc.Close()
+
+	go func() {
+		buf := make([]byte, dns.MaxMsgSize)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			r := new(dns.Msg)
+			if err := r.Unpack(buf[:n]); err != nil {
+				continue
+			}
+			ret := new(dns.Msg)
+			ret.SetReply(r)
+			ret.Answer = append(ret.Answer, A(r.Question[0].Name+" IN A 127.0.0.1"))
+			packed, err := ret.Pack()
+			if err != nil {
+				continue
+			}
+			if r.Question[0].Name == "late.example.org." {
+				// respond after the request times out
+				time.AfterFunc(200*time.Millisecond, func() { pc.WriteTo(packed, addr) })
+				continue
+			}
+			// respond twice
+			pc.WriteTo(packed, addr)
+			pc.WriteTo(packed, addr)
+		}
+	}()
+
+	bench := dnsbench.Benchmark{
+		Queries:          []string{"late.example.org", "example.org"},
+		Types:            []string{"A"},
+		Server:           pc.LocalAddr().String(),
+		Concurrency:      1,
+		Count:            1,
+		Probability:      1,
+		WriteTimeout:     100 * time.Millisecond,
+		ReadTimeout:      100 * time.Millisecond,
+		ConnectTimeout:   100 * time.Millisecond,
+		RequestTimeout:   100 * time.Millisecond,
+		RequestDelay:     "400ms",
+		UDPEngine:        true,
+		UDPEngineSockets: 1,
+		Rcodes:           true,
+		Recurse:          true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+	suite.EqualValues(2, rs[0].Counters.Total, "there should be executions")
+	suite.EqualValues(1, rs[0].Counters.Success, "one query should be answered")
+	suite.EqualValues(1, rs[0].Counters.IOError, "one query should time out")
+	suite.EqualValues(1, rs[0].Counters.Late, "late response should be counted")
+	suite.EqualValues(1, rs[0].Counters.Duplicate, "duplicate response should be counted")
+}
@@ -127,6 +127,365 @@ func TestBenchmark_init(t *testing.T) {
 			benchmark: Benchmark{Server: "8.8.8.8", RequestDelay: "invalid"},
 			wantErr:   true,
 		},
+		{
+			name:       "supported opcode and class",
+			benchmark:  Benchmark{Server: "8.8.8.8", Opcode: "NOTIFY", Class: "CH", RandomFlags: []string{"cd", "ad"}},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:      "invalid opcode",
+			benchmark: Benchmark{Server: "8.8.8.8", Opcode: "invalid"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid class",
+			benchmark: Benchmark{Server: "8.8.8.8", Class: "invalid"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid random flag",
+			benchmark: Benchmark{Server: "8.8.8.8", RandomFlags: []string{"qr"}},
+			wantErr:   true,
+		},
+		{
+			name:       "pipelining over TCP",
+			benchmark:  Benchmark{Server: "8.8.8.8", TCP: true, Pipeline: 10},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:      "pipelining over UDP",
+			benchmark: Benchmark{Server: "8.8.8.8", Pipeline: 10},
+			wantErr:   true,
+		},
+		{
+			name:      "pipelining with retries",
+			benchmark: Benchmark{Server: "8.8.8.8", DOT: true, Pipeline: 10, Retries: 1},
+			wantErr:   true,
+		},
+		{
+			name:       "asynchronous UDP engine",
+			benchmark:  Benchmark{Server: "8.8.8.8", UDPEngine: true},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:      "asynchronous UDP engine over TCP",
+			benchmark: Benchmark{Server: "8.8.8.8", TCP: true, UDPEngine: true},
+			wantErr:   true,
+		},
+		{
+			name:      "asynchronous UDP engine with too many outstanding queries",
+			benchmark: Benchmark{Server: "8.8.8.8", UDPEngine: true, UDPEngineSockets: 1, MaxOutstanding: 100000},
+			wantErr:   true,
+		},
+		{
+			name:      "asynchronous UDP engine with connection churn",
+			benchmark: Benchmark{Server: "8.8.8.8", UDPEngine: true, ConnRate: 10},
+			wantErr:   true,
+		},
+		{
+			name:       "connection per query",
+			benchmark:  Benchmark{Server: "quic://dns.adguard-dns.com", ConnPerQuery: true, QperConn: 1},
+			wantServer: "dns.adguard-dns.com:853",
+		},
+		{
+			name:      "connection per query with queries per connection",
+			benchmark: Benchmark{Server: "8.8.8.8", ConnPerQuery: true, QperConn: 10},
+			wantErr:   true,
+		},
+		{
+			name:      "negative connection rate",
+			benchmark: Benchmark{Server: "8.8.8.8", ConnRate: -1},
+			wantErr:   true,
+		},
+		{
+			name: "TLS settings",
+			benchmark: Benchmark{
+				Server: "8.8.8.8", DOT: true, CACertFile: "testdata/test.crt", ClientCertFile: "testdata/test.crt", ClientKeyFile: "testdata/test.key",
+				TLSMinVersion: "1.2", TLSMaxVersion: "1.3", TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+				TLSCurves: []string{"x25519", "P-256"}, ALPN: []string{"dot"},
+			},
+			wantServer: "8.8.8.8:853",
+		},
+		{
+			name:      "missing CA certificate file",
+			benchmark: Benchmark{Server: "8.8.8.8", DOT: true, CACertFile: "nonexisting"},
+			wantErr:   true,
+		},
+		{
+			name:      "CA certificate file without certificates",
+			benchmark: Benchmark{Server: "8.8.8.8", DOT: true, CACertFile: "testdata/test.key"},
+			wantErr:   true,
+		},
+		{
+			name:      "client certificate without key",
+			benchmark: Benchmark{Server: "8.8.8.8", DOT: true, ClientCertFile: "testdata/test.crt"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid TLS version",
+			benchmark: Benchmark{Server: "8.8.8.8", DOT: true, TLSMinVersion: "1.4"},
+			wantErr:   true,
+		},
+		{
+			name:      "minimum TLS version greater than maximum",
+			benchmark: Benchmark{Server: "8.8.8.8", DOT: true, TLSMinVersion: "1.3", TLSMaxVersion: "1.2"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid cipher suite",
+			benchmark: Benchmark{Server: "8.8.8.8", DOT: true, TLSCipherSuites: []string{"invalid"}},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid curve",
+			benchmark: Benchmark{Server: "8.8.8.8", DOT: true, TLSCurves: []string{"P-224"}},
+			wantErr:   true,
+		},
+		{
+			name:       "DoH URI template",
+			benchmark:  Benchmark{Server: "https://1.1.1.1/custom{?dns}"},
+			wantServer: "https://1.1.1.1/custom",
+		},
+		{
+			name:       "DoH URI template with query parameters",
+			benchmark:  Benchmark{Server: "https://1.1.1.1/dns-query?tenant=a{&dns}", DoHQueryParams: []string{"b=c"}},
+			wantServer: "https://1.1.1.1/dns-query",
+		},
+		{
+			name:      "unsupported DoH URI template",
+			benchmark: Benchmark{Server: "https://1.1.1.1/{tenant}{?dns}"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid DoH query parameter",
+			benchmark: Benchmark{Server: "https://1.1.1.1", DoHQueryParams: []string{"invalid"}},
+			wantErr:   true,
+		},
+		{
+			name:      "reserved DoH query parameter",
+			benchmark: Benchmark{Server: "https://1.1.1.1/dns-query?dns=AAAB"},
+			wantErr:   true,
+		},
+		{
+			name:       "DoH headers",
+			benchmark:  Benchmark{Server: "https://1.1.1.1", DoHHeaders: []string{"Authorization: Bearer token", "host: example.org"}},
+			wantServer: "https://1.1.1.1/dns-query",
+		},
+		{
+			name:      "invalid DoH header",
+			benchmark: Benchmark{Server: "https://1.1.1.1", DoHHeaders: []string{"invalid"}},
+			wantErr:   true,
+		},
+		{
+			name:       "DNSCrypt stamp",
+			benchmark:  Benchmark{Server: "sdns://AQAAAAAAAAAACTEyNy4wLjAuMSAAAQIDBAUGBwgJCgsMDQ4PEBESExQVFhcYGRobHB0eHxsyLmRuc2NyeXB0LWNlcnQuZXhhbXBsZS5jb20"},
+			wantServer: "127.0.0.1:443",
+		},
+		{
+			name:       "DNSCrypt stamp with port",
+			benchmark:  Benchmark{Server: "sdns://AQAAAAAAAAAADjEyNy4wLjAuMTo1NDQzIAABAgMEBQYHCAkKCwwNDg8QERITFBUWFxgZGhscHR4fGzIuZG5zY3J5cHQtY2VydC5leGFtcGxlLmNvbQ"},
+			wantServer: "127.0.0.1:5443",
+		},
+		{
+			name:       "DNSCrypt stamp with IPv6",
+			benchmark:  Benchmark{Server: "sdns://AQAAAAAAAAAABVs6OjFdIAABAgMEBQYHCAkKCwwNDg8QERITFBUWFxgZGhscHR4fGzIuZG5zY3J5cHQtY2VydC5leGFtcGxlLmNvbQ"},
+			wantServer: "[::1]:443",
+		},
+		{
+			name:      "not DNSCrypt stamp",
+			benchmark: Benchmark{Server: "sdns://AgAAAAAAAAAACTEyNy4wLjAuMSAAAQIDBAUGBwgJCgsMDQ4PEBESExQVFhcYGRobHB0eHxsyLmRuc2NyeXB0LWNlcnQuZXhhbXBsZS5jb20"},
+			wantErr:   true,
+		},
+		{
+			name: "DNSCrypt provider name and public key",
+			benchmark: Benchmark{
+				Server: "127.0.0.1", DNSCryptProviderName: "2.dnscrypt-cert.example.com",
+				DNSCryptPublicKey: "0001:0203:0405:0607:0809:0A0B:0C0D:0E0F:1011:1213:1415:1617:1819:1A1B:1C1D:1E1F",
+			},
+			wantServer: "127.0.0.1:443",
+		},
+		{
+			name:      "DNSCrypt provider name without public key",
+			benchmark: Benchmark{Server: "127.0.0.1", DNSCryptProviderName: "2.dnscrypt-cert.example.com"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid DNSCrypt public key",
+			benchmark: Benchmark{Server: "127.0.0.1", DNSCryptProviderName: "2.dnscrypt-cert.example.com", DNSCryptPublicKey: "0001"},
+			wantErr:   true,
+		},
+		{
+			name: "DNSCrypt with DoT",
+			benchmark: Benchmark{
+				Server: "sdns://AQAAAAAAAAAACTEyNy4wLjAuMSAAAQIDBAUGBwgJCgsMDQ4PEBESExQVFhcYGRobHB0eHxsyLmRuc2NyeXB0LWNlcnQuZXhhbXBsZS5jb20", DOT: true,
+			},
+			wantErr: true,
+		},
+		{
+			name:       "SOCKS5 proxy with plain DNS over UDP",
+			benchmark:  Benchmark{Server: "8.8.8.8", Proxy: "socks5://127.0.0.1:1080"},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:       "HTTP proxy with DoT",
+			benchmark:  Benchmark{Server: "8.8.8.8", DOT: true, Proxy: "http://127.0.0.1:3128"},
+			wantServer: "8.8.8.8:853",
+		},
+		{
+			name:      "HTTP proxy with plain DNS over UDP",
+			benchmark: Benchmark{Server: "8.8.8.8", Proxy: "http://127.0.0.1:3128"},
+			wantErr:   true,
+		},
+		{
+			name:      "proxy with DoQ",
+			benchmark: Benchmark{Server: "quic://dns.adguard-dns.com", Proxy: "socks5://127.0.0.1:1080"},
+			wantErr:   true,
+		},
+		{
+			name:      "proxy with DoH over HTTP/3",
+			benchmark: Benchmark{Server: "https://1.1.1.1", DohProtocol: HTTP3Proto, Proxy: "socks5://127.0.0.1:1080"},
+			wantErr:   true,
+		},
+		{
+			name:      "unsupported proxy scheme",
+			benchmark: Benchmark{Server: "8.8.8.8", Proxy: "https://127.0.0.1:3128"},
+			wantErr:   true,
+		},
+		{
+			name:       "PROXY protocol with DoT",
+			benchmark:  Benchmark{Server: "8.8.8.8", DOT: true, ProxyProtocol: ProxyProtocolV2, ProxyProtocolSources: []string{"192.0.2.0/24", "2001:db8::1"}},
+			wantServer: "8.8.8.8:853",
+		},
+		{
+			name:      "PROXY protocol with plain DNS over UDP",
+			benchmark: Benchmark{Server: "8.8.8.8", ProxyProtocol: ProxyProtocolV2, ProxyProtocolSources: []string{"192.0.2.1"}},
+			wantErr:   true,
+		},
+		{
+			name:      "PROXY protocol without sources",
+			benchmark: Benchmark{Server: "8.8.8.8", TCP: true, ProxyProtocol: ProxyProtocolV1},
+			wantErr:   true,
+		},
+		{
+			name:      "PROXY protocol with invalid source",
+			benchmark: Benchmark{Server: "8.8.8.8", TCP: true, ProxyProtocol: ProxyProtocolV1, ProxyProtocolSources: []string{"192.0.2.0/33"}},
+			wantErr:   true,
+		},
+		{
+			name:      "unsupported PROXY protocol version",
+			benchmark: Benchmark{Server: "8.8.8.8", TCP: true, ProxyProtocol: "v3", ProxyProtocolSources: []string{"192.0.2.1"}},
+			wantErr:   true,
+		},
+		{
+			name:       "source addresses and port range",
+			benchmark:  Benchmark{Server: "8.8.8.8", SourceAddresses: []string{"127.0.0.1", "::1"}, SourcePortRange: "40000-40100"},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:      "invalid source address",
+			benchmark: Benchmark{Server: "8.8.8.8", SourceAddresses: []string{"127.0.0.300"}},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid source port range",
+			benchmark: Benchmark{Server: "8.8.8.8", SourcePortRange: "40100-40000"},
+			wantErr:   true,
+		},
+		{
+			name:      "non-existing source interface",
+			benchmark: Benchmark{Server: "8.8.8.8", SourceInterface: "nonexisting0"},
+			wantErr:   true,
+		},
+		{
+			name:      "source address with DoQ",
+			benchmark: Benchmark{Server: "quic://dns.adguard-dns.com", SourceAddresses: []string{"127.0.0.1"}},
+			wantErr:   true,
+		},
+		{
+			name:       "socket options",
+			benchmark:  Benchmark{Server: "8.8.8.8", TCP: true, IPFamily: IPv6Family},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:      "DSCP out of range",
+			benchmark: Benchmark{Server: "8.8.8.8", DSCP: 64},
+			wantErr:   true,
+		},
+		{
+			name:      "negative receive buffer size",
+			benchmark: Benchmark{Server: "8.8.8.8", ReceiveBufferSize: -1},
+			wantErr:   true,
+		},
+		{
+			name:      "TCP Fast Open with DoQ",
+			benchmark: Benchmark{Server: "quic://dns.adguard-dns.com", TCPFastOpen: true},
+			wantErr:   true,
+		},
+		{
+			name:      "unsupported IP family",
+			benchmark: Benchmark{Server: "8.8.8.8", IPFamily: "ipv5"},
+			wantErr:   true,
+		},
+		{
+			name:       "IP family with SOCKS5 proxy",
+			benchmark:  Benchmark{Server: "8.8.8.8", IPFamily: IPv4Family, Proxy: "socks5://127.0.0.1"},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:      "IP family with SOCKS5H proxy",
+			benchmark: Benchmark{Server: "8.8.8.8", IPFamily: IPv4Family, Proxy: "socks5h://127.0.0.1"},
+			wantErr:   true,
+		},
+		{
+			name:       "DSO with DoT",
+			benchmark:  Benchmark{Server: "8.8.8.8", DOT: true, DSO: true},
+			wantServer: "8.8.8.8:853",
+		},
+		{
+			name:      "DSO over UDP",
+			benchmark: Benchmark{Server: "8.8.8.8", DSO: true},
+			wantErr:   true,
+		},
+		{
+			name:      "DSO with pipeline",
+			benchmark: Benchmark{Server: "8.8.8.8", TCP: true, DSO: true, Pipeline: 4},
+			wantErr:   true,
+		},
+		{
+			name:      "negative DSO inactivity timeout",
+			benchmark: Benchmark{Server: "8.8.8.8", TCP: true, DSO: true, DSOInactivityTimeout: -time.Second},
+			wantErr:   true,
+		},
+		{
+			name:       "TCP keepalive with idle probe",
+			benchmark:  Benchmark{Server: "8.8.8.8", TCP: true, TCPKeepalive: true, IdleProbe: time.Second},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:      "TCP keepalive over UDP",
+			benchmark: Benchmark{Server: "8.8.8.8", TCPKeepalive: true},
+			wantErr:   true,
+		},
+		{
+			name:      "TCP keepalive with DSO",
+			benchmark: Benchmark{Server: "8.8.8.8", TCP: true, TCPKeepalive: true, DSO: true},
+			wantErr:   true,
+		},
+		{
+			name:      "idle probe longer than maximum",
+			benchmark: Benchmark{Server: "8.8.8.8", TCP: true, IdleProbe: time.Minute, IdleProbeMax: time.Second},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid trust anchor",
+			benchmark: Benchmark{Server: "8.8.8.8", DNSSECValidation: true, TrustAnchors: []string{"example.org. IN A 127.0.0.1"}},
+			wantErr:   true,
+		},
+		{
+			name:      "missing trust anchor file",
+			benchmark: Benchmark{Server: "8.8.8.8", DNSSECValidation: true, TrustAnchorFile: "nonexisting"},
+			wantErr:   true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
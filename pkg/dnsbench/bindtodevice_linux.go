@@ -0,0 +1,25 @@
+//go:build linux
+
+package dnsbench
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const bindToDeviceSupported = true
+
+// bindToDeviceControl returns control function, which binds the socket to the network interface using SO_BINDTODEVICE.
+func bindToDeviceControl(device string) func(string, string, syscall.RawConn) error {
+	return func(_, _ string, c syscall.RawConn) error {
+		var sockErr error
+		err := c.Control(func(fd uintptr) {
+			sockErr = unix.BindToDevice(int(fd), device)
+		})
+		if err != nil {
+			return err
+		}
+		return sockErr
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package dnsbench
+
+import "syscall"
+
+const bindToDeviceSupported = false
+
+// bindToDeviceControl is no-op on platforms other than Linux, binding to the network interface is rejected when the benchmark is initialized.
+func bindToDeviceControl(_ string) func(string, string, syscall.RawConn) error {
+	return func(_, _ string, _ syscall.RawConn) error {
+		return nil
+	}
+}
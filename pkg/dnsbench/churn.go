@@ -0,0 +1,52 @@
+package dnsbench
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+)
+
+// connFactory creates new connection (client) to the server, the returned queryFunc sends the queries over the connection
+// and the returned function closes the connection.
+type connFactory func() (queryFunc, func())
+
+// churnQuery maintains single connection to the server created by the connFactory, the connection is closed and a new one is created
+// when Benchmark.QperConn queries are sent or when the new connection is permitted by Benchmark.ConnRate.
+// It is used for DoH and DoQ, plain DNS and DoT connections are maintained by connQuery.
+type churnQuery struct {
+	b         *Benchmark
+	newConn   connFactory
+	query     queryFunc
+	closeConn func()
+	i         int64
+}
+
+func (b *Benchmark) newChurnQuery(newConn connFactory) *churnQuery {
+	return &churnQuery{b: b, newConn: newConn}
+}
+
+// send is queryFunc sending the DNS query over the maintained connection.
+func (c *churnQuery) send(ctx context.Context, server string, msg *dns.Msg) (*dns.Msg, error) {
+	if c.query != nil && c.b.rotateConn(c.i) {
+		c.closeConn()
+		c.query = nil
+	}
+	c.i++
+	if c.query == nil {
+		c.query, c.closeConn = c.newConn()
+	}
+	return c.query(ctx, server, msg)
+}
+
+// churn returns true if the connections are periodically closed and created again.
+func (b *Benchmark) churn() bool {
+	return b.QperConn > 0 || b.ConnRate > 0
+}
+
+// rotateConn returns true if the connection, which already sent sent queries, should be closed and replaced by a new one.
+func (b *Benchmark) rotateConn(sent int64) bool {
+	if b.QperConn > 0 && sent%b.QperConn == 0 {
+		return true
+	}
+	return b.connRate != nil && b.connRate.tryReserve()
+}
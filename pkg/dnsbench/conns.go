@@ -0,0 +1,172 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/quic-go/quic-go"
+)
+
+// ConnStats represents the lifecycle of the connections opened by the benchmark.
+type ConnStats struct {
+	// Opened is counter of the connections opened by the benchmark.
+	Opened int64
+	// Reused is counter of the queries sent over already established connection.
+	Reused int64
+	// ClosedByServer is counter of the connections closed by the server, including the connections reset by the server.
+	ClosedByServer int64
+	// ResetOnError is counter of the connections closed because of other errors, for example when the response timed out.
+	ResetOnError int64
+	// FirstQuery is histogram of latencies of the queries, which opened new connection.
+	FirstQuery *hdrhistogram.Histogram
+	// ReusedQuery is histogram of latencies of the queries sent over already established connection.
+	ReusedQuery *hdrhistogram.Histogram
+}
+
+// connTracker records the connections closed by the server or because of errors into ConnStats of single worker.
+// The connections may be closed asynchronously, so nothing is recorded after the worker is finished.
+type connTracker struct {
+	mu      sync.Mutex
+	stats   *ConnStats
+	stopped bool
+}
+
+type connTrackerKey struct{}
+
+// withConnTracker returns the context, in which the connections closed by the server or because of errors are recorded into the tracker.
+func withConnTracker(ctx context.Context, conns *connTracker) context.Context {
+	return context.WithValue(ctx, connTrackerKey{}, conns)
+}
+
+func connTrackerFrom(ctx context.Context) *connTracker {
+	conns, _ := ctx.Value(connTrackerKey{}).(*connTracker)
+	return conns
+}
+
+// closed records the connection closed because of the error, nil tracker records nothing.
+func (t *connTracker) closed(err error) {
+	if t == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return
+	}
+	if isServerClose(err) {
+		t.stats.ClosedByServer++
+	} else {
+		t.stats.ResetOnError++
+	}
+}
+
+func (t *connTracker) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+}
+
+// isServerClose returns true if the error means that the connection was closed by the server.
+func isServerClose(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var appErr *quic.ApplicationError
+	if errors.As(err, &appErr) {
+		return appErr.Remote
+	}
+	var transportErr *quic.TransportError
+	if errors.As(err, &transportErr) {
+		return transportErr.Remote
+	}
+	var resetErr *quic.StatelessResetError
+	return errors.As(err, &resetErr)
+}
+
+func (rs *ResultStats) recordConn(trace *phaseTrace, err error, duration time.Duration) {
+	if rs.Conns == nil {
+		return
+	}
+	switch {
+	case !trace.newConn:
+		rs.Conns.Reused++
+		if err == nil {
+			rs.Conns.ReusedQuery.RecordValue(duration.Nanoseconds())
+		}
+	case !trace.connected.IsZero():
+		rs.Conns.Opened++
+		if err == nil {
+			rs.Conns.FirstQuery.RecordValue(duration.Nanoseconds())
+		}
+	}
+}
+
+// trackedConn records the connection closed by the server or because of I/O error, closing the connection by the client is not recorded.
+// It is used for the connections of DoH over HTTP/1.1 and HTTP/2, which are managed by the HTTP transports.
+type trackedConn struct {
+	net.Conn
+	conns *connTracker
+	once  sync.Once
+}
+
+func trackConn(ctx context.Context, conn net.Conn) net.Conn {
+	conns := connTrackerFrom(ctx)
+	if conns == nil {
+		return conn
+	}
+	return &trackedConn{Conn: conn, conns: conns}
+}
+
+func (c *trackedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if err != nil {
+		c.failed(err)
+	}
+	return n, err
+}
+
+func (c *trackedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if err != nil {
+		c.failed(err)
+	}
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {})
+	return c.Conn.Close()
+}
+
+func (c *trackedConn) failed(err error) {
+	if errors.Is(err, net.ErrClosed) {
+		return
+	}
+	c.once.Do(func() {
+		c.conns.closed(err)
+	})
+}
+
+// trackQUICConn records the QUIC connection closed by the server or because of error, once the connection is closed.
+func trackQUICConn(ctx context.Context, conn quic.Connection) {
+	conns := connTrackerFrom(ctx)
+	if conns == nil {
+		return
+	}
+	go func() {
+		<-conn.Context().Done()
+		err := context.Cause(conn.Context())
+		var appErr *quic.ApplicationError
+		if errors.As(err, &appErr) && !appErr.Remote || errors.Is(err, quic.Err0RTTRejected) {
+			// connection closed by the client, or replaced by the connection retrying the rejected 0-RTT data
+			return
+		}
+		conns.closed(err)
+	}()
+}
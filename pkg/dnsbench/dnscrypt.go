@@ -0,0 +1,405 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+	// nolint:staticcheck
+	"golang.org/x/crypto/poly1305"
+)
+
+const (
+	// dnscryptStampPrefix is the prefix of DNS stamps, see https://dnscrypt.info/stamps-specifications.
+	dnscryptStampPrefix = "sdns://"
+	// dnscryptStampProtocol is identifier of DNSCrypt protocol in DNS stamps.
+	dnscryptStampProtocol = 0x01
+	// dnscryptDefaultPort is the port used when the port of DNSCrypt server is not provided, the same as in DNS stamps.
+	dnscryptDefaultPort = "443"
+
+	dnscryptCertMagic     = "DNSC"
+	dnscryptResolverMagic = "r6fnvWj8"
+	// dnscryptCertSize is the size of the certificate without extensions.
+	dnscryptCertSize = 124
+
+	// dnscryptXSalsa20Poly1305 and dnscryptXChaCha20Poly1305 are the supported encryption systems of the certificates.
+	dnscryptXSalsa20Poly1305  uint16 = 0x0001
+	dnscryptXChaCha20Poly1305 uint16 = 0x0002
+
+	dnscryptHalfNonceSize = 12
+	// dnscryptMinQueryLen is the minimum length of padded query sent over UDP, which limits the amplification by the resolvers.
+	dnscryptMinQueryLen = 256
+	// dnscryptPadBlock is the block size the queries are padded to.
+	dnscryptPadBlock = 64
+)
+
+// dnscryptCert is the resolver certificate of DNSCrypt v2 protocol (https://dnscrypt.info/protocol), which provides
+// the public key of the resolver and the encryption system used for the queries.
+type dnscryptCert struct {
+	esVersion   uint16
+	resolverPK  [32]byte
+	clientMagic [8]byte
+	serial      uint32
+	notBefore   time.Time
+	notAfter    time.Time
+}
+
+// initDNSCrypt parses the provider name and the public key of DNSCrypt server either from DNS stamp configured in Benchmark.Server
+// or from Benchmark.DNSCryptProviderName and Benchmark.DNSCryptPublicKey.
+func (b *Benchmark) initDNSCrypt() error {
+	if b.DOT {
+		return errors.New("DNSCrypt cannot be combined with --dot, DNSCrypt queries are sent over UDP or TCP")
+	}
+	if strings.HasPrefix(b.Server, dnscryptStampPrefix) {
+		addr, providerName, pk, err := parseDNSCryptStamp(b.Server)
+		if err != nil {
+			return err
+		}
+		b.Server, b.dnscryptProvider, b.dnscryptPK = addr, providerName, pk
+		return nil
+	}
+	if len(b.DNSCryptPublicKey) == 0 {
+		return errors.New("--dnscrypt-public-key must be specified together with --dnscrypt-provider-name")
+	}
+	pk, err := hex.DecodeString(strings.ReplaceAll(b.DNSCryptPublicKey, ":", ""))
+	if err != nil || len(pk) != ed25519.PublicKeySize {
+		return fmt.Errorf("--dnscrypt-public-key '%s' is not hexadecimal Ed25519 public key", b.DNSCryptPublicKey)
+	}
+	b.dnscryptProvider = dns.Fqdn(b.DNSCryptProviderName)
+	b.dnscryptPK = pk
+	return nil
+}
+
+// parseDNSCryptStamp parses the address, provider name and public key of DNSCrypt server from DNS stamp.
+func parseDNSCryptStamp(stamp string) (addr, providerName string, pk ed25519.PublicKey, err error) {
+	bin, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimPrefix(stamp, dnscryptStampPrefix), "="))
+	if err != nil {
+		return "", "", nil, fmt.Errorf("'%s' is not valid DNS stamp: %w", stamp, err)
+	}
+	// protocol identifier followed by 8 bytes of properties
+	if len(bin) < 9 || bin[0] != dnscryptStampProtocol {
+		return "", "", nil, fmt.Errorf("'%s' is not DNSCrypt stamp", stamp)
+	}
+	rest := bin[9:]
+	var fields [3][]byte
+	for i := range fields {
+		if len(rest) == 0 || len(rest) < 1+int(rest[0]) {
+			return "", "", nil, fmt.Errorf("'%s' is not valid DNSCrypt stamp", stamp)
+		}
+		fields[i], rest = rest[1:1+int(rest[0])], rest[1+int(rest[0]):]
+	}
+	if len(fields[0]) == 0 || len(fields[1]) != ed25519.PublicKeySize || len(fields[2]) == 0 {
+		return "", "", nil, fmt.Errorf("'%s' is not valid DNSCrypt stamp", stamp)
+	}
+	addr = string(fields[0])
+	if strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, "]") {
+		// IPv6 address without port
+		addr = addr[1 : len(addr)-1]
+	}
+	return addr, dns.Fqdn(string(fields[2])), fields[1], nil
+}
+
+// fetchDNSCryptCert fetches the certificates of DNSCrypt provider and selects the valid certificate with the highest serial number.
+// The certificate is fetched once and used for all the queries of the benchmark.
+func (b *Benchmark) fetchDNSCryptCert(ctx context.Context) error {
+	req := dns.Msg{}
+	req.SetQuestion(b.dnscryptProvider, dns.TypeTXT)
+	dnsClient := b.getDNSClient()
+	co, err := b.dial(ctx, dnsClient)
+	if err != nil {
+		return fmt.Errorf("failed to fetch DNSCrypt certificate: %w", err)
+	}
+	defer co.Close()
+	resp, _, err := dnsClient.ExchangeWithConnContext(ctx, &req, co)
+	if err != nil {
+		return fmt.Errorf("failed to fetch DNSCrypt certificate: %w", err)
+	}
+
+	now := time.Now()
+	for _, rr := range resp.Answer {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {
+			continue
+		}
+		cert, err := parseDNSCryptCert(txtData(txt), b.dnscryptPK)
+		if err != nil || now.Before(cert.notBefore) || now.After(cert.notAfter) {
+			continue
+		}
+		if best := b.dnscryptCert; best == nil || cert.serial > best.serial || cert.serial == best.serial && cert.esVersion > best.esVersion {
+			b.dnscryptCert = cert
+		}
+	}
+	if b.dnscryptCert == nil {
+		return fmt.Errorf("no valid DNSCrypt certificate of provider '%s' signed by the configured public key", b.dnscryptProvider)
+	}
+	return nil
+}
+
+// txtData returns the strings of TXT record concatenated in wire format, the strings of dns.TXT are escaped.
+func txtData(txt *dns.TXT) []byte {
+	buf := make([]byte, dns.Len(txt))
+	off, err := dns.PackRR(txt, buf, 0, nil, false)
+	if err != nil {
+		return nil
+	}
+	var data []byte
+	for rdata := buf[off-int(txt.Hdr.Rdlength) : off]; len(rdata) > 0 && len(rdata) > int(rdata[0]); rdata = rdata[1+int(rdata[0]):] {
+		data = append(data, rdata[1:1+int(rdata[0])]...)
+	}
+	return data
+}
+
+func parseDNSCryptCert(data []byte, pk ed25519.PublicKey) (*dnscryptCert, error) {
+	if len(data) < dnscryptCertSize || string(data[:4]) != dnscryptCertMagic {
+		return nil, errors.New("not DNSCrypt certificate")
+	}
+	cert := dnscryptCert{esVersion: binary.BigEndian.Uint16(data[4:6])}
+	if cert.esVersion != dnscryptXSalsa20Poly1305 && cert.esVersion != dnscryptXChaCha20Poly1305 {
+		return nil, fmt.Errorf("unsupported encryption system %d", cert.esVersion)
+	}
+	// the signature covers the rest of the certificate including extensions
+	if !ed25519.Verify(pk, data[72:], data[8:72]) {
+		return nil, errors.New("invalid signature of DNSCrypt certificate")
+	}
+	copy(cert.resolverPK[:], data[72:104])
+	copy(cert.clientMagic[:], data[104:112])
+	cert.serial = binary.BigEndian.Uint32(data[112:116])
+	cert.notBefore = time.Unix(int64(binary.BigEndian.Uint32(data[116:120])), 0)
+	cert.notAfter = time.Unix(int64(binary.BigEndian.Uint32(data[120:124])), 0)
+	return &cert, nil
+}
+
+// dnscryptSharedKey computes the key shared by the client and the resolver for the encryption system.
+func dnscryptSharedKey(esVersion uint16, secretKey, publicKey *[32]byte) ([32]byte, error) {
+	var key [32]byte
+	if esVersion == dnscryptXSalsa20Poly1305 {
+		box.Precompute(&key, publicKey, secretKey)
+		return key, nil
+	}
+	dh, err := curve25519.X25519(secretKey[:], publicKey[:])
+	if err != nil {
+		return key, err
+	}
+	subKey, err := chacha20.HChaCha20(dh, make([]byte, 16))
+	if err != nil {
+		return key, err
+	}
+	copy(key[:], subKey)
+	return key, nil
+}
+
+// dnscryptSeal encrypts and authenticates the message, the authentication tag is prepended to the ciphertext.
+// XChaCha20Poly1305 uses the same construction as NaCl secretbox, which is different from the AEAD construction of RFC 8439.
+func dnscryptSeal(esVersion uint16, key *[32]byte, nonce *[24]byte, msg []byte) []byte {
+	if esVersion == dnscryptXSalsa20Poly1305 {
+		return secretbox.Seal(nil, msg, nonce, key)
+	}
+	out := make([]byte, poly1305.TagSize+len(msg))
+	polyKey := xchachaXOR(key, nonce, out[poly1305.TagSize:], msg)
+	var tag [poly1305.TagSize]byte
+	poly1305.Sum(&tag, out[poly1305.TagSize:], &polyKey)
+	copy(out, tag[:])
+	return out
+}
+
+// dnscryptOpen authenticates and decrypts the message sealed by dnscryptSeal.
+func dnscryptOpen(esVersion uint16, key *[32]byte, nonce *[24]byte, sealed []byte) ([]byte, error) {
+	if esVersion == dnscryptXSalsa20Poly1305 {
+		msg, ok := secretbox.Open(nil, sealed, nonce, key)
+		if !ok {
+			return nil, errors.New("failed to decrypt DNSCrypt response")
+		}
+		return msg, nil
+	}
+	if len(sealed) < poly1305.TagSize {
+		return nil, errors.New("failed to decrypt DNSCrypt response")
+	}
+	var tag [poly1305.TagSize]byte
+	copy(tag[:], sealed)
+	ciphertext := sealed[poly1305.TagSize:]
+	msg := make([]byte, len(ciphertext))
+	polyKey := xchachaXOR(key, nonce, msg, ciphertext)
+	if !poly1305.Verify(&tag, ciphertext, &polyKey) {
+		return nil, errors.New("failed to decrypt DNSCrypt response")
+	}
+	return msg, nil
+}
+
+// xchachaXOR XORs src with XChaCha20 key stream into dst, the first 32 bytes of the key stream are returned as Poly1305 key.
+func xchachaXOR(key *[32]byte, nonce *[24]byte, dst, src []byte) [32]byte {
+	// the errors are returned only for invalid key and nonce sizes
+	c, _ := chacha20.NewUnauthenticatedCipher(key[:], nonce[:])
+	var polyKey [32]byte
+	c.XORKeyStream(polyKey[:], polyKey[:])
+	// the message is encrypted by the key stream following the Poly1305 key the same as in secretbox
+	c.XORKeyStream(dst, src)
+	return polyKey
+}
+
+// dnscryptPad pads the query using ISO/IEC 7816-4 padding to the multiple of the block size and at least to minLen.
+func dnscryptPad(query []byte, minLen int) []byte {
+	n := (len(query) + 1 + dnscryptPadBlock - 1) / dnscryptPadBlock * dnscryptPadBlock
+	if n < minLen {
+		n = minLen
+	}
+	padded := make([]byte, n)
+	copy(padded, query)
+	padded[len(query)] = 0x80
+	return padded
+}
+
+// dnscryptUnpad removes ISO/IEC 7816-4 padding, the trailing zero bytes and the preceding 0x80 byte.
+func dnscryptUnpad(padded []byte) ([]byte, error) {
+	i := len(padded) - 1
+	for i >= 0 && padded[i] == 0 {
+		i--
+	}
+	if i < 0 || padded[i] != 0x80 {
+		return nil, errors.New("invalid padding of DNSCrypt response")
+	}
+	return padded[:i], nil
+}
+
+// dnscryptQuery sends the DNSCrypt queries encrypted for the resolver certificate over the connection maintained by connQuery.
+// The key pair of the client is generated once for the dnscryptQuery.
+type dnscryptQuery struct {
+	*connQuery
+	cert      *dnscryptCert
+	publicKey [32]byte
+	sharedKey *[32]byte
+	buf       []byte
+}
+
+func (b *Benchmark) newDNSCryptQuery(dnsClient *dns.Client) *dnscryptQuery {
+	return &dnscryptQuery{connQuery: b.newConnQuery(dnsClient), cert: b.dnscryptCert}
+}
+
+// query is queryFunc sending the encrypted DNS query over the maintained connection.
+func (c *dnscryptQuery) query(ctx context.Context, _ string, msg *dns.Msg) (*dns.Msg, error) {
+	if c.sharedKey == nil {
+		publicKey, secretKey, err := box.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		sharedKey, err := dnscryptSharedKey(c.cert.esVersion, secretKey, &c.cert.resolverPK)
+		if err != nil {
+			return nil, err
+		}
+		c.publicKey, c.sharedKey = *publicKey, &sharedKey
+	}
+	if err := c.conn(ctx); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	r, err := c.exchange(ctx, msg, start)
+	if err != nil {
+		connTrackerFrom(ctx).closed(err)
+		c.close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (c *dnscryptQuery) exchange(ctx context.Context, msg *dns.Msg, start time.Time) (*dns.Msg, error) {
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:dnscryptHalfNonceSize]); err != nil {
+		return nil, err
+	}
+	udp := c.dnsClient.Net == UDPTransport
+	minLen := 0
+	if udp {
+		minLen = dnscryptMinQueryLen
+	}
+
+	query := make([]byte, 0, 2+len(c.cert.clientMagic)+len(c.publicKey)+dnscryptHalfNonceSize+poly1305.TagSize+len(packed)+dnscryptMinQueryLen)
+	if !udp {
+		// the length is filled once the query is encrypted
+		query = append(query, 0, 0)
+	}
+	query = append(query, c.cert.clientMagic[:]...)
+	query = append(query, c.publicKey[:]...)
+	query = append(query, nonce[:dnscryptHalfNonceSize]...)
+	query = append(query, dnscryptSeal(c.cert.esVersion, c.sharedKey, &nonce, dnscryptPad(packed, minLen))...)
+	if !udp {
+		binary.BigEndian.PutUint16(query, uint16(len(query)-2))
+	}
+
+	conn := c.co.Conn
+	conn.SetWriteDeadline(deadline(ctx, c.b.WriteTimeout))
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(deadline(ctx, c.b.ReadTimeout))
+	if c.buf == nil {
+		c.buf = make([]byte, dns.MaxMsgSize)
+	}
+	for {
+		resp, err := c.read(conn, udp)
+		if err != nil {
+			return nil, err
+		}
+		if trace := phaseTraceFrom(ctx); trace != nil {
+			trace.firstByte(start)
+		}
+		headerSize := len(dnscryptResolverMagic) + len(nonce)
+		if len(resp) < headerSize || string(resp[:len(dnscryptResolverMagic)]) != dnscryptResolverMagic ||
+			!bytes.Equal(resp[len(dnscryptResolverMagic):len(dnscryptResolverMagic)+dnscryptHalfNonceSize], nonce[:dnscryptHalfNonceSize]) {
+			if udp {
+				// late response to the previous query sent over the same socket
+				continue
+			}
+			return nil, errors.New("unexpected DNSCrypt response")
+		}
+		copy(nonce[:], resp[len(dnscryptResolverMagic):headerSize])
+		padded, err := dnscryptOpen(c.cert.esVersion, c.sharedKey, &nonce, resp[headerSize:])
+		if err != nil {
+			return nil, err
+		}
+		unpadded, err := dnscryptUnpad(padded)
+		if err != nil {
+			return nil, err
+		}
+		r := new(dns.Msg)
+		if err := r.Unpack(unpadded); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+}
+
+func (c *dnscryptQuery) read(conn net.Conn, udp bool) ([]byte, error) {
+	if udp {
+		n, err := conn.Read(c.buf)
+		if err != nil {
+			return nil, err
+		}
+		return c.buf[:n], nil
+	}
+	if _, err := io.ReadFull(conn, c.buf[:2]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint16(c.buf))
+	if _, err := io.ReadFull(conn, c.buf[:n]); err != nil {
+		return nil, err
+	}
+	return c.buf[:n], nil
+}
@@ -0,0 +1,286 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+)
+
+const testDNSCryptProvider = "2.dnscrypt-cert.example.org."
+
+// dnscryptTestServer is DNSCrypt server answering the certificate queries and A queries over UDP and TCP on the same address.
+type dnscryptTestServer struct {
+	esVersion   uint16
+	providerSK  ed25519.PrivateKey
+	resolverSK  [32]byte
+	resolverPK  [32]byte
+	clientMagic [8]byte
+	udp         net.PacketConn
+	tcp         net.Listener
+}
+
+func newDNSCryptTestServer(t *testing.T, esVersion uint16) *dnscryptTestServer {
+	_, providerSK, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	resolverPK, resolverSK, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	s := &dnscryptTestServer{esVersion: esVersion, providerSK: providerSK, resolverSK: *resolverSK, resolverPK: *resolverPK}
+	copy(s.clientMagic[:], resolverPK[:8])
+
+	s.udp, err = net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s.tcp, err = net.Listen("tcp", s.udp.LocalAddr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		s.udp.Close()
+		s.tcp.Close()
+	})
+	go s.serveUDP()
+	go s.serveTCP()
+	return s
+}
+
+// stamp returns DNS stamp of the server.
+func (s *dnscryptTestServer) stamp() string {
+	addr := s.udp.LocalAddr().String()
+	bin := append([]byte{dnscryptStampProtocol}, make([]byte, 8)...)
+	bin = append(append(bin, byte(len(addr))), addr...)
+	bin = append(append(bin, ed25519.PublicKeySize), s.providerSK.Public().(ed25519.PublicKey)...)
+	bin = append(append(bin, byte(len(testDNSCryptProvider))), testDNSCryptProvider...)
+	return dnscryptStampPrefix + base64.RawURLEncoding.EncodeToString(bin)
+}
+
+func (s *dnscryptTestServer) cert(serial uint32, notBefore, notAfter time.Time) string {
+	data := []byte(dnscryptCertMagic)
+	data = binary.BigEndian.AppendUint16(data, s.esVersion)
+	data = append(data, 0, 0)
+	signed := append(s.resolverPK[:], s.clientMagic[:]...)
+	signed = binary.BigEndian.AppendUint32(signed, serial)
+	signed = binary.BigEndian.AppendUint32(signed, uint32(notBefore.Unix()))
+	signed = binary.BigEndian.AppendUint32(signed, uint32(notAfter.Unix()))
+	data = append(append(data, ed25519.Sign(s.providerSK, signed)...), signed...)
+
+	var escaped strings.Builder
+	for _, b := range data {
+		fmt.Fprintf(&escaped, "\\%03d", b)
+	}
+	return escaped.String()
+}
+
+func (s *dnscryptTestServer) handle(query []byte) []byte {
+	if len(query) > 52 && string(query[:8]) == string(s.clientMagic[:]) {
+		return s.handleEncrypted(query)
+	}
+	req := dns.Msg{}
+	if err := req.Unpack(query); err != nil || req.Question[0].Name != testDNSCryptProvider {
+		return nil
+	}
+	resp := dns.Msg{}
+	resp.SetReply(&req)
+	now := time.Now()
+	hdr := dns.RR_Header{Name: testDNSCryptProvider, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60}
+	resp.Answer = []dns.RR{
+		&dns.TXT{Hdr: hdr, Txt: []string{s.cert(1, now.Add(-time.Hour), now.Add(time.Hour))}},
+		// expired certificate with higher serial is ignored
+		&dns.TXT{Hdr: hdr, Txt: []string{s.cert(2, now.Add(-2*time.Hour), now.Add(-time.Hour))}},
+	}
+	packed, _ := resp.Pack()
+	return packed
+}
+
+func (s *dnscryptTestServer) handleEncrypted(query []byte) []byte {
+	var clientPK [32]byte
+	copy(clientPK[:], query[8:40])
+	var nonce [24]byte
+	copy(nonce[:], query[40:52])
+	key, err := dnscryptSharedKey(s.esVersion, &s.resolverSK, &clientPK)
+	if err != nil {
+		return nil
+	}
+	padded, err := dnscryptOpen(s.esVersion, &key, &nonce, query[52:])
+	if err != nil {
+		return nil
+	}
+	unpadded, err := dnscryptUnpad(padded)
+	if err != nil {
+		return nil
+	}
+	req := dns.Msg{}
+	if err := req.Unpack(unpadded); err != nil {
+		return nil
+	}
+	resp := dns.Msg{}
+	resp.SetReply(&req)
+	resp.Answer = append(resp.Answer, &dns.A{Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.IPv4(127, 0, 0, 1)})
+	packed, _ := resp.Pack()
+
+	rand.Read(nonce[dnscryptHalfNonceSize:])
+	out := append([]byte(dnscryptResolverMagic), nonce[:]...)
+	return append(out, dnscryptSeal(s.esVersion, &key, &nonce, dnscryptPad(packed, 0))...)
+}
+
+func (s *dnscryptTestServer) serveUDP() {
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp := s.handle(buf[:n]); resp != nil {
+			s.udp.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (s *dnscryptTestServer) serveTCP() {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				var length uint16
+				if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+					return
+				}
+				query := make([]byte, length)
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				resp := s.handle(query)
+				if resp == nil {
+					return
+				}
+				conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+			}
+		}()
+	}
+}
+
+func Test_dnscryptUnpad(t *testing.T) {
+	tests := []struct {
+		name    string
+		padded  []byte
+		want    []byte
+		wantErr bool
+	}{
+		{name: "padded", padded: []byte{0x01, 0x05, 0x80, 0, 0, 0}, want: []byte{0x01, 0x05}},
+		{name: "last byte is UTF-8 lead byte", padded: []byte{0x01, 0xC8, 0x80, 0, 0, 0}, want: []byte{0x01, 0xC8}},
+		{name: "padding marker only", padded: []byte{0x80}, want: []byte{}},
+		{name: "missing padding marker", padded: []byte{0x01, 0x05, 0, 0}, wantErr: true},
+		{name: "zeros only", padded: []byte{0, 0}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dnscryptUnpad(tt.padded)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBenchmark_Run_dnscrypt(t *testing.T) {
+	tests := []struct {
+		name        string
+		esVersion   uint16
+		tcp         bool
+		wantNetwork string
+	}{
+		{
+			name:        "XSalsa20Poly1305 over UDP",
+			esVersion:   dnscryptXSalsa20Poly1305,
+			wantNetwork: "dnscrypt/udp",
+		},
+		{
+			name:        "XChaCha20Poly1305 over UDP",
+			esVersion:   dnscryptXChaCha20Poly1305,
+			wantNetwork: "dnscrypt/udp",
+		},
+		{
+			name:        "XChaCha20Poly1305 over TCP",
+			esVersion:   dnscryptXChaCha20Poly1305,
+			tcp:         true,
+			wantNetwork: "dnscrypt/tcp",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newDNSCryptTestServer(t, tt.esVersion)
+
+			bench := Benchmark{
+				Server:         s.stamp(),
+				TCP:            tt.tcp,
+				Queries:        []string{"example.org"},
+				Types:          []string{"A"},
+				Concurrency:    2,
+				Count:          3,
+				Probability:    1,
+				WriteTimeout:   1 * time.Second,
+				ReadTimeout:    3 * time.Second,
+				ConnectTimeout: 1 * time.Second,
+				RequestTimeout: 5 * time.Second,
+				Rcodes:         true,
+				Recurse:        true,
+				Writer:         io.Discard,
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			assert.Equal(t, tt.wantNetwork, bench.network())
+			require.NotNil(t, bench.dnscryptCert)
+			assert.EqualValues(t, 1, bench.dnscryptCert.serial, "expired certificate should not be used")
+			require.Len(t, rs, 2)
+			for _, r := range rs {
+				assert.EqualValues(t, 3, r.Counters.Total)
+				assert.EqualValues(t, 3, r.Counters.Success)
+				assert.EqualValues(t, 0, r.Counters.IOError)
+			}
+		})
+	}
+}
+
+func TestBenchmark_Run_dnscrypt_invalid_public_key(t *testing.T) {
+	s := newDNSCryptTestServer(t, dnscryptXSalsa20Poly1305)
+	otherPK, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	bench := Benchmark{
+		Server:               s.udp.LocalAddr().String(),
+		DNSCryptProviderName: testDNSCryptProvider,
+		DNSCryptPublicKey:    fmt.Sprintf("%X", []byte(otherPK)),
+		Queries:              []string{"example.org"},
+		Types:                []string{"A"},
+		Concurrency:          1,
+		Count:                1,
+		Probability:          1,
+		WriteTimeout:         1 * time.Second,
+		ReadTimeout:          3 * time.Second,
+		ConnectTimeout:       1 * time.Second,
+		RequestTimeout:       5 * time.Second,
+		Writer:               io.Discard,
+	}
+
+	_, err = bench.Run(context.Background())
+
+	require.Error(t, err, "certificate not signed by the configured public key should be rejected")
+}
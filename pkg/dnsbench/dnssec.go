@@ -0,0 +1,406 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DNSSECStatus represents result of the local DNSSEC validation of a DNS response.
+type DNSSECStatus int
+
+const (
+	// DNSSECSecure means that all signed data in the response were validated using chain of trust from the trust anchor.
+	DNSSECSecure DNSSECStatus = iota
+	// DNSSECInsecure means that the response data belong to the zone, which is not signed (no DS record in the parent zone).
+	DNSSECInsecure
+	// DNSSECBogus means that the response data should be signed, but the signatures or chain of trust are not valid.
+	DNSSECBogus
+	// DNSSECIndeterminate means that the chain of trust could not be built, for example no trust anchor covers the response
+	// or the DNSKEY and DS records could not be retrieved from the server.
+	DNSSECIndeterminate
+)
+
+// rootTrustAnchors are DS records of the root zone KSK-2017 and KSK-2024 keys, see https://data.iana.org/root-anchors/root-anchors.xml
+var rootTrustAnchors = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+type zoneKeys struct {
+	keys   []*dns.DNSKEY
+	status DNSSECStatus
+}
+
+// dnssecValidator validates DNSSEC signatures of DNS responses, DNSKEY and DS records needed for building
+// the chain of trust are queried from the benchmarked server and cached.
+type dnssecValidator struct {
+	anchors map[string][]dns.RR
+	timeout time.Duration
+
+	queryMu sync.Mutex
+	query   queryFunc
+	server  string
+	useQuic bool
+
+	mu    sync.Mutex
+	zones map[string]zoneKeys
+	apex  map[string]string
+}
+
+func newDNSSECValidator(b *Benchmark, query queryFunc) *dnssecValidator {
+	return &dnssecValidator{
+		anchors: b.trustAnchors,
+		timeout: b.RequestTimeout,
+		query:   query,
+		server:  b.Server,
+		useQuic: b.useQuic,
+		zones:   make(map[string]zoneKeys),
+		apex:    make(map[string]string),
+	}
+}
+
+// parseTrustAnchors parses DS or DNSKEY trust anchors configured in Benchmark.TrustAnchors and Benchmark.TrustAnchorFile.
+// When no trust anchors are configured, root zone trust anchors are used.
+func (b *Benchmark) parseTrustAnchors() error {
+	b.trustAnchors = make(map[string][]dns.RR)
+	add := func(rr dns.RR) error {
+		switch rr.(type) {
+		case *dns.DS, *dns.DNSKEY:
+			name := dns.CanonicalName(rr.Header().Name)
+			b.trustAnchors[name] = append(b.trustAnchors[name], rr)
+			return nil
+		default:
+			return fmt.Errorf("trust anchor '%s' is not DS or DNSKEY record", rr.String())
+		}
+	}
+
+	for _, a := range b.TrustAnchors {
+		rr, err := dns.NewRR(a)
+		if err != nil {
+			return fmt.Errorf("failed to parse trust anchor '%s': %v", a, err)
+		}
+		if rr == nil {
+			continue
+		}
+		if err := add(rr); err != nil {
+			return err
+		}
+	}
+
+	if len(b.TrustAnchorFile) != 0 {
+		f, err := os.Open(b.TrustAnchorFile)
+		if err != nil {
+			return fmt.Errorf("failed to open trust anchor file: %v", err)
+		}
+		defer f.Close()
+		zp := dns.NewZoneParser(f, ".", b.TrustAnchorFile)
+		for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+			if err := add(rr); err != nil {
+				return err
+			}
+		}
+		if err := zp.Err(); err != nil {
+			return fmt.Errorf("failed to parse trust anchor file: %v", err)
+		}
+	}
+
+	if len(b.trustAnchors) == 0 {
+		for _, a := range rootTrustAnchors {
+			rr, _ := dns.NewRR(a)
+			_ = add(rr)
+		}
+	}
+	return nil
+}
+
+// validate validates signatures of all RR sets in the answer and authority sections of the response.
+func (v *dnssecValidator) validate(ctx context.Context, resp *dns.Msg) DNSSECStatus {
+	rrsets, sigs := splitRRsets(append(append([]dns.RR{}, resp.Answer...), resp.Ns...))
+	if len(rrsets) == 0 {
+		return DNSSECIndeterminate
+	}
+
+	res := DNSSECSecure
+	for key, rrset := range rrsets {
+		var st DNSSECStatus
+		if s, ok := sigs[key]; ok {
+			st = v.verifyRRset(ctx, rrset, s)
+		} else {
+			st = v.unsignedStatus(ctx, key.name)
+		}
+		if st > res {
+			res = st
+		}
+	}
+	return res
+}
+
+func (v *dnssecValidator) verifyRRset(ctx context.Context, rrset []dns.RR, sigs []*dns.RRSIG) DNSSECStatus {
+	res := DNSSECBogus
+	for _, sig := range sigs {
+		if !signerCovers(sig, rrset[0].Header().Name) {
+			// the signature is not made by the zone, where the RR set belongs to
+			continue
+		}
+		keys := v.zoneKeys(ctx, sig.SignerName)
+		if keys.status != DNSSECSecure {
+			// signatures made by keys of insecure zone can't be validated
+			res = keys.status
+			continue
+		}
+		if verifySignature(sig, keys.keys, rrset) {
+			return DNSSECSecure
+		}
+	}
+	return res
+}
+
+// unsignedStatus decides whether unsigned data owned by name are insecure or bogus.
+// Note that the absence of DS record in the parent zone is not proven using NSEC or NSEC3 records.
+func (v *dnssecValidator) unsignedStatus(ctx context.Context, name string) DNSSECStatus {
+	zone, err := v.zoneApex(ctx, name)
+	if err != nil {
+		return DNSSECIndeterminate
+	}
+	switch st := v.zoneKeys(ctx, zone).status; st {
+	case DNSSECSecure:
+		// unsigned data in signed zone
+		return DNSSECBogus
+	default:
+		return st
+	}
+}
+
+// zoneApex finds the apex of the zone, where the name belongs to, by querying SOA record of the name.
+func (v *dnssecValidator) zoneApex(ctx context.Context, name string) (string, error) {
+	name = dns.CanonicalName(name)
+	v.mu.Lock()
+	zone, ok := v.apex[name]
+	v.mu.Unlock()
+	if ok {
+		return zone, nil
+	}
+
+	resp, err := v.exchange(ctx, name, dns.TypeSOA)
+	if err != nil {
+		return "", err
+	}
+	for _, rr := range append(append([]dns.RR{}, resp.Answer...), resp.Ns...) {
+		if soa, ok := rr.(*dns.SOA); ok {
+			zone = dns.CanonicalName(soa.Hdr.Name)
+			break
+		}
+	}
+	if len(zone) == 0 {
+		return "", errors.New("zone apex not found")
+	}
+
+	v.mu.Lock()
+	v.apex[name] = zone
+	v.mu.Unlock()
+	return zone, nil
+}
+
+// zoneKeys returns DNSKEY records of the zone validated using the chain of trust.
+func (v *dnssecValidator) zoneKeys(ctx context.Context, zone string) zoneKeys {
+	zone = dns.CanonicalName(zone)
+	v.mu.Lock()
+	cached, ok := v.zones[zone]
+	v.mu.Unlock()
+	if ok {
+		return cached
+	}
+
+	res := v.buildZoneKeys(ctx, zone)
+	if ctx.Err() == nil {
+		v.mu.Lock()
+		v.zones[zone] = res
+		v.mu.Unlock()
+	}
+	return res
+}
+
+func (v *dnssecValidator) buildZoneKeys(ctx context.Context, zone string) zoneKeys {
+	var ds []*dns.DS
+	if anchors, ok := v.anchors[zone]; ok {
+		for _, a := range anchors {
+			switch rr := a.(type) {
+			case *dns.DS:
+				ds = append(ds, rr)
+			case *dns.DNSKEY:
+				ds = append(ds, rr.ToDS(dns.SHA256))
+			}
+		}
+	} else {
+		if zone == "." {
+			return zoneKeys{status: DNSSECIndeterminate}
+		}
+		resp, err := v.exchange(ctx, zone, dns.TypeDS)
+		if err != nil {
+			return zoneKeys{status: DNSSECIndeterminate}
+		}
+		rrsets, sigs := splitRRsets(resp.Answer)
+		key := rrsetKey{name: zone, rrtype: dns.TypeDS}
+		if len(rrsets[key]) == 0 {
+			// no DS record, the parent zone might still be secure, but the delegation is insecure
+			return zoneKeys{status: v.parentStatus(ctx, zone)}
+		}
+		// DS records must be signed by the keys of some parent zone
+		var parentSigs []*dns.RRSIG
+		for _, sig := range sigs[key] {
+			signer := dns.CanonicalName(sig.SignerName)
+			if signer != zone && dns.IsSubDomain(signer, zone) {
+				parentSigs = append(parentSigs, sig)
+			}
+		}
+		if len(parentSigs) == 0 {
+			return zoneKeys{status: DNSSECBogus}
+		}
+		if st := v.verifyRRset(ctx, rrsets[key], parentSigs); st != DNSSECSecure {
+			return zoneKeys{status: st}
+		}
+		for _, rr := range rrsets[key] {
+			ds = append(ds, rr.(*dns.DS))
+		}
+	}
+
+	resp, err := v.exchange(ctx, zone, dns.TypeDNSKEY)
+	if err != nil {
+		return zoneKeys{status: DNSSECIndeterminate}
+	}
+	rrsets, sigs := splitRRsets(resp.Answer)
+	key := rrsetKey{name: zone, rrtype: dns.TypeDNSKEY}
+	var keys []*dns.DNSKEY
+	for _, rr := range rrsets[key] {
+		keys = append(keys, rr.(*dns.DNSKEY))
+	}
+
+	var anchored []*dns.DNSKEY
+	for _, k := range keys {
+		for _, d := range ds {
+			if matchesDS(k, d) {
+				anchored = append(anchored, k)
+				break
+			}
+		}
+	}
+	if len(anchored) == 0 {
+		return zoneKeys{status: DNSSECBogus}
+	}
+	for _, sig := range sigs[key] {
+		if verifySignature(sig, anchored, rrsets[key]) {
+			return zoneKeys{keys: keys, status: DNSSECSecure}
+		}
+	}
+	return zoneKeys{status: DNSSECBogus}
+}
+
+// parentStatus returns status of the delegation without DS record, the delegation is insecure,
+// unless there is no trust anchor above the zone.
+func (v *dnssecValidator) parentStatus(ctx context.Context, zone string) DNSSECStatus {
+	labels := dns.SplitDomainName(zone)
+	parent := "."
+	if len(labels) > 1 {
+		parent = dns.Fqdn(strings.Join(labels[1:], "."))
+	}
+	switch st := v.zoneKeys(ctx, parent).status; st {
+	case DNSSECSecure:
+		return DNSSECInsecure
+	default:
+		return st
+	}
+}
+
+func (v *dnssecValidator) exchange(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	req := dns.Msg{}
+	req.SetQuestion(name, qtype)
+	req.RecursionDesired = true
+	// ask for data even if the server considers them bogus, the validation is done locally
+	req.CheckingDisabled = true
+	req.SetEdns0(DefaultEdns0BufferSize, true)
+	if v.useQuic {
+		req.Id = 0
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	v.queryMu.Lock()
+	defer v.queryMu.Unlock()
+	resp, err := v.query(reqCtx, v.server, &req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("unexpected response code %s", dns.RcodeToString[resp.Rcode])
+	}
+	return resp, nil
+}
+
+type rrsetKey struct {
+	name   string
+	rrtype uint16
+}
+
+func splitRRsets(rrs []dns.RR) (map[rrsetKey][]dns.RR, map[rrsetKey][]*dns.RRSIG) {
+	rrsets := make(map[rrsetKey][]dns.RR)
+	sigs := make(map[rrsetKey][]*dns.RRSIG)
+	for _, rr := range rrs {
+		name := dns.CanonicalName(rr.Header().Name)
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			key := rrsetKey{name: name, rrtype: sig.TypeCovered}
+			sigs[key] = append(sigs[key], sig)
+			continue
+		}
+		if rr.Header().Rrtype == dns.TypeOPT {
+			continue
+		}
+		key := rrsetKey{name: name, rrtype: rr.Header().Rrtype}
+		rrsets[key] = append(rrsets[key], rr)
+	}
+	return rrsets, sigs
+}
+
+// signerCovers checks that the signer of the signature is the owner of the RR set or its ancestor and that the labels count
+// of the signature is valid for the owner (RFC 4035 section 5.3.1). When the RR set was synthesized from a wildcard,
+// the labels count is less than the number of labels of the owner and the wildcard must also belong to the signer zone.
+func signerCovers(sig *dns.RRSIG, owner string) bool {
+	signer := dns.CanonicalName(sig.SignerName)
+	if !dns.IsSubDomain(signer, dns.CanonicalName(owner)) {
+		return false
+	}
+	return int(sig.Labels) <= dns.CountLabel(owner) && int(sig.Labels) >= dns.CountLabel(signer)
+}
+
+func verifySignature(sig *dns.RRSIG, keys []*dns.DNSKEY, rrset []dns.RR) bool {
+	if !sig.ValidityPeriod(time.Now()) {
+		return false
+	}
+	for _, k := range keys {
+		if k.KeyTag() != sig.KeyTag || k.Algorithm != sig.Algorithm {
+			continue
+		}
+		if dns.CanonicalName(k.Hdr.Name) != dns.CanonicalName(sig.SignerName) {
+			continue
+		}
+		if err := sig.Verify(k, rrset); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesDS(key *dns.DNSKEY, ds *dns.DS) bool {
+	if key.KeyTag() != ds.KeyTag || key.Algorithm != ds.Algorithm {
+		return false
+	}
+	computed := key.ToDS(ds.DigestType)
+	return computed != nil && strings.EqualFold(computed.Digest, ds.Digest)
+}
@@ -0,0 +1,152 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type signedZone struct {
+	name string
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newSignedZone(t *testing.T, name string) signedZone {
+	t.Helper()
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	require.NoError(t, err)
+	return signedZone{name: name, key: key, priv: priv.(crypto.Signer)}
+}
+
+func (z signedZone) sign(t *testing.T, rrset ...dns.RR) *dns.RRSIG {
+	t.Helper()
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrset[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 3600},
+		KeyTag:     z.key.KeyTag(),
+		SignerName: z.name,
+		Algorithm:  z.key.Algorithm,
+		Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+		Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+	}
+	require.NoError(t, sig.Sign(z.priv, rrset))
+	return sig
+}
+
+func rr(s string) dns.RR {
+	r, err := dns.NewRR(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func Test_dnssecValidator_validate(t *testing.T) {
+	zone := newSignedZone(t, "example.org.")
+	subZone := newSignedZone(t, "sub.example.org.")
+	otherZone := newSignedZone(t, "example.net.")
+	answer := rr("example.org. 3600 IN A 127.0.0.1")
+	validSig := zone.sign(t, answer)
+	invalidSig := zone.sign(t, rr("example.org. 3600 IN A 127.0.0.2"))
+	otherZoneSig := otherZone.sign(t, answer)
+	wildcardAnswer := rr("www.example.org. 3600 IN A 127.0.0.1")
+	wildcardSig := zone.sign(t, rr("*.example.org. 3600 IN A 127.0.0.1"))
+	wildcardSig.Hdr.Name = wildcardAnswer.Header().Name
+	subWildcardAnswer := rr("www.sub.example.org. 3600 IN A 127.0.0.1")
+	// the wildcard of the parent zone is signed by the keys of the child zone
+	subWildcardSig := subZone.sign(t, rr("*.example.org. 3600 IN A 127.0.0.1"))
+	subWildcardSig.Hdr.Name = subWildcardAnswer.Header().Name
+	soa := rr("example.org. 3600 IN SOA ns.example.org. admin.example.org. 1 3600 600 86400 3600")
+	zones := map[string]signedZone{zone.name: zone, subZone.name: subZone, otherZone.name: otherZone}
+
+	query := func(_ context.Context, _ string, m *dns.Msg) (*dns.Msg, error) {
+		resp := new(dns.Msg)
+		resp.SetReply(m)
+		switch m.Question[0].Qtype {
+		case dns.TypeDNSKEY:
+			if z, ok := zones[m.Question[0].Name]; ok {
+				resp.Answer = []dns.RR{z.key, z.sign(t, z.key)}
+			}
+		case dns.TypeSOA:
+			resp.Answer = []dns.RR{soa, zone.sign(t, soa)}
+		}
+		return resp, nil
+	}
+
+	tests := []struct {
+		name    string
+		anchors []string
+		resp    *dns.Msg
+		want    DNSSECStatus
+	}{
+		{
+			name:    "secure",
+			anchors: []string{zone.key.ToDS(dns.SHA256).String()},
+			resp:    &dns.Msg{Answer: []dns.RR{answer, validSig}},
+			want:    DNSSECSecure,
+		},
+		{
+			name:    "bogus signature",
+			anchors: []string{zone.key.ToDS(dns.SHA256).String()},
+			resp:    &dns.Msg{Answer: []dns.RR{answer, invalidSig}},
+			want:    DNSSECBogus,
+		},
+		{
+			name:    "bogus missing signature",
+			anchors: []string{zone.key.ToDS(dns.SHA256).String()},
+			resp:    &dns.Msg{Answer: []dns.RR{answer}},
+			want:    DNSSECBogus,
+		},
+		{
+			name:    "secure wildcard expansion",
+			anchors: []string{zone.key.ToDS(dns.SHA256).String()},
+			resp:    &dns.Msg{Answer: []dns.RR{wildcardAnswer, wildcardSig}},
+			want:    DNSSECSecure,
+		},
+		{
+			name:    "bogus signature of unrelated zone",
+			anchors: []string{zone.key.ToDS(dns.SHA256).String(), otherZone.key.ToDS(dns.SHA256).String()},
+			resp:    &dns.Msg{Answer: []dns.RR{answer, otherZoneSig}},
+			want:    DNSSECBogus,
+		},
+		{
+			name:    "bogus wildcard outside of signer zone",
+			anchors: []string{zone.key.ToDS(dns.SHA256).String(), subZone.key.ToDS(dns.SHA256).String()},
+			resp:    &dns.Msg{Answer: []dns.RR{subWildcardAnswer, subWildcardSig}},
+			want:    DNSSECBogus,
+		},
+		{
+			name:    "indeterminate without trust anchor",
+			anchors: []string{newSignedZone(t, "example.com.").key.ToDS(dns.SHA256).String()},
+			resp:    &dns.Msg{Answer: []dns.RR{answer, validSig}},
+			want:    DNSSECIndeterminate,
+		},
+		{
+			name:    "indeterminate empty response",
+			anchors: []string{zone.key.ToDS(dns.SHA256).String()},
+			resp:    &dns.Msg{},
+			want:    DNSSECIndeterminate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Benchmark{Server: "127.0.0.1", DNSSECValidation: true, TrustAnchors: tt.anchors, RequestTimeout: time.Second}
+			require.NoError(t, b.init())
+
+			v := newDNSSECValidator(&b, query)
+
+			assert.Equal(t, tt.want, v.validate(context.Background(), tt.resp))
+		})
+	}
+}
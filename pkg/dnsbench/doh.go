@@ -0,0 +1,101 @@
+package dnsbench
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// dohURITemplateVariables are the RFC 8484 URI template expressions of the dns variable, which are supported in Benchmark.Server.
+var dohURITemplateVariables = []string{"{?dns}", "{&dns}"}
+
+// initDoH normalizes DoH server URL and parses the customizations of the DoH requests. The URI template expression of the dns variable
+// is removed from the URL, because the variable is filled by the DoH client for GET requests and is omitted for POST requests (RFC 8484).
+// The query parameters of the URL are sent together with the query parameters configured in Benchmark.DoHQueryParams.
+func (b *Benchmark) initDoH() error {
+	server := b.Server
+	for _, v := range dohURITemplateVariables {
+		if strings.HasSuffix(server, v) {
+			server = strings.TrimSuffix(server, v)
+			break
+		}
+	}
+	if strings.ContainsAny(server, "{}") {
+		return fmt.Errorf("'%s' is not supported URI template, only {?dns} variable is supported", b.Server)
+	}
+
+	parsedURL, err := url.Parse(server)
+	if err != nil {
+		return err
+	}
+	if len(parsedURL.Path) == 0 {
+		parsedURL.Path = "/dns-query"
+	}
+
+	query := parsedURL.Query()
+	for _, p := range b.DoHQueryParams {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || len(k) == 0 {
+			return fmt.Errorf("--doh-query-param '%s' is not in format key=value", p)
+		}
+		query.Add(k, v)
+	}
+	if query.Has("dns") {
+		return fmt.Errorf("dns query parameter of '%s' is reserved for the DNS query", b.Server)
+	}
+	b.dohQueryParams = query.Encode()
+	parsedURL.RawQuery = ""
+	b.Server = parsedURL.String()
+
+	for _, h := range b.DoHHeaders {
+		k, v, ok := strings.Cut(h, ":")
+		k = strings.TrimSpace(k)
+		if !ok || len(k) == 0 {
+			return fmt.Errorf("--doh-header '%s' is not in format name:value", h)
+		}
+		v = strings.TrimSpace(v)
+		if http.CanonicalHeaderKey(k) == "Host" {
+			b.dohHost = v
+			continue
+		}
+		if b.dohHeader == nil {
+			b.dohHeader = make(http.Header)
+		}
+		b.dohHeader.Add(k, v)
+	}
+	return nil
+}
+
+// customizeDoH returns true if the DoH requests created by the DoH client have to be customized.
+func (b *Benchmark) customizeDoH() bool {
+	return len(b.dohHeader) != 0 || len(b.dohHost) != 0 || len(b.dohQueryParams) != 0
+}
+
+// dohRequestRoundTripper customizes the DoH requests created by the DoH client, the configured headers, Host header
+// and query parameters are set to the requests.
+type dohRequestRoundTripper struct {
+	http.RoundTripper
+	header http.Header
+	host   string
+	query  string
+}
+
+func (rt dohRequestRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	for k, v := range rt.header {
+		req.Header[k] = v
+	}
+	if len(rt.host) != 0 {
+		req.Host = rt.host
+	}
+	if len(rt.query) != 0 {
+		if len(req.URL.RawQuery) == 0 {
+			req.URL.RawQuery = rt.query
+		} else {
+			req.URL.RawQuery += "&" + rt.query
+		}
+	}
+	return rt.RoundTripper.RoundTrip(req)
+}
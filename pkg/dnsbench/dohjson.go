@@ -0,0 +1,133 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+// dohStatusError indicates that DoH server responded with unexpected HTTP status code. It provides the status code the same way as
+// doh.UnexpectedServerHTTPStatusError, which cannot be created outside the doh package.
+type dohStatusError struct {
+	code int
+}
+
+func (e dohStatusError) Error() string {
+	return fmt.Sprintf("unexpected upstream server response HTTP status: %d", e.code)
+}
+
+// HTTPStatus returns HTTP status code returned by the DoH server.
+func (e dohStatusError) HTTPStatus() int {
+	return e.code
+}
+
+type dohJSONQuestion struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+}
+
+type dohJSONRecord struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+	TTL  uint32 `json:"TTL"`
+	Data string `json:"data"`
+}
+
+// dohJSONResponse is the response of DoH JSON API, see https://developers.google.com/speed/public-dns/docs/doh/json.
+type dohJSONResponse struct {
+	Status     int               `json:"Status"`
+	TC         bool              `json:"TC"`
+	RD         bool              `json:"RD"`
+	RA         bool              `json:"RA"`
+	AD         bool              `json:"AD"`
+	CD         bool              `json:"CD"`
+	Question   []dohJSONQuestion `json:"Question"`
+	Answer     []dohJSONRecord   `json:"Answer"`
+	Authority  []dohJSONRecord   `json:"Authority"`
+	Additional []dohJSONRecord   `json:"Additional"`
+}
+
+// dohJSONClient sends the DNS queries using DoH JSON API (application/dns-json) provided for example by Google and Cloudflare.
+// Only the question, CD flag and DO bit of the query can be expressed in the JSON API request, the other EDNS0 options are not sent.
+type dohJSONClient struct {
+	c *http.Client
+}
+
+// query is queryFunc sending the DNS query using the JSON API, the JSON response is converted to DNS message.
+func (c *dohJSONClient) query(ctx context.Context, server string, msg *dns.Msg) (*dns.Msg, error) {
+	q := msg.Question[0]
+	qtype, ok := dns.TypeToString[q.Qtype]
+	if !ok {
+		qtype = strconv.Itoa(int(q.Qtype))
+	}
+	params := url.Values{}
+	params.Set("name", q.Name)
+	params.Set("type", qtype)
+	if msg.CheckingDisabled {
+		params.Set("cd", "true")
+	}
+	if opt := msg.IsEdns0(); opt != nil && opt.Do() {
+		params.Set("do", "true")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-json")
+
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, dohStatusError{code: resp.StatusCode}
+	}
+	var jsonResp dohJSONResponse
+	if err := json.NewDecoder(resp.Body).Decode(&jsonResp); err != nil {
+		return nil, err
+	}
+	return jsonResp.msg(msg), nil
+}
+
+// msg converts the JSON response to the DNS message replying to the request.
+func (r *dohJSONResponse) msg(req *dns.Msg) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Rcode = r.Status
+	m.Truncated = r.TC
+	m.RecursionDesired = r.RD
+	m.RecursionAvailable = r.RA
+	m.AuthenticatedData = r.AD
+	m.CheckingDisabled = r.CD
+	if len(r.Question) > 0 {
+		// the question is taken from the response, so that the letter case preserved by the server can be verified
+		m.Question = []dns.Question{{Name: dns.Fqdn(r.Question[0].Name), Qtype: r.Question[0].Type, Qclass: req.Question[0].Qclass}}
+	}
+	m.Answer = jsonRecords(r.Answer)
+	m.Ns = jsonRecords(r.Authority)
+	m.Extra = jsonRecords(r.Additional)
+	return m
+}
+
+// jsonRecords converts the records of the JSON response to the resource records. The JSON API does not provide the class of the records,
+// so IN class is assumed. The records with data, which cannot be parsed, are represented by the header only.
+func jsonRecords(records []dohJSONRecord) []dns.RR {
+	var rrs []dns.RR
+	for _, r := range records {
+		name := dns.Fqdn(r.Name)
+		rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", name, r.TTL, dns.Type(r.Type).String(), r.Data))
+		if err != nil || rr == nil {
+			rr = &dns.RR_Header{Name: name, Rrtype: r.Type, Class: dns.ClassINET, Ttl: r.TTL}
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs
+}
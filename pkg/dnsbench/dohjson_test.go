@@ -0,0 +1,43 @@
+package dnsbench
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_dohJSONResponse_msg(t *testing.T) {
+	req := new(dns.Msg)
+	req.SetQuestion("ExAmple.org.", dns.TypeTXT)
+	req.Id = 1234
+
+	resp := dohJSONResponse{
+		Status:   dns.RcodeSuccess,
+		TC:       true,
+		RD:       true,
+		RA:       true,
+		AD:       true,
+		Question: []dohJSONQuestion{{Name: "example.org", Type: dns.TypeTXT}},
+		Answer: []dohJSONRecord{
+			{Name: "example.org", Type: dns.TypeTXT, TTL: 60, Data: `"v=spf1 -all"`},
+			{Name: "example.org.", Type: 65280, TTL: 60, Data: "unparseable"},
+		},
+		Additional: []dohJSONRecord{{Name: "ns.example.org.", Type: dns.TypeA, TTL: 30, Data: "127.0.0.1"}},
+	}
+
+	m := resp.msg(req)
+
+	assert.Equal(t, req.Id, m.Id)
+	assert.True(t, m.Response)
+	assert.True(t, m.Truncated)
+	assert.True(t, m.RecursionAvailable)
+	assert.True(t, m.AuthenticatedData)
+	assert.Equal(t, []dns.Question{{Name: "example.org.", Qtype: dns.TypeTXT, Qclass: dns.ClassINET}}, m.Question)
+	require.Len(t, m.Answer, 2)
+	assert.Equal(t, &dns.TXT{Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60}, Txt: []string{"v=spf1 -all"}}, m.Answer[0])
+	assert.Equal(t, &dns.RR_Header{Name: "example.org.", Rrtype: 65280, Class: dns.ClassINET, Ttl: 60}, m.Answer[1])
+	require.Len(t, m.Extra, 1)
+	assert.Equal(t, "ns.example.org.\t30\tIN\tA\t127.0.0.1", m.Extra[0].String())
+}
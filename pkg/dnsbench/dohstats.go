@@ -0,0 +1,231 @@
+package dnsbench
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/quic-go/quic-go"
+)
+
+const (
+	// maxTrackedAge is the highest value of Age and max-age in seconds tracked by DoHStats histograms.
+	maxTrackedAge = 7 * 24 * 60 * 60
+	// maxTrackedHeaderSize is the highest size of the response headers in bytes tracked by DoHStats.HeaderSize.
+	maxTrackedHeaderSize = 1 << 20
+	// maxTrackedStreams is the highest number of requests per connection tracked by DoHStats.StreamsPerConn.
+	maxTrackedStreams = 1 << 30
+)
+
+// DoHStats represents HTTP level statistics of the DoH responses.
+type DoHStats struct {
+	// Protocols counts the responses per negotiated HTTP protocol, for example "HTTP/2.0".
+	Protocols map[string]int64
+	// Fallback is counter of the responses received over different HTTP protocol than configured in Benchmark.DohProtocol.
+	Fallback int64
+	// CacheControl is counter of the responses with Cache-Control header.
+	CacheControl int64
+	// MaxAge is histogram of max-age directives of Cache-Control headers in seconds.
+	MaxAge *hdrhistogram.Histogram
+	// Cached is counter of the responses with Age header, which are served from HTTP cache, for example by CDN.
+	Cached int64
+	// Age is histogram of values of Age headers in seconds.
+	Age *hdrhistogram.Histogram
+	// HeaderSize is histogram of uncompressed sizes of the response header fields in bytes.
+	HeaderSize *hdrhistogram.Histogram
+	// ReusedConn is counter of the requests sent over already established connection.
+	ReusedConn int64
+	// StreamsPerConn is histogram of numbers of the requests sent over each connection, for HTTP/2 and HTTP/3 these are
+	// the streams multiplexed over the connection.
+	StreamsPerConn *hdrhistogram.Histogram
+}
+
+func newDoHStats() *DoHStats {
+	return &DoHStats{
+		Protocols:      make(map[string]int64),
+		MaxAge:         hdrhistogram.New(0, maxTrackedAge, 3),
+		Age:            hdrhistogram.New(0, maxTrackedAge, 3),
+		HeaderSize:     hdrhistogram.New(1, maxTrackedHeaderSize, 3),
+		StreamsPerConn: hdrhistogram.New(1, maxTrackedStreams, 3),
+	}
+}
+
+// dohResponse is HTTP level information about single DoH response.
+type dohResponse struct {
+	proto        string
+	protoMajor   int
+	cacheControl bool
+	maxAge       int64
+	age          int64
+	headerSize   int64
+}
+
+func newDoHResponse(resp *http.Response) *dohResponse {
+	r := dohResponse{proto: resp.Proto, protoMajor: resp.ProtoMajor, maxAge: -1, age: -1}
+	if cc := resp.Header.Get("Cache-Control"); len(cc) != 0 {
+		r.cacheControl = true
+		for _, directive := range strings.Split(cc, ",") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(directive), "max-age="); ok {
+				if maxAge, err := strconv.ParseInt(v, 10, 64); err == nil {
+					r.maxAge = maxAge
+				}
+			}
+		}
+	}
+	if age, err := strconv.ParseInt(resp.Header.Get("Age"), 10, 64); err == nil {
+		r.age = age
+	}
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			// name: value\r\n
+			r.headerSize += int64(len(k) + len(v) + 4)
+		}
+	}
+	return &r
+}
+
+// dohConnRegistry keeps the request counters of the DoH connections opened by single worker, the counters are recorded into
+// DoHStats.StreamsPerConn once the benchmark is finished, because the connections may be shared by the workers.
+type dohConnRegistry struct {
+	mu      sync.Mutex
+	streams []*atomic.Int64
+}
+
+func (r *dohConnRegistry) register() *atomic.Int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	streams := &atomic.Int64{}
+	r.streams = append(r.streams, streams)
+	return streams
+}
+
+func (rs *ResultStats) recordDoHConns(r *dohConnRegistry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, streams := range r.streams {
+		if n := streams.Load(); n > 0 {
+			rs.DoH.StreamsPerConn.RecordValue(n)
+		}
+	}
+}
+
+type dohResponseKey struct{}
+
+// withDoHResponse returns the context, in which HTTP level information about the DoH response of the request is stored into r.
+func withDoHResponse(ctx context.Context, r *dohResponse) context.Context {
+	return context.WithValue(ctx, dohResponseKey{}, r)
+}
+
+func dohResponseFrom(ctx context.Context) *dohResponse {
+	r, _ := ctx.Value(dohResponseKey{}).(*dohResponse)
+	return r
+}
+
+type dohConnRegistryKey struct{}
+
+// withDoHConnRegistry returns the context, in which the DoH connections are registered into the registry.
+func withDoHConnRegistry(ctx context.Context, r *dohConnRegistry) context.Context {
+	return context.WithValue(ctx, dohConnRegistryKey{}, r)
+}
+
+func dohConnRegistryFrom(ctx context.Context) *dohConnRegistry {
+	r, _ := ctx.Value(dohConnRegistryKey{}).(*dohConnRegistry)
+	return r
+}
+
+// recordDoH records the DoH response, newConn is true if the request opened new connection.
+func (rs *ResultStats) recordDoH(r *dohResponse, newConn bool) {
+	// the protocol is empty when no response was received
+	if rs.DoH == nil || r == nil || r.proto == "" {
+		return
+	}
+	rs.DoH.Protocols[r.proto]++
+	if wantMajor, err := strconv.Atoi(strings.Split(rs.dohProto, ".")[0]); err == nil && wantMajor != r.protoMajor {
+		rs.DoH.Fallback++
+	}
+	if r.cacheControl {
+		rs.DoH.CacheControl++
+	}
+	if r.maxAge >= 0 {
+		rs.DoH.MaxAge.RecordValue(r.maxAge)
+	}
+	if r.age >= 0 {
+		rs.DoH.Cached++
+		rs.DoH.Age.RecordValue(r.age)
+	}
+	rs.DoH.HeaderSize.RecordValue(r.headerSize)
+	if !newConn {
+		rs.DoH.ReusedConn++
+	}
+}
+
+// countedConn counts the requests sent over the HTTP/1.1 or HTTP/2 connection.
+type countedConn struct {
+	net.Conn
+	streams *atomic.Int64
+}
+
+// countConn registers the connection into the registry present in the context, so that the requests sent over the connection are counted.
+func countConn(ctx context.Context, conn net.Conn) net.Conn {
+	registry := dohConnRegistryFrom(ctx)
+	if registry == nil {
+		return conn
+	}
+	return &countedConn{Conn: conn, streams: registry.register()}
+}
+
+// countedQUICConn counts the HTTP/3 requests sent over the QUIC connection, each request is sent over new bidirectional stream.
+type countedQUICConn struct {
+	quic.EarlyConnection
+	streams *atomic.Int64
+}
+
+func (c *countedQUICConn) OpenStreamSync(ctx context.Context) (quic.Stream, error) {
+	c.streams.Add(1)
+	return c.EarlyConnection.OpenStreamSync(ctx)
+}
+
+func (c *countedQUICConn) OpenStream() (quic.Stream, error) {
+	c.streams.Add(1)
+	return c.EarlyConnection.OpenStream()
+}
+
+// countQUICConn registers the QUIC connection into the registry present in the context, so that the HTTP/3 requests are counted.
+func countQUICConn(ctx context.Context, conn quic.EarlyConnection) quic.EarlyConnection {
+	registry := dohConnRegistryFrom(ctx)
+	if registry == nil {
+		return conn
+	}
+	return &countedQUICConn{EarlyConnection: conn, streams: registry.register()}
+}
+
+// dohAnalyticsRoundTripper records HTTP level information about the DoH responses into dohResponse present in the request context.
+type dohAnalyticsRoundTripper struct {
+	http.RoundTripper
+}
+
+func (rt dohAnalyticsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := dohResponseFrom(req.Context())
+	if r == nil {
+		return rt.RoundTripper.RoundTrip(req)
+	}
+	// the connection used by HTTP/1.1 and HTTP/2 requests is reported by the transport, HTTP/3 requests are counted by the connection
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if c, ok := info.Conn.(*countedConn); ok {
+				c.streams.Add(1)
+			}
+		},
+	}))
+	resp, err := rt.RoundTripper.RoundTrip(req)
+	if err == nil {
+		*r = *newDoHResponse(resp)
+	}
+	return resp, err
+}
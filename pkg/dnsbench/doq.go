@@ -0,0 +1,145 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// doqClient sends DNS queries over single QUIC connection, each query is sent over separate QUIC stream (RFC 9250).
+// Unlike the connection of the github.com/tantalor93/doq-go client, the connection can be closed, which is needed for connection churn.
+type doqClient struct {
+	b         *Benchmark
+	tlsConfig *tls.Config
+
+	mu   sync.Mutex
+	conn quic.Connection
+}
+
+func (b *Benchmark) newDoQClient() *doqClient {
+	h, _, _ := net.SplitHostPort(b.Server)
+	tlsConfig := b.newTLSConfig()
+	if len(tlsConfig.ServerName) == 0 {
+		tlsConfig.ServerName = h
+	}
+	if len(tlsConfig.NextProtos) == 0 {
+		tlsConfig.NextProtos = []string{"doq"}
+	}
+	return &doqClient{b: b, tlsConfig: tlsConfig}
+}
+
+// query is queryFunc sending the DNS query over new stream of the QUIC connection, the connection is established if needed.
+func (c *doqClient) query(ctx context.Context, _ string, msg *dns.Msg) (*dns.Msg, error) {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.exchange(ctx, conn, msg)
+	if early, ok := conn.(quic.EarlyConnection); ok && errors.Is(err, quic.Err0RTTRejected) {
+		// the server rejected 0-RTT data, the query is sent again after the handshake is finished
+		conn = early.NextConnection()
+		c.mu.Lock()
+		c.conn = conn
+		c.mu.Unlock()
+		return c.exchange(ctx, conn, msg)
+	}
+	return r, err
+}
+
+func (c *doqClient) exchange(ctx context.Context, conn quic.Connection, msg *dns.Msg) (*dns.Msg, error) {
+	start := time.Now()
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	packed, err := msg.Pack()
+	if err != nil {
+		stream.CancelWrite(0)
+		stream.CancelRead(0)
+		return nil, err
+	}
+	stream.SetWriteDeadline(deadline(ctx, c.b.WriteTimeout))
+	buf := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(packed)), uint16(len(packed)))
+	if _, err := stream.Write(append(buf, packed...)); err != nil {
+		stream.CancelRead(0)
+		return nil, err
+	}
+	// closing the stream signals the server that the query was sent completely
+	if err := stream.Close(); err != nil {
+		stream.CancelRead(0)
+		return nil, err
+	}
+
+	stream.SetReadDeadline(deadline(ctx, c.b.ReadTimeout))
+	var length uint16
+	if err := binary.Read(stream, binary.BigEndian, &length); err != nil {
+		stream.CancelRead(0)
+		return nil, err
+	}
+	if trace := phaseTraceFrom(ctx); trace != nil {
+		trace.firstByte(start)
+	}
+	resp := make([]byte, length)
+	if _, err := io.ReadFull(stream, resp); err != nil {
+		stream.CancelRead(0)
+		return nil, err
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(resp); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (c *doqClient) connection(ctx context.Context) (quic.Connection, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil && c.conn.Context().Err() == nil {
+		return c.conn, nil
+	}
+
+	connectCtx := ctx
+	if c.b.ConnectTimeout != 0 {
+		var cancel context.CancelFunc
+		connectCtx, cancel = context.WithTimeout(ctx, c.b.ConnectTimeout)
+		defer cancel()
+	}
+	conn, err := c.b.dialDoQ(connectCtx, c.b.Server, c.tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+// close closes the QUIC connection, new connection is established by the next query.
+func (c *doqClient) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		// 0x0 is DOQ_NO_ERROR error code (RFC 9250)
+		c.conn.CloseWithError(0, "")
+		c.conn = nil
+	}
+}
+
+// deadline returns deadline for the I/O operation limited by the timeout and the deadline of the context, zero timeout means no timeout.
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	var d time.Time
+	if timeout != 0 {
+		d = time.Now().Add(timeout)
+	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (d.IsZero() || ctxDeadline.Before(d)) {
+		d = ctxDeadline
+	}
+	return d
+}
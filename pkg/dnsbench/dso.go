@@ -0,0 +1,341 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+)
+
+// https://www.rfc-editor.org/rfc/rfc8490.html
+const (
+	dsoOpcode           = 6
+	dsoTypeKeepalive    = 0x0001
+	dsoTypeRetryDelay   = 0x0002
+	dsoTypePadding      = 0x0003
+	dsoKeepaliveLength  = 8
+	dsoRetryDelayLength = 4
+	dsoTLVHeaderLength  = 4
+	// dsoInfiniteMs is the value of the timeouts meaning infinity.
+	dsoInfiniteMs = 0xFFFFFFFF
+)
+
+// DSOInfinite is the value of DSOStats.InactivityTimeout and DSOStats.KeepaliveInterval, when the server disabled the timeout.
+const DSOInfinite time.Duration = -1
+
+// Default timeouts proposed by the client when the DSO session is established.
+const (
+	DefaultDSOInactivityTimeout = 15 * time.Second
+	DefaultDSOKeepaliveInterval = 15 * time.Second
+)
+
+var errDSOMalformed = errors.New("malformed DSO message")
+
+// DSOStats represents the DNS Stateful Operations sessions (RFC 8490) established by the benchmark.
+type DSOStats struct {
+	// Sessions is counter of the established DSO sessions.
+	Sessions int64
+	// Setup is histogram of durations of the DSO session establishment, from sending the Keepalive request to receiving the response.
+	Setup *hdrhistogram.Histogram
+	// InactivityTimeout is the inactivity timeout last negotiated with the server, DSOInfinite when the server disabled it.
+	InactivityTimeout time.Duration
+	// KeepaliveInterval is the keepalive interval last negotiated with the server, DSOInfinite when the server disabled it.
+	KeepaliveInterval time.Duration
+	// Keepalives is histogram of round trips of the Keepalive requests sent to keep the idle sessions open.
+	Keepalives *hdrhistogram.Histogram
+	// KeepaliveUpdates is counter of the unidirectional Keepalive messages, with which the server changed the timeouts of the session.
+	KeepaliveUpdates int64
+	// InactivityCloses is counter of the sessions closed by the client, because they were idle for longer than the inactivity timeout.
+	InactivityCloses int64
+	// Rejected is counter of the sessions rejected by the server, which responded with DSOTYPENI or other error response code.
+	// The queries are sent over the connection without DSO session then.
+	Rejected int64
+	// RetryDelays is counter of the Retry Delay messages, with which the server asked the client to close the session.
+	RetryDelays int64
+	// Malformed is counter of the malformed or unexpected DSO messages received from the server, the connection is closed after such message.
+	Malformed int64
+}
+
+// dsoTrace is the DSO activity of single request.
+type dsoTrace struct {
+	setup             time.Duration
+	keepalive         time.Duration
+	inactivityTimeout time.Duration
+	keepaliveInterval time.Duration
+	rejected          bool
+	keepaliveUpdates  int64
+	inactivityClose   bool
+	retryDelay        bool
+	malformed         bool
+}
+
+func (rs *ResultStats) recordDSO(t *dsoTrace) {
+	if rs.DSO == nil || t == nil {
+		return
+	}
+	if t.setup > 0 {
+		rs.DSO.Sessions++
+		rs.DSO.Setup.RecordValue(t.setup.Nanoseconds())
+	}
+	if t.keepalive > 0 {
+		rs.DSO.Keepalives.RecordValue(t.keepalive.Nanoseconds())
+	}
+	if t.inactivityTimeout != 0 || t.keepaliveInterval != 0 {
+		rs.DSO.InactivityTimeout, rs.DSO.KeepaliveInterval = t.inactivityTimeout, t.keepaliveInterval
+	}
+	rs.DSO.KeepaliveUpdates += t.keepaliveUpdates
+	if t.inactivityClose {
+		rs.DSO.InactivityCloses++
+	}
+	if t.rejected {
+		rs.DSO.Rejected++
+	}
+	if t.retryDelay {
+		rs.DSO.RetryDelays++
+	}
+	if t.malformed {
+		rs.DSO.Malformed++
+	}
+}
+
+type dsoTraceKey struct{}
+
+// withDSOTrace returns the context, in which the DSO activity during the request is recorded into the trace.
+func withDSOTrace(ctx context.Context, trace *dsoTrace) context.Context {
+	return context.WithValue(ctx, dsoTraceKey{}, trace)
+}
+
+// dsoTraceFrom returns the DSO trace of the request, nil is returned when the request is not traced.
+func dsoTraceFrom(ctx context.Context) *dsoTrace {
+	trace, _ := ctx.Value(dsoTraceKey{}).(*dsoTrace)
+	return trace
+}
+
+// initDSO validates DSO configuration of the Benchmark.
+func (b *Benchmark) initDSO() error {
+	if b.useDoH || b.useQuic || b.useDNSCrypt || !b.TCP && !b.DOT {
+		return errors.New("--dso is supported only for plain DNS over TCP and DoT")
+	}
+	if b.Pipeline > 1 {
+		return errors.New("--dso cannot be combined with --pipeline")
+	}
+	if b.DSOInactivityTimeout == 0 {
+		b.DSOInactivityTimeout = DefaultDSOInactivityTimeout
+	}
+	if b.DSOKeepaliveInterval == 0 {
+		b.DSOKeepaliveInterval = DefaultDSOKeepaliveInterval
+	}
+	if b.DSOInactivityTimeout < 0 || b.DSOKeepaliveInterval < 0 {
+		return errors.New("--dso-inactivity-timeout and --dso-keepalive-interval must not be negative")
+	}
+	return nil
+}
+
+// dsoSession is the state of DSO session established on the connection.
+type dsoSession struct {
+	inactivityTimeout time.Duration
+	keepaliveInterval time.Duration
+	// lastActivity is the time of the last query, lastTraffic is the time of the last query or Keepalive request.
+	// Keepalive traffic keeps the session open, but it does not reset the inactivity timeout.
+	lastActivity time.Time
+	lastTraffic  time.Time
+	// retry is set when the server asked to close the session, the client does not reconnect before retryDelay elapses.
+	retry      bool
+	retryDelay time.Duration
+}
+
+// establishDSO establishes DSO session on the connection by sending Keepalive request. Nil session is returned, when the server rejected the session.
+func (b *Benchmark) establishDSO(ctx context.Context, co *dns.Conn) (*dsoSession, error) {
+	trace := dsoTraceFrom(ctx)
+	start := time.Now()
+	s := &dsoSession{}
+	accepted, err := b.sendDSOKeepalive(ctx, co, s)
+	if err != nil {
+		if trace != nil && errors.Is(err, errDSOMalformed) {
+			trace.malformed = true
+		}
+		return nil, err
+	}
+	if !accepted {
+		if trace != nil {
+			trace.rejected = true
+		}
+		return nil, nil
+	}
+	s.lastActivity = s.lastTraffic
+	if trace != nil {
+		trace.setup = time.Since(start)
+		trace.inactivityTimeout, trace.keepaliveInterval = s.inactivityTimeout, s.keepaliveInterval
+	}
+	return s, nil
+}
+
+// keepalive sends Keepalive request over the session, which was idle for the keepalive interval, and updates the timeouts of the session.
+func (s *dsoSession) keepalive(ctx context.Context, b *Benchmark, co *dns.Conn) error {
+	trace := dsoTraceFrom(ctx)
+	start := time.Now()
+	accepted, err := b.sendDSOKeepalive(ctx, co, s)
+	if err == nil && !accepted {
+		// the server must not reject Keepalive request of the established session
+		err = fmt.Errorf("%w: Keepalive request rejected", errDSOMalformed)
+	}
+	if err != nil {
+		if trace != nil && errors.Is(err, errDSOMalformed) {
+			trace.malformed = true
+		}
+		return err
+	}
+	if trace != nil {
+		trace.keepalive = time.Since(start)
+		trace.inactivityTimeout, trace.keepaliveInterval = s.inactivityTimeout, s.keepaliveInterval
+	}
+	return nil
+}
+
+// inactive returns true if no query was sent over the session for the inactivity timeout.
+func (s *dsoSession) inactive() bool {
+	return s.inactivityTimeout != DSOInfinite && time.Since(s.lastActivity) >= s.inactivityTimeout
+}
+
+// idle returns true if no traffic was sent over the session for the keepalive interval.
+func (s *dsoSession) idle() bool {
+	return s.keepaliveInterval != DSOInfinite && time.Since(s.lastTraffic) >= s.keepaliveInterval
+}
+
+// active marks the query sent over the session.
+func (s *dsoSession) active() {
+	s.lastActivity = time.Now()
+	s.lastTraffic = s.lastActivity
+}
+
+// sendDSOKeepalive sends Keepalive request proposing the timeouts configured by the Benchmark, reads the response of the server
+// and updates the timeouts of the session. False is returned, when the server responded with error response code, for example DSOTYPENI.
+func (b *Benchmark) sendDSOKeepalive(ctx context.Context, co *dns.Conn, s *dsoSession) (bool, error) {
+	id := uint16(rand.Intn(0xFFFF)) + 1
+	req := binary.BigEndian.AppendUint16(nil, id)
+	req = binary.BigEndian.AppendUint16(req, dsoOpcode<<11)
+	req = append(req, make([]byte, msgHeaderLen-4)...)
+	req = appendDSOKeepalive(req, b.DSOInactivityTimeout, b.DSOKeepaliveInterval)
+
+	deadline := time.Now().Add(b.exchangeTimeout(b.ReadTimeout))
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	co.SetDeadline(deadline)
+	if _, err := co.Conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(req))), req...)); err != nil {
+		return false, err
+	}
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		n, err := readPacked(co.Conn, false, buf)
+		if err != nil {
+			return false, err
+		}
+		msg := buf[:n]
+		if n < msgHeaderLen || dsoMessageOpcode(msg) != dsoOpcode {
+			return false, fmt.Errorf("%w: unexpected message", errDSOMalformed)
+		}
+		if binary.BigEndian.Uint16(msg) == 0 {
+			// the server may send unidirectional messages before the response
+			if err := s.unidirectional(ctx, msg); err != nil {
+				return false, err
+			}
+			continue
+		}
+		if binary.BigEndian.Uint16(msg) != id || msg[2]&0x80 == 0 {
+			return false, fmt.Errorf("%w: unexpected message", errDSOMalformed)
+		}
+		if rcode := msg[3] & 0x0F; rcode != dns.RcodeSuccess {
+			return false, nil
+		}
+		s.inactivityTimeout, s.keepaliveInterval, err = parseDSOKeepalive(msg[msgHeaderLen:])
+		if err != nil {
+			return false, err
+		}
+		s.lastTraffic = time.Now()
+		return true, nil
+	}
+}
+
+// unidirectional handles the unidirectional DSO message sent by the server, which updates the timeouts of the session or asks the client
+// to close the session. Other primary TLVs are not allowed in unidirectional messages and the session must be closed.
+func (s *dsoSession) unidirectional(ctx context.Context, msg []byte) error {
+	trace := dsoTraceFrom(ctx)
+	tlvType, data, ok := firstDSOTLV(msg[msgHeaderLen:])
+	// unidirectional messages have zero ID and QR bit is not set
+	ok = ok && binary.BigEndian.Uint16(msg) == 0 && msg[2]&0x80 == 0
+	switch {
+	case ok && tlvType == dsoTypeKeepalive:
+		inactivityTimeout, keepaliveInterval, err := parseDSOKeepalive(msg[msgHeaderLen:])
+		if err != nil {
+			break
+		}
+		s.inactivityTimeout, s.keepaliveInterval = inactivityTimeout, keepaliveInterval
+		if trace != nil {
+			trace.keepaliveUpdates++
+			trace.inactivityTimeout, trace.keepaliveInterval = inactivityTimeout, keepaliveInterval
+		}
+		return nil
+	case ok && tlvType == dsoTypeRetryDelay && len(data) == dsoRetryDelayLength:
+		s.retry, s.retryDelay = true, time.Duration(binary.BigEndian.Uint32(data))*time.Millisecond
+		if trace != nil {
+			trace.retryDelay = true
+		}
+		return nil
+	}
+	if trace != nil {
+		trace.malformed = true
+	}
+	return fmt.Errorf("%w: unexpected unidirectional message", errDSOMalformed)
+}
+
+func dsoMessageOpcode(msg []byte) int {
+	return int(msg[2]>>3) & 0x0F
+}
+
+func appendDSOKeepalive(b []byte, inactivityTimeout, keepaliveInterval time.Duration) []byte {
+	b = binary.BigEndian.AppendUint16(b, dsoTypeKeepalive)
+	b = binary.BigEndian.AppendUint16(b, dsoKeepaliveLength)
+	b = binary.BigEndian.AppendUint32(b, dsoMilliseconds(inactivityTimeout))
+	return binary.BigEndian.AppendUint32(b, dsoMilliseconds(keepaliveInterval))
+}
+
+func dsoMilliseconds(d time.Duration) uint32 {
+	if d == DSOInfinite || d.Milliseconds() >= dsoInfiniteMs {
+		return dsoInfiniteMs
+	}
+	return uint32(d.Milliseconds())
+}
+
+func dsoDuration(ms uint32) time.Duration {
+	if ms == dsoInfiniteMs {
+		return DSOInfinite
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// parseDSOKeepalive parses the timeouts of Keepalive TLV, which must be the primary TLV of the message.
+func parseDSOKeepalive(tlvs []byte) (time.Duration, time.Duration, error) {
+	tlvType, data, ok := firstDSOTLV(tlvs)
+	if !ok || tlvType != dsoTypeKeepalive || len(data) != dsoKeepaliveLength {
+		return 0, 0, fmt.Errorf("%w: Keepalive TLV expected", errDSOMalformed)
+	}
+	return dsoDuration(binary.BigEndian.Uint32(data)), dsoDuration(binary.BigEndian.Uint32(data[4:])), nil
+}
+
+// firstDSOTLV returns the type and data of the primary TLV, the Encryption Padding TLV cannot be primary.
+func firstDSOTLV(tlvs []byte) (uint16, []byte, bool) {
+	if len(tlvs) < dsoTLVHeaderLength {
+		return 0, nil, false
+	}
+	tlvType, length := binary.BigEndian.Uint16(tlvs), int(binary.BigEndian.Uint16(tlvs[2:]))
+	if tlvType == dsoTypePadding || len(tlvs) < dsoTLVHeaderLength+length {
+		return 0, nil, false
+	}
+	return tlvType, tlvs[dsoTLVHeaderLength : dsoTLVHeaderLength+length], true
+}
@@ -0,0 +1,260 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dsoTypeNotImplemented is DSOTYPENI response code of the server, which does not support the DSO type.
+const dsoTypeNotImplemented = 11
+
+func Test_appendDSOKeepalive(t *testing.T) {
+	tlv := appendDSOKeepalive(nil, 15*time.Second, DSOInfinite)
+	assert.Equal(t, []byte{0, 1, 0, 8, 0, 0, 0x3A, 0x98, 0xFF, 0xFF, 0xFF, 0xFF}, tlv)
+
+	inactivityTimeout, keepaliveInterval, err := parseDSOKeepalive(tlv)
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Second, inactivityTimeout)
+	assert.Equal(t, DSOInfinite, keepaliveInterval)
+}
+
+func Test_parseDSOKeepalive(t *testing.T) {
+	tests := []struct {
+		name string
+		tlvs []byte
+	}{
+		{name: "empty", tlvs: nil},
+		{name: "truncated", tlvs: []byte{0, 1, 0, 8, 0, 0, 0, 1}},
+		{name: "wrong length", tlvs: []byte{0, 1, 0, 4, 0, 0, 0, 1}},
+		{name: "retry delay", tlvs: []byte{0, 2, 0, 4, 0, 0, 0, 1}},
+		{name: "padding", tlvs: []byte{0, 3, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parseDSOKeepalive(tt.tlvs)
+			require.ErrorIs(t, err, errDSOMalformed)
+		})
+	}
+}
+
+// dsoTestServer is DNS server over TCP, which accepts DSO sessions with the configured timeouts.
+type dsoTestServer struct {
+	ln net.Listener
+	// rcode is the response code of the Keepalive requests, session is rejected when it is not success.
+	rcode int
+	// inactivityTimeout and keepaliveInterval are the timeouts in milliseconds sent in Keepalive responses.
+	inactivityTimeout uint32
+	keepaliveInterval uint32
+	// malformed controls whether Keepalive responses are sent without Keepalive TLV.
+	malformed bool
+	// unidirectional is the DSO message sent before each query response, if set.
+	unidirectional []byte
+	keepalives     atomic.Int64
+	conns          atomic.Int64
+}
+
+func newDSOTestServer(t *testing.T, configure func(s *dsoTestServer)) *dsoTestServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	s := &dsoTestServer{ln: ln, inactivityTimeout: 10000, keepaliveInterval: 10000}
+	configure(s)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.conns.Add(1)
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *dsoTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+		msg := make([]byte, length)
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return
+		}
+		var resp []byte
+		if dsoMessageOpcode(msg) == dsoOpcode {
+			s.keepalives.Add(1)
+			resp = append([]byte{}, msg[:msgHeaderLen]...)
+			resp[2] |= 0x80
+			resp[3] = byte(s.rcode)
+			if s.rcode == dns.RcodeSuccess && !s.malformed {
+				resp = binary.BigEndian.AppendUint16(resp, dsoTypeKeepalive)
+				resp = binary.BigEndian.AppendUint16(resp, dsoKeepaliveLength)
+				resp = binary.BigEndian.AppendUint32(resp, s.inactivityTimeout)
+				resp = binary.BigEndian.AppendUint32(resp, s.keepaliveInterval)
+			}
+		} else {
+			req := dns.Msg{}
+			if err := req.Unpack(msg); err != nil {
+				return
+			}
+			if s.unidirectional != nil {
+				conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(s.unidirectional))), s.unidirectional...))
+			}
+			r := dns.Msg{}
+			r.SetReply(&req)
+			r.Answer = append(r.Answer, &dns.A{Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.IPv4(127, 0, 0, 1)})
+			resp, _ = r.Pack()
+		}
+		conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+	}
+}
+
+func dsoUnidirectional(tlvType uint16, data []byte) []byte {
+	msg := make([]byte, msgHeaderLen)
+	binary.BigEndian.PutUint16(msg[2:], dsoOpcode<<11)
+	msg = binary.BigEndian.AppendUint16(msg, tlvType)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(data)))
+	return append(msg, data...)
+}
+
+func TestBenchmark_Run_dso(t *testing.T) {
+	tests := []struct {
+		name         string
+		configure    func(s *dsoTestServer)
+		requestDelay string
+		want         DSOStats
+		wantSuccess  int64
+		wantConns    int64
+	}{
+		{
+			name:        "session established",
+			configure:   func(*dsoTestServer) {},
+			want:        DSOStats{Sessions: 1, InactivityTimeout: 10 * time.Second, KeepaliveInterval: 10 * time.Second},
+			wantSuccess: 3,
+			wantConns:   2,
+		},
+		{
+			name: "session rejected",
+			configure: func(s *dsoTestServer) {
+				s.rcode = dsoTypeNotImplemented
+			},
+			want:        DSOStats{Rejected: 1},
+			wantSuccess: 3,
+			wantConns:   2,
+		},
+		{
+			name: "keepalive of idle session",
+			configure: func(s *dsoTestServer) {
+				s.keepaliveInterval = 1
+			},
+			requestDelay: "20ms",
+			want:         DSOStats{Sessions: 1, InactivityTimeout: 10 * time.Second, KeepaliveInterval: time.Millisecond},
+			wantSuccess:  3,
+			wantConns:    2,
+		},
+		{
+			name: "inactivity timeout",
+			configure: func(s *dsoTestServer) {
+				s.inactivityTimeout, s.keepaliveInterval = 1, dsoInfiniteMs
+			},
+			requestDelay: "20ms",
+			want:         DSOStats{Sessions: 3, InactivityTimeout: time.Millisecond, KeepaliveInterval: DSOInfinite, InactivityCloses: 2},
+			wantSuccess:  3,
+			wantConns:    6,
+		},
+		{
+			name: "keepalive update",
+			configure: func(s *dsoTestServer) {
+				s.unidirectional = dsoUnidirectional(dsoTypeKeepalive, []byte{0, 0, 0x4E, 0x20, 0xFF, 0xFF, 0xFF, 0xFF})
+			},
+			want:        DSOStats{Sessions: 1, InactivityTimeout: 20 * time.Second, KeepaliveInterval: DSOInfinite, KeepaliveUpdates: 3},
+			wantSuccess: 3,
+			wantConns:   2,
+		},
+		{
+			name: "retry delay",
+			configure: func(s *dsoTestServer) {
+				s.unidirectional = dsoUnidirectional(dsoTypeRetryDelay, []byte{0, 0, 0, 10})
+			},
+			want:        DSOStats{Sessions: 3, InactivityTimeout: 10 * time.Second, KeepaliveInterval: 10 * time.Second, RetryDelays: 3},
+			wantSuccess: 3,
+			wantConns:   6,
+		},
+		{
+			name: "malformed keepalive response",
+			configure: func(s *dsoTestServer) {
+				s.malformed = true
+			},
+			want:      DSOStats{Malformed: 3},
+			wantConns: 6,
+		},
+		{
+			name: "unexpected unidirectional message",
+			configure: func(s *dsoTestServer) {
+				s.unidirectional = dsoUnidirectional(0xF000, nil)
+			},
+			want:      DSOStats{Sessions: 3, InactivityTimeout: 10 * time.Second, KeepaliveInterval: 10 * time.Second, Malformed: 3},
+			wantConns: 6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newDSOTestServer(t, tt.configure)
+
+			bench := Benchmark{
+				Server:         s.ln.Addr().String(),
+				TCP:            true,
+				DSO:            true,
+				Queries:        []string{"example.org"},
+				Types:          []string{"A"},
+				Concurrency:    2,
+				Count:          3,
+				Probability:    1,
+				RequestDelay:   tt.requestDelay,
+				WriteTimeout:   time.Second,
+				ReadTimeout:    3 * time.Second,
+				ConnectTimeout: time.Second,
+				RequestTimeout: 5 * time.Second,
+				Writer:         io.Discard,
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			require.Len(t, rs, 2)
+			for _, r := range rs {
+				assert.Equal(t, tt.wantSuccess, r.Counters.Success)
+				require.NotNil(t, r.DSO)
+				assert.Equal(t, tt.want.Sessions, r.DSO.Sessions)
+				assert.Equal(t, tt.want.Sessions, r.DSO.Setup.TotalCount())
+				assert.Equal(t, tt.want.InactivityTimeout, r.DSO.InactivityTimeout)
+				assert.Equal(t, tt.want.KeepaliveInterval, r.DSO.KeepaliveInterval)
+				assert.Equal(t, tt.want.KeepaliveUpdates, r.DSO.KeepaliveUpdates)
+				assert.Equal(t, tt.want.InactivityCloses, r.DSO.InactivityCloses)
+				assert.Equal(t, tt.want.Rejected, r.DSO.Rejected)
+				assert.Equal(t, tt.want.RetryDelays, r.DSO.RetryDelays)
+				assert.Equal(t, tt.want.Malformed, r.DSO.Malformed)
+				if len(tt.requestDelay) != 0 && tt.want.KeepaliveInterval == time.Millisecond {
+					// the session is idle for the keepalive interval before each query except the first one
+					assert.EqualValues(t, 2, r.DSO.Keepalives.TotalCount())
+				} else {
+					assert.EqualValues(t, 0, r.DSO.Keepalives.TotalCount())
+				}
+			}
+			assert.Equal(t, tt.wantConns, s.conns.Load())
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package dnsbench
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ExpectationSeparator separates the domain and the individual assertions in the data source entry or in the line of the expectations file,
+// for example "example.org|type=A|rcode=NOERROR|min-answers=1".
+const ExpectationSeparator = "|"
+
+// expectation represents assertions about the DNS response for a single domain (and optionally single query type).
+type expectation struct {
+	rcode      *int
+	minAnswers int
+	answers    []dns.RR
+	contains   []dns.RR
+}
+
+type expectationKey struct {
+	name  string
+	qtype uint16
+}
+
+type expectations map[expectationKey]*expectation
+
+// parseExpectation parses data source entry or expectations file line in format <domain>[|<assertion>]...
+// Supported assertions are type=<query type>, rcode=<response code>, min-answers=<number>, answer=<RR> and contains=<RR>.
+// When any answer=<RR> assertion is specified, the answer section of the response must contain exactly the specified records.
+// When contains=<RR> assertion is specified, the answer section of the response must contain the specified record.
+func parseExpectation(entry string) (string, expectationKey, *expectation, error) {
+	fields := strings.Split(entry, ExpectationSeparator)
+	name := dns.Fqdn(strings.TrimSpace(fields[0]))
+	key := expectationKey{name: dns.CanonicalName(name)}
+	if len(fields) == 1 {
+		return name, key, nil, nil
+	}
+
+	e := expectation{}
+	for _, f := range fields[1:] {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			return "", key, nil, fmt.Errorf("assertion '%s' of '%s' is not in <key>=<value> format", f, name)
+		}
+		k = strings.TrimSpace(k)
+		v = strings.TrimSpace(v)
+		switch k {
+		case "type":
+			qtype, ok := dns.StringToType[strings.ToUpper(v)]
+			if !ok {
+				return "", key, nil, fmt.Errorf("'%s' of '%s' is not a DNS query type", v, name)
+			}
+			key.qtype = qtype
+		case "rcode":
+			rcode, ok := dns.StringToRcode[strings.ToUpper(v)]
+			if !ok {
+				return "", key, nil, fmt.Errorf("'%s' of '%s' is not a DNS response code", v, name)
+			}
+			e.rcode = &rcode
+		case "min-answers":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return "", key, nil, fmt.Errorf("'%s' of '%s' is not a valid number of answers", v, name)
+			}
+			e.minAnswers = n
+		case "answer", "contains":
+			rr, err := dns.NewRR(v)
+			if err != nil || rr == nil {
+				return "", key, nil, fmt.Errorf("'%s' of '%s' is not a valid resource record", v, name)
+			}
+			if k == "answer" {
+				e.answers = append(e.answers, rr)
+			} else {
+				e.contains = append(e.contains, rr)
+			}
+		default:
+			return "", key, nil, fmt.Errorf("'%s' of '%s' is not supported assertion", k, name)
+		}
+	}
+	return name, key, &e, nil
+}
+
+// readExpectationsFile reads sidecar expectations file, each line of the file contains expectations for single domain.
+// Empty lines and lines starting with # are ignored.
+func (b *Benchmark) readExpectationsFile() error {
+	f, err := os.Open(b.ExpectationsFile)
+	if err != nil {
+		return fmt.Errorf("failed to open expectations file: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := b.parseQuestion(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parseQuestion parses domain from the data source entry and registers the expectations declared in the entry.
+func (b *Benchmark) parseQuestion(entry string) (string, error) {
+	if !strings.Contains(entry, ExpectationSeparator) {
+		return dns.Fqdn(entry), nil
+	}
+	name, key, e, err := parseExpectation(entry)
+	if err != nil {
+		return "", err
+	}
+	if e != nil {
+		if b.expectations == nil {
+			b.expectations = make(expectations)
+		}
+		b.expectations[key] = e
+	}
+	return name, nil
+}
+
+// matches returns false, if there is expectation for the question and the response does not fulfill it.
+func (e expectations) matches(q dns.Question, resp *dns.Msg) bool {
+	name := dns.CanonicalName(q.Name)
+	exp, ok := e[expectationKey{name: name, qtype: q.Qtype}]
+	if !ok {
+		exp, ok = e[expectationKey{name: name}]
+	}
+	if !ok {
+		return true
+	}
+	return exp.matches(resp)
+}
+
+func (e *expectation) matches(resp *dns.Msg) bool {
+	if e.rcode != nil && resp.Rcode != *e.rcode {
+		return false
+	}
+	if len(resp.Answer) < e.minAnswers {
+		return false
+	}
+	if len(e.answers) > 0 {
+		if len(e.answers) != len(resp.Answer) || !containsAll(resp.Answer, e.answers) || !containsAll(e.answers, resp.Answer) {
+			return false
+		}
+	}
+	return containsAll(resp.Answer, e.contains)
+}
+
+// containsAll checks whether all records are present in rrs, TTLs of the records are not compared.
+func containsAll(rrs []dns.RR, records []dns.RR) bool {
+	for _, r := range records {
+		found := false
+		for _, rr := range rrs {
+			if dns.IsDuplicate(rr, r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package dnsbench
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseExpectation(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    string
+		wantName string
+		wantKey  expectationKey
+		wantErr  bool
+	}{
+		{
+			name:     "domain without expectations",
+			entry:    "example.org",
+			wantName: "example.org.",
+			wantKey:  expectationKey{name: "example.org."},
+		},
+		{
+			name:     "domain with expectations",
+			entry:    "Example.org|type=AAAA|rcode=NOERROR|min-answers=1|contains=example.org. IN AAAA ::1",
+			wantName: "Example.org.",
+			wantKey:  expectationKey{name: "example.org.", qtype: dns.TypeAAAA},
+		},
+		{
+			name:    "invalid assertion format",
+			entry:   "example.org|rcode",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported assertion",
+			entry:   "example.org|ttl=10",
+			wantErr: true,
+		},
+		{
+			name:    "invalid rcode",
+			entry:   "example.org|rcode=invalid",
+			wantErr: true,
+		},
+		{
+			name:    "invalid answer",
+			entry:   "example.org|answer=invalid",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, key, _, err := parseExpectation(tt.entry)
+
+			require.Equal(t, tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(t, tt.wantName, name)
+				assert.Equal(t, tt.wantKey, key)
+			}
+		})
+	}
+}
+
+func Test_expectations_matches(t *testing.T) {
+	a1 := &dns.A{Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP("127.0.0.1")}
+	a2 := &dns.A{Hdr: dns.RR_Header{Name: "example.org.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.ParseIP("127.0.0.2")}
+
+	tests := []struct {
+		name     string
+		entry    string
+		question dns.Question
+		resp     *dns.Msg
+		want     bool
+	}{
+		{
+			name:     "no expectation for domain",
+			entry:    "example.com|rcode=NXDOMAIN",
+			question: dns.Question{Name: "example.org.", Qtype: dns.TypeA},
+			resp:     &dns.Msg{},
+			want:     true,
+		},
+		{
+			name:     "no expectation for query type",
+			entry:    "example.org|type=AAAA|rcode=NXDOMAIN",
+			question: dns.Question{Name: "example.org.", Qtype: dns.TypeA},
+			resp:     &dns.Msg{},
+			want:     true,
+		},
+		{
+			name:     "rcode mismatch",
+			entry:    "example.org|rcode=NXDOMAIN",
+			question: dns.Question{Name: "example.org.", Qtype: dns.TypeA},
+			resp:     &dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeSuccess}},
+			want:     false,
+		},
+		{
+			name:     "min answers",
+			entry:    "example.org|min-answers=2",
+			question: dns.Question{Name: "example.org.", Qtype: dns.TypeA},
+			resp:     &dns.Msg{Answer: []dns.RR{a1}},
+			want:     false,
+		},
+		{
+			name:     "contains",
+			entry:    "example.org|contains=example.org. 300 IN A 127.0.0.2",
+			question: dns.Question{Name: "EXAMPLE.org.", Qtype: dns.TypeA},
+			resp:     &dns.Msg{Answer: []dns.RR{a1, a2}},
+			want:     true,
+		},
+		{
+			name:     "exact answer",
+			entry:    "example.org|answer=example.org. IN A 127.0.0.1",
+			question: dns.Question{Name: "example.org.", Qtype: dns.TypeA},
+			resp:     &dns.Msg{Answer: []dns.RR{a1, a2}},
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Benchmark{}
+			_, err := b.parseQuestion(tt.entry)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, b.expectations.matches(tt.question, tt.resp))
+		})
+	}
+}
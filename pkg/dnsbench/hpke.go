@@ -0,0 +1,128 @@
+package dnsbench
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// HPKE (RFC 9180) identifiers of the only cipher suite supported for ODoH, DHKEM(X25519, HKDF-SHA256), HKDF-SHA256 and AES-128-GCM.
+const (
+	hpkeKEMX25519HKDFSHA256 uint16 = 0x0020
+	hpkeKDFHKDFSHA256       uint16 = 0x0001
+	hpkeAEADAES128GCM       uint16 = 0x0001
+
+	hpkeModeBase   = 0x00
+	hpkeKeySize    = 16
+	hpkeNonceSize  = 12
+	hpkeSecretSize = 32
+)
+
+var (
+	hpkeKEMSuiteID = binary.BigEndian.AppendUint16([]byte("KEM"), hpkeKEMX25519HKDFSHA256)
+	hpkeSuiteID    = binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(
+		binary.BigEndian.AppendUint16([]byte("HPKE"), hpkeKEMX25519HKDFSHA256), hpkeKDFHKDFSHA256), hpkeAEADAES128GCM)
+)
+
+// hpkeContext is HPKE encryption context established in the base mode, only single message is sealed or opened by the context
+// (sequence number 0), which is sufficient for ODoH.
+type hpkeContext struct {
+	aead           cipher.AEAD
+	baseNonce      []byte
+	exporterSecret []byte
+}
+
+// hpkeSetupBaseS generates ephemeral key pair and establishes the sender context for the public key of the receiver.
+// The encapsulated key is returned together with the context.
+func hpkeSetupBaseS(pkR, info []byte) ([]byte, *hpkeContext, error) {
+	skE := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(skE); err != nil {
+		return nil, nil, err
+	}
+	return hpkeSetupBaseSWithKey(skE, pkR, info)
+}
+
+func hpkeSetupBaseSWithKey(skE, pkR, info []byte) ([]byte, *hpkeContext, error) {
+	pkE, err := curve25519.X25519(skE, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	dh, err := curve25519.X25519(skE, pkR)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, err := hpkeKeySchedule(hpkeSharedSecret(dh, append(append([]byte{}, pkE...), pkR...)), info)
+	return pkE, ctx, err
+}
+
+// hpkeSetupBaseR establishes the receiver context from the encapsulated key and the private key of the receiver.
+func hpkeSetupBaseR(enc, skR, info []byte) (*hpkeContext, error) {
+	dh, err := curve25519.X25519(skR, enc)
+	if err != nil {
+		return nil, err
+	}
+	pkR, err := curve25519.X25519(skR, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	return hpkeKeySchedule(hpkeSharedSecret(dh, append(append([]byte{}, enc...), pkR...)), info)
+}
+
+func hpkeSharedSecret(dh, kemContext []byte) []byte {
+	prk := hpkeLabeledExtract(hpkeKEMSuiteID, nil, "eae_prk", dh)
+	return hpkeLabeledExpand(hpkeKEMSuiteID, prk, "shared_secret", kemContext, hpkeSecretSize)
+}
+
+func hpkeKeySchedule(sharedSecret, info []byte) (*hpkeContext, error) {
+	keyScheduleContext := []byte{hpkeModeBase}
+	keyScheduleContext = append(keyScheduleContext, hpkeLabeledExtract(hpkeSuiteID, nil, "psk_id_hash", nil)...)
+	keyScheduleContext = append(keyScheduleContext, hpkeLabeledExtract(hpkeSuiteID, nil, "info_hash", info)...)
+	secret := hpkeLabeledExtract(hpkeSuiteID, sharedSecret, "secret", nil)
+
+	block, err := aes.NewCipher(hpkeLabeledExpand(hpkeSuiteID, secret, "key", keyScheduleContext, hpkeKeySize))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &hpkeContext{
+		aead:           aead,
+		baseNonce:      hpkeLabeledExpand(hpkeSuiteID, secret, "base_nonce", keyScheduleContext, hpkeNonceSize),
+		exporterSecret: hpkeLabeledExpand(hpkeSuiteID, secret, "exp", keyScheduleContext, hpkeSecretSize),
+	}, nil
+}
+
+func (c *hpkeContext) seal(aad, plaintext []byte) []byte {
+	return c.aead.Seal(nil, c.baseNonce, plaintext, aad)
+}
+
+func (c *hpkeContext) open(aad, ciphertext []byte) ([]byte, error) {
+	return c.aead.Open(nil, c.baseNonce, ciphertext, aad)
+}
+
+// export derives secret of the given length from the context.
+func (c *hpkeContext) export(exporterContext []byte, length int) []byte {
+	return hpkeLabeledExpand(hpkeSuiteID, c.exporterSecret, "sec", exporterContext, length)
+}
+
+func hpkeLabeledExtract(suiteID, salt []byte, label string, ikm []byte) []byte {
+	labeledIKM := append(append(append([]byte("HPKE-v1"), suiteID...), label...), ikm...)
+	return hkdf.Extract(sha256.New, labeledIKM, salt)
+}
+
+func hpkeLabeledExpand(suiteID, prk []byte, label string, info []byte, length int) []byte {
+	labeledInfo := binary.BigEndian.AppendUint16(nil, uint16(length))
+	labeledInfo = append(append(append(append(labeledInfo, "HPKE-v1"...), suiteID...), label...), info...)
+	out := make([]byte, length)
+	// the length is always much lower than the limit of HKDF-SHA256, so the expansion does not fail
+	io.ReadFull(hkdf.Expand(sha256.New, prk, labeledInfo), out)
+	return out
+}
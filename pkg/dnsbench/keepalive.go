@@ -0,0 +1,188 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultIdleProbeMax is the longest idle gap inserted by the idle timeout probing, when Benchmark.IdleProbeMax is not configured.
+const DefaultIdleProbeMax = 2 * time.Minute
+
+// keepaliveTimeoutUnit is the unit of the timeout of edns-tcp-keepalive option (RFC 7828).
+const keepaliveTimeoutUnit = 100 * time.Millisecond
+
+// idleProbeReadTimeout is the deadline of the read, which checks whether the server closed the idle connection.
+// The connection closed by the server is readable immediately, so the deadline only limits the delay of the open connection.
+const idleProbeReadTimeout = 10 * time.Millisecond
+
+// IdleStats represents the idle timeouts of the TCP connections, advertised by the server in edns-tcp-keepalive option (RFC 7828)
+// and observed by the idle timeout probing.
+type IdleStats struct {
+	// KeepaliveResponses is counter of the responses with edns-tcp-keepalive option.
+	KeepaliveResponses int64
+	// KeepaliveTimeout is the idle timeout last advertised by the server in edns-tcp-keepalive option.
+	KeepaliveTimeout time.Duration
+	// KeepaliveExpired is counter of the connections closed by the client, because they were idle for longer than the advertised timeout.
+	KeepaliveExpired int64
+	// Probes is counter of the idle gaps inserted by the idle timeout probing.
+	Probes int64
+	// ProbesClosed is counter of the idle gaps, after which the server closed the connection.
+	ProbesClosed int64
+	// LongestOpen is the longest idle gap, after which the connection was still open.
+	LongestOpen time.Duration
+	// ShortestClosed is the shortest idle gap, after which the server closed the connection, zero when the server did not close any connection.
+	ShortestClosed time.Duration
+}
+
+// idleTrace is the idle timeout activity of single request.
+type idleTrace struct {
+	keepalive        bool
+	keepaliveTimeout time.Duration
+	keepaliveExpired bool
+}
+
+func (rs *ResultStats) recordIdle(t *idleTrace) {
+	if rs.Idle == nil || t == nil {
+		return
+	}
+	if t.keepalive {
+		rs.Idle.KeepaliveResponses++
+		rs.Idle.KeepaliveTimeout = t.keepaliveTimeout
+	}
+	if t.keepaliveExpired {
+		rs.Idle.KeepaliveExpired++
+	}
+}
+
+// recordIdleProbe records whether the server closed the connection after the idle gap inserted by the idle timeout probing.
+func (rs *ResultStats) recordIdleProbe(gap time.Duration, closed bool) {
+	if rs.Idle == nil {
+		return
+	}
+	rs.Idle.Probes++
+	if !closed {
+		rs.Idle.LongestOpen = max(rs.Idle.LongestOpen, gap)
+		return
+	}
+	rs.Idle.ProbesClosed++
+	if rs.Idle.ShortestClosed == 0 || gap < rs.Idle.ShortestClosed {
+		rs.Idle.ShortestClosed = gap
+	}
+}
+
+type idleTraceKey struct{}
+
+// withIdleTrace returns the context, in which the idle timeout activity during the request is recorded into the trace.
+func withIdleTrace(ctx context.Context, trace *idleTrace) context.Context {
+	return context.WithValue(ctx, idleTraceKey{}, trace)
+}
+
+// idleTraceFrom returns the idle timeout trace of the request, nil is returned when the request is not traced.
+func idleTraceFrom(ctx context.Context) *idleTrace {
+	trace, _ := ctx.Value(idleTraceKey{}).(*idleTrace)
+	return trace
+}
+
+// initIdle validates edns-tcp-keepalive and idle timeout probing configuration of the Benchmark.
+func (b *Benchmark) initIdle() error {
+	if b.useDoH || b.useQuic || b.useDNSCrypt || !b.TCP && !b.DOT {
+		return errors.New("--tcp-keepalive and --idle-probe are supported only for plain DNS over TCP and DoT")
+	}
+	if b.Pipeline > 1 {
+		return errors.New("--tcp-keepalive and --idle-probe cannot be combined with --pipeline")
+	}
+	if b.DSO {
+		// the idle timeouts of DSO session are negotiated by DSO Keepalive, edns-tcp-keepalive option must not be used (RFC 8490)
+		return errors.New("--tcp-keepalive and --idle-probe cannot be combined with --dso")
+	}
+	if b.IdleProbe < 0 || b.IdleProbeMax < 0 {
+		return errors.New("--idle-probe and --idle-probe-max must not be negative")
+	}
+	if b.IdleProbe > 0 {
+		if b.IdleProbeMax == 0 {
+			b.IdleProbeMax = DefaultIdleProbeMax
+		}
+		if b.IdleProbeMax < b.IdleProbe {
+			return errors.New("--idle-probe-max must not be shorter than --idle-probe")
+		}
+	}
+	return nil
+}
+
+// idleTimeouts returns true if edns-tcp-keepalive option is sent or the idle timeout is probed.
+func (b *Benchmark) idleTimeouts() bool {
+	return b.TCPKeepalive || b.IdleProbe != 0
+}
+
+// keepaliveTimeout returns the idle timeout advertised by the server in edns-tcp-keepalive option of the response.
+// The timeout is zero when the server omitted the value, the connection should not be reused then.
+func keepaliveTimeout(r *dns.Msg) (time.Duration, bool) {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return 0, false
+	}
+	for _, o := range opt.Option {
+		if k, ok := o.(*dns.EDNS0_TCP_KEEPALIVE); ok {
+			return time.Duration(k.Timeout) * keepaliveTimeoutUnit, true
+		}
+	}
+	return 0, false
+}
+
+// idleProber inserts idle gaps of increasing length before the queries sent over the reused connection, to discover when the server closes idle connections.
+// The gaps are doubled from Benchmark.IdleProbe until the server closes the connection or Benchmark.IdleProbeMax is reached, the idle timeout is then
+// narrowed down by bisection between the longest gap after which the connection was open and the shortest gap after which it was closed.
+type idleProber struct {
+	step        time.Duration
+	maxGap      time.Duration
+	longestOpen time.Duration
+	// shortestClosed is zero until the server closes the connection.
+	shortestClosed time.Duration
+}
+
+// nextGap returns the next idle gap, false is returned when the idle timeout is found with the precision of the step or the longest gap was probed.
+func (p *idleProber) nextGap() (time.Duration, bool) {
+	if p.shortestClosed == 0 {
+		if p.longestOpen >= p.maxGap {
+			return 0, false
+		}
+		if p.longestOpen == 0 {
+			return p.step, true
+		}
+		return min(2*p.longestOpen, p.maxGap), true
+	}
+	if p.shortestClosed-p.longestOpen <= p.step {
+		return 0, false
+	}
+	return (p.longestOpen + p.shortestClosed) / 2, true
+}
+
+// observe records whether the connection was closed by the server after the idle gap.
+func (p *idleProber) observe(gap time.Duration, closed bool) {
+	if !closed {
+		p.longestOpen = max(p.longestOpen, gap)
+		return
+	}
+	if p.shortestClosed == 0 || gap < p.shortestClosed {
+		p.shortestClosed = gap
+	}
+}
+
+// serverClosed returns the error, when the server closed the idle connection. It is detected by reading from the connection with short deadline,
+// the server is not expected to send anything over idle connection, so the connection is not usable after successful read either.
+func serverClosed(co *dns.Conn) error {
+	co.SetReadDeadline(time.Now().Add(idleProbeReadTimeout))
+	_, err := co.Conn.Read(make([]byte, 1))
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return nil
+	}
+	if err == nil {
+		return errors.New("unexpected data received over idle connection")
+	}
+	return err
+}
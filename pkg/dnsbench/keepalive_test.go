@@ -0,0 +1,225 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_idleProber(t *testing.T) {
+	tests := []struct {
+		name          string
+		serverTimeout time.Duration
+		wantGaps      []time.Duration
+		wantOpen      time.Duration
+		wantClosed    time.Duration
+	}{
+		{
+			name:          "timeout found by bisection",
+			serverTimeout: 5500 * time.Millisecond,
+			wantGaps:      []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 6 * time.Second, 5 * time.Second},
+			wantOpen:      5 * time.Second,
+			wantClosed:    6 * time.Second,
+		},
+		{
+			name:          "timeout longer than longest gap",
+			serverTimeout: time.Minute,
+			wantGaps:      []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second},
+			wantOpen:      10 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := idleProber{step: time.Second, maxGap: 10 * time.Second}
+			var gaps []time.Duration
+			for {
+				gap, ok := p.nextGap()
+				if !ok {
+					break
+				}
+				gaps = append(gaps, gap)
+				p.observe(gap, gap > tt.serverTimeout)
+			}
+			assert.Equal(t, tt.wantGaps, gaps)
+			assert.Equal(t, tt.wantOpen, p.longestOpen)
+			assert.Equal(t, tt.wantClosed, p.shortestClosed)
+		})
+	}
+}
+
+func Test_keepaliveTimeout(t *testing.T) {
+	r := dns.Msg{}
+	_, ok := keepaliveTimeout(&r)
+	assert.False(t, ok)
+
+	r.SetEdns0(DefaultEdns0BufferSize, false)
+	_, ok = keepaliveTimeout(&r)
+	assert.False(t, ok)
+
+	opt := r.IsEdns0()
+	opt.Option = append(opt.Option, &dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE, Timeout: 25})
+	timeout, ok := keepaliveTimeout(&r)
+	assert.True(t, ok)
+	assert.Equal(t, 2500*time.Millisecond, timeout)
+}
+
+// keepaliveTestServer is DNS server over TCP, which advertises the idle timeout in edns-tcp-keepalive option and closes idle connections.
+type keepaliveTestServer struct {
+	ln net.Listener
+	// advertise is the timeout in units of 100 milliseconds advertised in the responses to the queries with edns-tcp-keepalive option.
+	advertise uint16
+	// idleTimeout is the timeout after which the idle connection is closed, the connections are not closed when zero.
+	idleTimeout time.Duration
+	keepalives  atomic.Int64
+	conns       atomic.Int64
+}
+
+func newKeepaliveTestServer(t *testing.T, advertise uint16, idleTimeout time.Duration) *keepaliveTestServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	s := &keepaliveTestServer{ln: ln, advertise: advertise, idleTimeout: idleTimeout}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.conns.Add(1)
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *keepaliveTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		if s.idleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.idleTimeout))
+		}
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+		query := make([]byte, length)
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		req := dns.Msg{}
+		if err := req.Unpack(query); err != nil {
+			return
+		}
+		resp := dns.Msg{}
+		resp.SetReply(&req)
+		resp.Answer = append(resp.Answer, &dns.A{Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.IPv4(127, 0, 0, 1)})
+		if _, ok := keepaliveTimeout(&req); ok {
+			s.keepalives.Add(1)
+			resp.SetEdns0(DefaultEdns0BufferSize, false)
+			opt := resp.IsEdns0()
+			opt.Option = append(opt.Option, &dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE, Timeout: s.advertise})
+		}
+		packed, _ := resp.Pack()
+		conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(packed))), packed...))
+	}
+}
+
+func TestBenchmark_Run_tcp_keepalive(t *testing.T) {
+	tests := []struct {
+		name        string
+		advertise   uint16
+		wantExpired int64
+		wantConns   int64
+	}{
+		{
+			name:      "connection reused",
+			advertise: 100,
+			wantConns: 1,
+		},
+		{
+			name:        "advertised timeout expired",
+			advertise:   1,
+			wantExpired: 2,
+			wantConns:   3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newKeepaliveTestServer(t, tt.advertise, 0)
+
+			bench := Benchmark{
+				Server:         s.ln.Addr().String(),
+				TCP:            true,
+				TCPKeepalive:   true,
+				Queries:        []string{"example.org"},
+				Types:          []string{"A"},
+				Concurrency:    1,
+				Count:          3,
+				Probability:    1,
+				RequestDelay:   "150ms",
+				WriteTimeout:   time.Second,
+				ReadTimeout:    3 * time.Second,
+				ConnectTimeout: time.Second,
+				RequestTimeout: 5 * time.Second,
+				Writer:         io.Discard,
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			require.Len(t, rs, 1)
+			assert.EqualValues(t, 3, rs[0].Counters.Success)
+			require.NotNil(t, rs[0].Idle)
+			assert.EqualValues(t, 3, rs[0].Idle.KeepaliveResponses)
+			assert.Equal(t, time.Duration(tt.advertise)*keepaliveTimeoutUnit, rs[0].Idle.KeepaliveTimeout)
+			assert.Equal(t, tt.wantExpired, rs[0].Idle.KeepaliveExpired)
+			assert.EqualValues(t, 3, s.keepalives.Load(), "edns-tcp-keepalive option expected in all queries")
+			assert.Equal(t, tt.wantConns, s.conns.Load())
+		})
+	}
+}
+
+func TestBenchmark_Run_idle_probe(t *testing.T) {
+	s := newKeepaliveTestServer(t, 0, 650*time.Millisecond)
+
+	bench := Benchmark{
+		Server:         s.ln.Addr().String(),
+		TCP:            true,
+		IdleProbe:      200 * time.Millisecond,
+		IdleProbeMax:   time.Second,
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Concurrency:    1,
+		Count:          6,
+		Probability:    1,
+		WriteTimeout:   time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: time.Second,
+		RequestTimeout: 5 * time.Second,
+		Writer:         io.Discard,
+	}
+
+	rs, err := bench.Run(context.Background())
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 1)
+	// the connection closed by the server is detected before the query is sent, so no query fails
+	assert.EqualValues(t, 6, rs[0].Counters.Success)
+	require.NotNil(t, rs[0].Idle)
+	// gaps of 200ms, 400ms, 800ms and 600ms are probed, then the idle timeout is found with 200ms precision
+	assert.EqualValues(t, 4, rs[0].Idle.Probes)
+	assert.EqualValues(t, 1, rs[0].Idle.ProbesClosed)
+	assert.Equal(t, 600*time.Millisecond, rs[0].Idle.LongestOpen)
+	assert.Equal(t, 800*time.Millisecond, rs[0].Idle.ShortestClosed)
+	assert.EqualValues(t, 2, s.conns.Load())
+	// the idle gaps are not included in the latencies
+	assert.Less(t, rs[0].Hist.Max(), (200 * time.Millisecond).Nanoseconds())
+}
@@ -0,0 +1,321 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// odohConfigsPath is the well-known path of ODoH configurations of the target (RFC 9230).
+	odohConfigsPath  = "/.well-known/odohconfigs"
+	odohContentType  = "application/oblivious-dns-message"
+	odohVersion      = 0x0001
+	odohQueryType    = 0x01
+	odohResponseType = 0x02
+	// odohPadBlock is the block size the DNS queries are padded to, the same as recommended for DoH by RFC 8467.
+	odohPadBlock = 128
+	// odohResponseNonceSize is max(Nn, Nk) of AES-128-GCM.
+	odohResponseNonceSize = 16
+	maxODoHConfigsSize    = 1 << 16
+)
+
+// ODoHStats represents the breakdown of the latencies of ODoH queries.
+type ODoHStats struct {
+	// Crypto is histogram of durations of HPKE encryption of the queries and decryption of the responses done by the client.
+	Crypto *hdrhistogram.Histogram
+	// Proxied is histogram of HTTP round trips of the queries sent through the oblivious proxy to the target.
+	Proxied *hdrhistogram.Histogram
+	// Target is histogram of HTTP round trips of the queries sent directly to the target. It is filled only when Benchmark.ODoHDirectProbe is configured.
+	Target *hdrhistogram.Histogram
+	// Proxy is histogram of the proxy contributions to the latencies, which are the differences between the round trips through the proxy
+	// and the round trips of the direct probes. It is filled only when Benchmark.ODoHDirectProbe is configured.
+	Proxy *hdrhistogram.Histogram
+}
+
+// odohTiming is the latency breakdown of single ODoH query.
+type odohTiming struct {
+	crypto  time.Duration
+	proxied time.Duration
+	// target is zero when the query was not probed directly or the probe failed
+	target time.Duration
+}
+
+type odohTimingKey struct{}
+
+// withODoHTiming returns the context, in which the latency breakdown of the ODoH query is stored into t.
+func withODoHTiming(ctx context.Context, t *odohTiming) context.Context {
+	return context.WithValue(ctx, odohTimingKey{}, t)
+}
+
+func odohTimingFrom(ctx context.Context) *odohTiming {
+	t, _ := ctx.Value(odohTimingKey{}).(*odohTiming)
+	return t
+}
+
+func (rs *ResultStats) recordODoH(t *odohTiming) {
+	// the timing is zero when the query failed
+	if rs.ODoH == nil || t == nil || t.proxied == 0 {
+		return
+	}
+	rs.ODoH.Crypto.RecordValue(t.crypto.Nanoseconds())
+	rs.ODoH.Proxied.RecordValue(t.proxied.Nanoseconds())
+	if t.target > 0 {
+		rs.ODoH.Target.RecordValue(t.target.Nanoseconds())
+		rs.ODoH.Proxy.RecordValue(max(t.proxied-t.target, 0).Nanoseconds())
+	}
+}
+
+// odohConfig is ObliviousDoHConfigContents of the target with supported cipher suite.
+type odohConfig struct {
+	publicKey []byte
+	keyID     []byte
+}
+
+// useODoH returns true if the DoH server is benchmarked as ODoH target through the oblivious proxy.
+func (b *Benchmark) useODoH() bool {
+	return len(b.odohProxyURL) != 0
+}
+
+// initODoH validates the oblivious proxy URL, the target is the DoH server configured in Benchmark.Server.
+func (b *Benchmark) initODoH() error {
+	if !b.useDoH {
+		return errors.New("--odoh-proxy requires the server to be ODoH target URL, for example https://odoh.example.com/dns-query")
+	}
+	if b.DohMethod == GetHTTPMethod || b.DohMethod == JSONHTTPMethod {
+		return errors.New("--odoh-proxy supports only POST method")
+	}
+	if ok, _ := isHTTPUrl(b.ODoHProxy); !ok {
+		return fmt.Errorf("--odoh-proxy '%s' is not HTTP URL", b.ODoHProxy)
+	}
+	proxyURL, err := url.Parse(b.ODoHProxy)
+	if err != nil {
+		return err
+	}
+	targetURL, err := url.Parse(b.Server)
+	if err != nil {
+		return err
+	}
+	query := proxyURL.Query()
+	query.Set("targethost", targetURL.Host)
+	query.Set("targetpath", targetURL.Path)
+	proxyURL.RawQuery = query.Encode()
+	b.odohProxyURL = proxyURL.String()
+	return nil
+}
+
+// fetchODoHConfig fetches ODoH configurations from the well-known URL of the target, the first configuration
+// with supported version and cipher suite is used for all the queries of the benchmark.
+func (b *Benchmark) fetchODoHConfig(ctx context.Context) error {
+	configURL, err := url.Parse(b.Server)
+	if err != nil {
+		return err
+	}
+	configURL.Path, configURL.RawQuery = odohConfigsPath, ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, configURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	c := http.Client{Transport: &http.Transport{TLSClientConfig: b.newTLSConfig(), DialContext: b.dialNet}, Timeout: b.RequestTimeout}
+	resp, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch ODoH configuration: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch ODoH configuration: %w", dohStatusError{code: resp.StatusCode})
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxODoHConfigsSize))
+	if err != nil {
+		return fmt.Errorf("failed to fetch ODoH configuration: %w", err)
+	}
+	b.odohConfig, err = parseODoHConfigs(data)
+	return err
+}
+
+// parseODoHConfigs parses ObliviousDoHConfigs structure (RFC 9230, section 6.1).
+func parseODoHConfigs(data []byte) (*odohConfig, error) {
+	configs, _, ok := readVector(data)
+	if !ok {
+		return nil, errors.New("invalid ODoH configurations")
+	}
+	for len(configs) >= 4 {
+		version := binary.BigEndian.Uint16(configs)
+		contents, rest, ok := readVector(configs[2:])
+		if !ok {
+			return nil, errors.New("invalid ODoH configurations")
+		}
+		configs = rest
+		if version != odohVersion || len(contents) < 8 {
+			continue
+		}
+		kem, kdf, aead := binary.BigEndian.Uint16(contents), binary.BigEndian.Uint16(contents[2:]), binary.BigEndian.Uint16(contents[4:])
+		publicKey, _, ok := readVector(contents[6:])
+		if !ok || kem != hpkeKEMX25519HKDFSHA256 || kdf != hpkeKDFHKDFSHA256 || aead != hpkeAEADAES128GCM {
+			continue
+		}
+		// key_id = Expand(Extract("", config), "odoh key id", Nh)
+		keyID := make([]byte, sha256.Size)
+		io.ReadFull(hkdf.Expand(sha256.New, hkdf.Extract(sha256.New, contents, nil), []byte("odoh key id")), keyID)
+		return &odohConfig{publicKey: publicKey, keyID: keyID}, nil
+	}
+	return nil, errors.New("no ODoH configuration with supported version and cipher suite (X25519, HKDF-SHA256, AES-128-GCM)")
+}
+
+// readVector reads the vector with 2 bytes length prefix, the rest of the data is returned as well.
+func readVector(data []byte) (vector, rest []byte, ok bool) {
+	if len(data) < 2 || len(data) < 2+int(binary.BigEndian.Uint16(data)) {
+		return nil, nil, false
+	}
+	n := 2 + int(binary.BigEndian.Uint16(data))
+	return data[2:n], data[n:], true
+}
+
+func appendVector(b, vector []byte) []byte {
+	return append(binary.BigEndian.AppendUint16(b, uint16(len(vector))), vector...)
+}
+
+// odohClient sends the DNS queries encrypted for ODoH target through the oblivious proxy (RFC 9230). When directProbe is set,
+// the same encrypted query is sent directly to the target concurrently, so that the latency of the target can be compared with the latency through the proxy.
+type odohClient struct {
+	c           *http.Client
+	config      *odohConfig
+	proxyURL    string
+	targetURL   string
+	directProbe bool
+}
+
+// query is queryFunc sending the DNS query through the oblivious proxy.
+func (c *odohClient) query(ctx context.Context, _ string, msg *dns.Msg) (*dns.Msg, error) {
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	// ObliviousDoHMessagePlaintext with the DNS message padded to the block size
+	plaintext := appendVector(nil, packed)
+	plaintext = appendVector(plaintext, make([]byte, (odohPadBlock-len(packed)%odohPadBlock)%odohPadBlock))
+	enc, hpkeCtx, err := hpkeSetupBaseS(c.config.publicKey, []byte("odoh query"))
+	if err != nil {
+		return nil, err
+	}
+	aad := appendVector([]byte{odohQueryType}, c.config.keyID)
+	body := append(aad, appendVector(nil, append(enc, hpkeCtx.seal(aad, plaintext)...))...)
+	timing := odohTiming{crypto: time.Since(start)}
+
+	var probe chan time.Duration
+	if c.directProbe {
+		probe = make(chan time.Duration, 1)
+		go func() {
+			// the probe is not part of the request trace
+			probeStart := time.Now()
+			if _, err := c.post(withoutTraces(ctx), c.targetURL, body); err != nil {
+				probe <- 0
+				return
+			}
+			probe <- time.Since(probeStart)
+		}()
+	}
+
+	start = time.Now()
+	resp, err := c.post(ctx, c.proxyURL, body)
+	timing.proxied = time.Since(start)
+	if probe != nil {
+		timing.target = <-probe
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	start = time.Now()
+	r, err := c.decrypt(hpkeCtx, plaintext, resp)
+	timing.crypto += time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	if t := odohTimingFrom(ctx); t != nil {
+		*t = timing
+	}
+	return r, nil
+}
+
+func (c *odohClient) post(ctx context.Context, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", odohContentType)
+	req.Header.Set("Accept", odohContentType)
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, dohStatusError{code: resp.StatusCode}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize+maxODoHConfigsSize))
+}
+
+// decrypt decrypts the ODoH response using the key derived from the HPKE context of the query (RFC 9230, section 6.4).
+func (c *odohClient) decrypt(hpkeCtx *hpkeContext, plaintext, resp []byte) (*dns.Msg, error) {
+	if len(resp) < 1 || resp[0] != odohResponseType {
+		return nil, errors.New("unexpected ODoH response message type")
+	}
+	responseNonce, rest, ok := readVector(resp[1:])
+	if !ok {
+		return nil, errors.New("invalid ODoH response")
+	}
+	encrypted, _, ok := readVector(rest)
+	if !ok {
+		return nil, errors.New("invalid ODoH response")
+	}
+	aead, nonce, err := odohResponseAEAD(hpkeCtx, plaintext, responseNonce)
+	if err != nil {
+		return nil, err
+	}
+	decrypted, err := aead.Open(nil, nonce, encrypted, appendVector([]byte{odohResponseType}, responseNonce))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt ODoH response: %w", err)
+	}
+	packed, _, ok := readVector(decrypted)
+	if !ok {
+		return nil, errors.New("invalid ODoH response")
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(packed); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// odohResponseAEAD derives the key and nonce used to encrypt the response from the HPKE context of the query, the query plaintext
+// and the response nonce chosen by the target.
+func odohResponseAEAD(hpkeCtx *hpkeContext, plaintext, responseNonce []byte) (cipher.AEAD, []byte, error) {
+	secret := hpkeCtx.export([]byte("odoh response"), hpkeKeySize)
+	salt := appendVector(append([]byte{}, plaintext...), responseNonce)
+	prk := hkdf.Extract(sha256.New, secret, salt)
+	key := make([]byte, hpkeKeySize)
+	io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("odoh key")), key)
+	nonce := make([]byte, hpkeNonceSize)
+	io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("odoh nonce")), nonce)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, nonce, err
+}
@@ -0,0 +1,243 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/curve25519"
+)
+
+// Test_hpkeSetupBaseS verifies the HPKE implementation using the test vector of RFC 9180, appendix A.1.1.
+func Test_hpkeSetupBaseS(t *testing.T) {
+	skE, _ := hex.DecodeString("52c4a758a802cd8b936eceea314432798d5baf2d7e9235dc084ab1b9cfa2f736")
+	skR, _ := hex.DecodeString("4612c550263fc8ad58375df3f557aac531d26850903e55a9f23f21d8534e8ac8")
+	info, _ := hex.DecodeString("4f6465206f6e2061204772656369616e2055726e")
+	aad, _ := hex.DecodeString("436f756e742d30")
+	pt, _ := hex.DecodeString("4265617574792069732074727574682c20747275746820626561757479")
+	pkR, err := curve25519.X25519(skR, curve25519.Basepoint)
+	require.NoError(t, err)
+
+	enc, ctx, err := hpkeSetupBaseSWithKey(skE, pkR, info)
+
+	require.NoError(t, err)
+	assert.Equal(t, "37fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431", hex.EncodeToString(enc))
+	ct := ctx.seal(aad, pt)
+	assert.Equal(t, "f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a", hex.EncodeToString(ct))
+
+	receiver, err := hpkeSetupBaseR(enc, skR, info)
+	require.NoError(t, err)
+	decrypted, err := receiver.open(aad, ct)
+	require.NoError(t, err)
+	assert.Equal(t, pt, decrypted)
+	assert.Equal(t, ctx.export([]byte("context"), 32), receiver.export([]byte("context"), 32))
+}
+
+// odohTestTarget is ODoH target answering A queries.
+type odohTestTarget struct {
+	sk      []byte
+	config  []byte
+	queries atomic.Int32
+}
+
+func newODoHTestTarget(t *testing.T) *odohTestTarget {
+	sk := make([]byte, curve25519.ScalarSize)
+	_, err := rand.Read(sk)
+	require.NoError(t, err)
+	pk, err := curve25519.X25519(sk, curve25519.Basepoint)
+	require.NoError(t, err)
+
+	contents := binary.BigEndian.AppendUint16(nil, hpkeKEMX25519HKDFSHA256)
+	contents = binary.BigEndian.AppendUint16(contents, hpkeKDFHKDFSHA256)
+	contents = binary.BigEndian.AppendUint16(contents, hpkeAEADAES128GCM)
+	contents = appendVector(contents, pk)
+	// unsupported version is skipped
+	configs := appendVector(binary.BigEndian.AppendUint16(nil, 0xff06), []byte{1, 2, 3})
+	configs = appendVector(binary.BigEndian.AppendUint16(configs, odohVersion), contents)
+	return &odohTestTarget{sk: sk, config: appendVector(nil, configs)}
+}
+
+func (s *odohTestTarget) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == odohConfigsPath {
+		w.Write(s.config)
+		return
+	}
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != odohContentType {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.queries.Add(1)
+	body, _ := io.ReadAll(r.Body)
+	keyID, rest, _ := readVector(body[1:])
+	encrypted, _, _ := readVector(rest)
+	hpkeCtx, err := hpkeSetupBaseR(encrypted[:curve25519.PointSize], s.sk, []byte("odoh query"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	plaintext, err := hpkeCtx.open(appendVector([]byte{odohQueryType}, keyID), encrypted[curve25519.PointSize:])
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	packed, _, _ := readVector(plaintext)
+	req := dns.Msg{}
+	if err := req.Unpack(packed); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	resp := dns.Msg{}
+	resp.SetReply(&req)
+	resp.Answer = append(resp.Answer, &dns.A{Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.IPv4(127, 0, 0, 1)})
+	respPacked, _ := resp.Pack()
+
+	responseNonce := make([]byte, odohResponseNonceSize)
+	rand.Read(responseNonce)
+	aead, nonce, err := odohResponseAEAD(hpkeCtx, plaintext, responseNonce)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	respPlaintext := appendVector(appendVector(nil, respPacked), nil)
+	sealed := aead.Seal(nil, nonce, respPlaintext, appendVector([]byte{odohResponseType}, responseNonce))
+	w.Header().Set("Content-Type", odohContentType)
+	w.Write(appendVector(appendVector([]byte{odohResponseType}, responseNonce), sealed))
+}
+
+// odohTestProxy forwards ODoH queries to the target after the delay.
+type odohTestProxy struct {
+	delay time.Duration
+	c     *http.Client
+}
+
+func (p *odohTestProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(p.delay)
+	body, _ := io.ReadAll(r.Body)
+	target := "https://" + r.URL.Query().Get("targethost") + r.URL.Query().Get("targetpath")
+	resp, err := p.c.Post(target, r.Header.Get("Content-Type"), bytes.NewReader(body))
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func TestBenchmark_Run_odoh(t *testing.T) {
+	tests := []struct {
+		name        string
+		directProbe bool
+	}{
+		{
+			name: "through proxy",
+		},
+		{
+			name:        "through proxy with direct probes",
+			directProbe: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := newODoHTestTarget(t)
+			targetServer := httptest.NewTLSServer(target)
+			defer targetServer.Close()
+			// nolint:gosec
+			proxy := &odohTestProxy{delay: 20 * time.Millisecond, c: &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}}
+			proxyServer := httptest.NewTLSServer(proxy)
+			defer proxyServer.Close()
+
+			bench := Benchmark{
+				Server:          targetServer.URL + "/dns-query",
+				ODoHProxy:       proxyServer.URL + "/proxy",
+				ODoHDirectProbe: tt.directProbe,
+				Queries:         []string{"example.org"},
+				Types:           []string{"A"},
+				Concurrency:     1,
+				Count:           3,
+				Probability:     1,
+				WriteTimeout:    1 * time.Second,
+				ReadTimeout:     3 * time.Second,
+				ConnectTimeout:  1 * time.Second,
+				RequestTimeout:  5 * time.Second,
+				HistMax:         time.Second,
+				Insecure:        true,
+				Writer:          io.Discard,
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			assert.Equal(t, "https/1.1 (ODoH)", bench.network())
+			require.Len(t, rs, 1)
+			assert.EqualValues(t, 3, rs[0].Counters.Success)
+			o := rs[0].ODoH
+			require.NotNil(t, o)
+			assert.EqualValues(t, 3, o.Crypto.TotalCount())
+			assert.EqualValues(t, 3, o.Proxied.TotalCount())
+			assert.GreaterOrEqual(t, o.Proxied.Min(), (19 * time.Millisecond).Nanoseconds(), "delay of the proxy should be included")
+			if tt.directProbe {
+				assert.EqualValues(t, 6, target.queries.Load())
+				assert.EqualValues(t, 3, o.Target.TotalCount())
+				assert.EqualValues(t, 3, o.Proxy.TotalCount())
+				assert.GreaterOrEqual(t, o.Proxy.Min(), (15 * time.Millisecond).Nanoseconds(), "delay of the proxy should be attributed to the proxy")
+			} else {
+				assert.EqualValues(t, 3, target.queries.Load())
+				assert.EqualValues(t, 0, o.Target.TotalCount())
+			}
+		})
+	}
+}
+
+func TestBenchmark_init_odoh(t *testing.T) {
+	tests := []struct {
+		name         string
+		benchmark    Benchmark
+		wantProxyURL string
+		wantErr      bool
+	}{
+		{
+			name:         "ODoH proxy",
+			benchmark:    Benchmark{Server: "https://odoh.example.com", ODoHProxy: "https://proxy.example.com/proxy"},
+			wantProxyURL: "https://proxy.example.com/proxy?targethost=odoh.example.com&targetpath=%2Fdns-query",
+		},
+		{
+			name:      "ODoH target is not URL",
+			benchmark: Benchmark{Server: "127.0.0.1", ODoHProxy: "https://proxy.example.com/proxy"},
+			wantErr:   true,
+		},
+		{
+			name:      "ODoH with GET method",
+			benchmark: Benchmark{Server: "https://odoh.example.com", ODoHProxy: "https://proxy.example.com/proxy", DohMethod: GetHTTPMethod},
+			wantErr:   true,
+		},
+		{
+			name:      "ODoH proxy is not URL",
+			benchmark: Benchmark{Server: "https://odoh.example.com", ODoHProxy: "proxy.example.com"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.benchmark.init()
+
+			require.Equal(t, tt.wantErr, err != nil)
+			if !tt.wantErr {
+				assert.Equal(t, tt.wantProxyURL, tt.benchmark.odohProxyURL)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// Phase is a phase of the DNS request measured when Benchmark.PhaseTimings is configured.
+type Phase int
+
+const (
+	// PhaseDNSResolve is the resolution of the hostname of the benchmarked server, it is measured only when the server is not specified by IP address.
+	PhaseDNSResolve Phase = iota
+	// PhaseConnect is the establishment of the TCP connection (or connecting the UDP socket).
+	PhaseConnect
+	// PhaseTLSHandshake is the TLS handshake of DoT and DoH over HTTP/1.1 or HTTP/2.
+	PhaseTLSHandshake
+	// PhaseQUICHandshake is the QUIC handshake of DoQ and DoH over HTTP/3.
+	PhaseQUICHandshake
+	// PhaseFirstByte is the time from sending the query over the established connection until the first byte of the response is received.
+	PhaseFirstByte
+
+	numPhases = int(PhaseFirstByte) + 1
+)
+
+// Phases lists all phases in the order in which they happen.
+var Phases = []Phase{PhaseDNSResolve, PhaseConnect, PhaseTLSHandshake, PhaseQUICHandshake, PhaseFirstByte}
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseDNSResolve:
+		return "DNS resolve"
+	case PhaseConnect:
+		return "TCP connect"
+	case PhaseTLSHandshake:
+		return "TLS handshake"
+	case PhaseQUICHandshake:
+		return "QUIC handshake"
+	case PhaseFirstByte:
+		return "first byte"
+	default:
+		return "unknown"
+	}
+}
+
+// PhaseHistograms are histograms of durations of the request phases. Requests which opened new connection are kept separately
+// from the requests which reused already established connection, the reused connections have only PhaseFirstByte measured.
+type PhaseHistograms struct {
+	NewConn    map[Phase]*hdrhistogram.Histogram
+	ReusedConn map[Phase]*hdrhistogram.Histogram
+}
+
+// phaseTrace collects the durations of the phases of single DNS request. The phases may be recorded by the goroutines dialing
+// the connections shared by multiple requests, so the trace is safe for concurrent use.
+type phaseTrace struct {
+	mu        sync.Mutex
+	newConn   bool
+	connected time.Time
+	durations [numPhases]time.Duration
+
+	// handshake is duration of the TLS or QUIC handshake of the connection opened by the request.
+	handshake time.Duration
+	resumed   bool
+	used0RTT  bool
+	// handshakeWait is closed when the QUIC handshake finished after the query was sent in 0-RTT data.
+	handshakeWait chan struct{}
+}
+
+type phaseTraceKey struct{}
+
+func withPhaseTrace(ctx context.Context, trace *phaseTrace) context.Context {
+	return context.WithValue(ctx, phaseTraceKey{}, trace)
+}
+
+func phaseTraceFrom(ctx context.Context) *phaseTrace {
+	trace, _ := ctx.Value(phaseTraceKey{}).(*phaseTrace)
+	return trace
+}
+
+func (t *phaseTrace) record(p Phase, start time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.durations[p] = time.Since(start)
+}
+
+// dialing marks the request as the one, which opened (or attempted to open) new connection.
+func (t *phaseTrace) dialing() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.newConn = true
+}
+
+// openedConn returns true if the request opened (or attempted to open) new connection.
+func (t *phaseTrace) openedConn() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.newConn
+}
+
+// connectionEstablished records when the connection opened by the request was established.
+func (t *phaseTrace) connectionEstablished() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.connected = time.Now()
+}
+
+// firstByte records PhaseFirstByte for the query sent at start, the time spent establishing the connection is not included.
+func (t *phaseTrace) firstByte(start time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.connected.After(start) {
+		start = t.connected
+	}
+	t.durations[PhaseFirstByte] = time.Since(start)
+}
+
+// recordTrace records the phases, the handshake and the connection of the traced request, the QUIC handshake finishing after 0-RTT query is awaited.
+func (rs *ResultStats) recordTrace(trace *phaseTrace, err error, duration time.Duration) {
+	trace.mu.Lock()
+	wait := trace.handshakeWait
+	trace.mu.Unlock()
+	if wait != nil {
+		<-wait
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	rs.recordHandshake(trace)
+	rs.recordPhases(trace)
+	rs.recordConn(trace, err, duration)
+}
+
+func (rs *ResultStats) recordPhases(trace *phaseTrace) {
+	if rs.PhaseHists == nil {
+		return
+	}
+	hists := rs.PhaseHists.ReusedConn
+	if trace.newConn {
+		hists = rs.PhaseHists.NewConn
+	}
+	for p, d := range trace.durations {
+		if d <= 0 {
+			continue
+		}
+		h, ok := hists[Phase(p)]
+		if !ok {
+			h = hdrhistogram.New(rs.Hist.LowestTrackableValue(), rs.Hist.HighestTrackableValue(), int(rs.Hist.SignificantFigures()))
+			hists[Phase(p)] = h
+		}
+		h.RecordValue(d.Nanoseconds())
+	}
+}
+
+// resolve resolves the host of the address to IP address of the family configured by Benchmark.IPFamily, when the trace is present in the context,
+// so that the resolution is measured separately from the connection establishment, when the family is configured or when the address
+// is sent to socks5:// proxy. The address is returned unchanged otherwise.
+func (b *Benchmark) resolve(ctx context.Context, addr string) (string, error) {
+	trace := phaseTraceFrom(ctx)
+	if trace == nil && len(b.IPFamily) == 0 && b.proxy == nil {
+		return addr, nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if trace != nil {
+		trace.dialing()
+	}
+	if net.ParseIP(host) != nil {
+		return addr, nil
+	}
+	start := time.Now()
+	ips, err := net.DefaultResolver.LookupIP(ctx, b.lookupNetwork(), host)
+	if err != nil {
+		return "", err
+	}
+	if trace != nil {
+		trace.record(PhaseDNSResolve, start)
+	}
+	return net.JoinHostPort(ips[0].String(), port), nil
+}
+
+// dialNet dials the connection to the address and records the phases into the trace present in the context.
+// When Benchmark.Proxy is used, PhaseConnect includes the handshake with the proxy. The address is resolved by socks5h:// and HTTP proxies.
+func (b *Benchmark) dialNet(ctx context.Context, network, addr string) (net.Conn, error) {
+	trace := phaseTraceFrom(ctx)
+	if !b.proxyResolves() {
+		var err error
+		if addr, err = b.resolve(ctx, addr); err != nil {
+			return nil, err
+		}
+	} else if trace != nil {
+		trace.dialing()
+	}
+	start := time.Now()
+	conn, err := b.dialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if b.proxyProtocol != nil {
+		if err := b.sendProxyProtocolHeader(conn, addr); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if trace != nil {
+		trace.record(PhaseConnect, start)
+		trace.connectionEstablished()
+	}
+	return conn, nil
+}
+
+// dialTLS dials the TLS connection to the address and records the phases into the trace present in the context.
+func (b *Benchmark) dialTLS(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+	conn, err := b.dialNet(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	cfg = cfg.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	tlsConn := tls.Client(conn, cfg)
+	start := time.Now()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if trace := phaseTraceFrom(ctx); trace != nil {
+		trace.handshakeDone(PhaseTLSHandshake, start, tlsConn.ConnectionState(), false)
+		trace.connectionEstablished()
+	}
+	return tlsConn, nil
+}
+
+// dialQUIC dials the QUIC connection for DoH over HTTP/3. HTTP/3 client sends the requests after the handshake is finished,
+// so the handshake is awaited to be measured separately from the first request.
+func (b *Benchmark) dialQUIC(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+	addr, err := b.resolve(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	conn, err := b.dialQUICConn(ctx, addr, tlsCfg, cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	trace := phaseTraceFrom(ctx)
+	if trace == nil {
+		return conn, nil
+	}
+	select {
+	case <-conn.HandshakeComplete():
+	case <-conn.Context().Done():
+		return nil, context.Cause(conn.Context())
+	case <-ctx.Done():
+		conn.CloseWithError(0, "")
+		return nil, ctx.Err()
+	}
+	trace.handshakeDone(PhaseQUICHandshake, start, conn.ConnectionState().TLS, false)
+	trace.connectionEstablished()
+	trackQUICConn(ctx, conn)
+	return conn, nil
+}
+
+// dialDoQ dials the QUIC connection for DoQ. When Benchmark.ZeroRTT is configured, the connection is returned before the handshake
+// is finished, so that the query can be sent in 0-RTT data. Otherwise, the handshake is awaited and 0-RTT is not used.
+func (b *Benchmark) dialDoQ(ctx context.Context, addr string, tlsCfg *tls.Config) (quic.Connection, error) {
+	addr, err := b.resolve(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	trace := phaseTraceFrom(ctx)
+	start := time.Now()
+	if !b.ZeroRTT {
+		conn, err := b.dialQUICConn(ctx, addr, tlsCfg, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		if trace != nil {
+			trace.handshakeDone(PhaseQUICHandshake, start, conn.ConnectionState().TLS, false)
+			trace.connectionEstablished()
+		}
+		trackQUICConn(ctx, conn)
+		return conn, nil
+	}
+
+	conn, err := b.dialQUICConn(ctx, addr, tlsCfg, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	if trace != nil {
+		wait := make(chan struct{})
+		trace.mu.Lock()
+		trace.handshakeWait = wait
+		trace.mu.Unlock()
+		go func() {
+			defer close(wait)
+			select {
+			case <-conn.HandshakeComplete():
+				state := conn.ConnectionState()
+				trace.handshakeDone(PhaseQUICHandshake, start, state.TLS, state.Used0RTT)
+			case <-conn.Context().Done():
+			}
+		}()
+		trace.connectionEstablished()
+	}
+	trackQUICConn(ctx, conn)
+	return conn, nil
+}
+
+// dial dials the connection for plain DNS or DoT, the phases are measured only when the trace is present in the context.
+// The connection is dialed by the DNS client, unless the trace, TLS session resumption, proxy, PROXY protocol, source binding or socket options are used.
+func (b *Benchmark) dial(ctx context.Context, dnsClient *dns.Client) (*dns.Conn, error) {
+	if phaseTraceFrom(ctx) == nil && !b.TLSSessionResumption && !b.customDial() {
+		return dnsClient.DialContext(ctx, b.Server)
+	}
+	var conn net.Conn
+	var err error
+	if dnsClient.Net == TLSTransport {
+		conn, err = b.dialTLS(ctx, TCPTransport, b.Server, dnsClient.TLSConfig)
+	} else {
+		conn, err = b.dialNet(ctx, dnsClient.Net, b.Server)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dns.Conn{Conn: conn, UDPSize: dnsClient.UDPSize}, nil
+}
+
+// phaseRoundTripper records PhaseFirstByte of the DoH requests, the round trip ends when the response headers are received.
+type phaseRoundTripper struct {
+	http.RoundTripper
+}
+
+func (rt phaseRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := phaseTraceFrom(req.Context())
+	start := time.Now()
+	resp, err := rt.RoundTripper.RoundTrip(req)
+	if err == nil && trace != nil {
+		trace.firstByte(start)
+	}
+	return resp, err
+}
@@ -0,0 +1,224 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+)
+
+var errPipelineClosed = errors.New("pipelined connection closed")
+
+type pipelineResult struct {
+	msg *dns.Msg
+	err error
+}
+
+// pipelineQuery is in-flight query waiting for the response.
+type pipelineQuery struct {
+	ch   chan pipelineResult
+	sent time.Time
+}
+
+// pipelineConn is single TCP or DoT connection with in-flight queries waiting for the responses.
+type pipelineConn struct {
+	co      *dns.Conn
+	pending map[uint16]pipelineQuery
+	retired bool
+	conns   *connTracker
+}
+
+// pipelinedConn sends multiple DNS queries over single TCP or DoT connection without waiting for the responses of
+// the previous queries, the responses are matched to the queries by ID and can arrive out of order (RFC 7766).
+type pipelinedConn struct {
+	b         *Benchmark
+	dnsClient *dns.Client
+
+	mu   sync.Mutex
+	conn *pipelineConn
+	// dialing is closed, when the connection being dialed without holding mu is established or the dial failed.
+	dialing chan struct{}
+	sent    int64
+	depth   *hdrhistogram.Histogram
+}
+
+func (b *Benchmark) newPipelinedConn() *pipelinedConn {
+	return &pipelinedConn{
+		b:         b,
+		dnsClient: b.getDNSClient(),
+		depth:     hdrhistogram.New(1, int64(b.Pipeline), 3),
+	}
+}
+
+// query is queryFunc safe for concurrent use. When the ID of the message collides with ID of the in-flight query, the message ID is changed.
+func (p *pipelinedConn) query(ctx context.Context, _ string, msg *dns.Msg) (*dns.Msg, error) {
+	c, err := p.activeConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.sent++
+
+	for _, ok := c.pending[msg.Id]; ok; _, ok = c.pending[msg.Id] {
+		msg.Id = dns.Id()
+	}
+	ch := make(chan pipelineResult, 1)
+	start := time.Now()
+	c.pending[msg.Id] = pipelineQuery{ch: ch, sent: start}
+	p.depth.RecordValue(int64(len(c.pending)))
+	if len(c.pending) == 1 {
+		p.setReadDeadline(c)
+	}
+
+	writeDeadline := start.Add(p.b.exchangeTimeout(p.b.WriteTimeout))
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(writeDeadline) {
+		writeDeadline = deadline
+	}
+	if err := c.co.SetWriteDeadline(writeDeadline); err != nil {
+		p.fail(c, err)
+		p.mu.Unlock()
+		return nil, err
+	}
+	if err := c.co.WriteMsg(msg); err != nil {
+		p.fail(c, err)
+		p.mu.Unlock()
+		return nil, err
+	}
+	p.mu.Unlock()
+
+	select {
+	case res := <-ch:
+		if trace := phaseTraceFrom(ctx); trace != nil && res.err == nil {
+			trace.firstByte(start)
+		}
+		return res.msg, res.err
+	case <-ctx.Done():
+		p.mu.Lock()
+		// the read deadline is kept, so that the connection fails, unless any response is received until the deadline of the abandoned query
+		delete(c.pending, msg.Id)
+		p.closeIfDrained(c)
+		p.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// activeConn returns the connection, over which the next query is sent, with mu held. New connection is dialed without holding mu,
+// so that the queries sent over the previous connection can be answered meanwhile, other callers wait for the dial to finish.
+func (p *pipelinedConn) activeConn(ctx context.Context) (*pipelineConn, error) {
+	p.mu.Lock()
+	for {
+		if p.conn != nil && p.b.rotateConn(p.sent) {
+			p.retire(p.conn)
+		}
+		if p.conn != nil {
+			return p.conn, nil
+		}
+		if p.dialing == nil {
+			break
+		}
+		dialing := p.dialing
+		p.mu.Unlock()
+		select {
+		case <-dialing:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		p.mu.Lock()
+	}
+
+	dialing := make(chan struct{})
+	p.dialing = dialing
+	p.mu.Unlock()
+	co, err := p.b.dial(ctx, p.dnsClient)
+	p.mu.Lock()
+	p.dialing = nil
+	close(dialing)
+	if err != nil {
+		p.mu.Unlock()
+		return nil, err
+	}
+	p.conn = &pipelineConn{co: co, pending: make(map[uint16]pipelineQuery), conns: connTrackerFrom(ctx)}
+	go p.readLoop(p.conn)
+	return p.conn, nil
+}
+
+// setReadDeadline sets the read deadline of the connection, so that the response of the oldest in-flight query is awaited
+// at most for the exchange timeout, the connection fails with all its in-flight queries when the deadline elapses.
+// The deadline is cleared, when there are no in-flight queries.
+func (p *pipelinedConn) setReadDeadline(c *pipelineConn) {
+	var oldest time.Time
+	for _, q := range c.pending {
+		if oldest.IsZero() || q.sent.Before(oldest) {
+			oldest = q.sent
+		}
+	}
+	var deadline time.Time
+	if !oldest.IsZero() {
+		deadline = oldest.Add(p.b.exchangeTimeout(p.b.ReadTimeout))
+	}
+	c.co.SetReadDeadline(deadline)
+}
+
+func (p *pipelinedConn) readLoop(c *pipelineConn) {
+	for {
+		msg, err := c.co.ReadMsg()
+		p.mu.Lock()
+		if err != nil {
+			p.fail(c, err)
+			p.mu.Unlock()
+			return
+		}
+		if q, ok := c.pending[msg.Id]; ok {
+			delete(c.pending, msg.Id)
+			q.ch <- pipelineResult{msg: msg}
+			p.setReadDeadline(c)
+		}
+		p.closeIfDrained(c)
+		p.mu.Unlock()
+	}
+}
+
+// retire stops sending new queries over the connection, the connection is closed once all in-flight queries are answered.
+func (p *pipelinedConn) retire(c *pipelineConn) {
+	c.retired = true
+	if p.conn == c {
+		p.conn = nil
+	}
+	p.closeIfDrained(c)
+}
+
+func (p *pipelinedConn) closeIfDrained(c *pipelineConn) {
+	if c.retired && len(c.pending) == 0 {
+		c.co.Close()
+	}
+}
+
+// fail closes the connection and fails all its in-flight queries.
+func (p *pipelinedConn) fail(c *pipelineConn, err error) {
+	if c.retired && len(c.pending) == 0 {
+		// connection was closed after all queries were answered
+		return
+	}
+	if err != errPipelineClosed {
+		c.conns.closed(err)
+	}
+	for id, q := range c.pending {
+		q.ch <- pipelineResult{err: err}
+		delete(c.pending, id)
+	}
+	c.retired = true
+	if p.conn == c {
+		p.conn = nil
+	}
+	c.co.Close()
+}
+
+func (p *pipelinedConn) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.fail(p.conn, errPipelineClosed)
+	}
+}
@@ -0,0 +1,411 @@
+package dnsbench
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schemes of the proxy URLs supported in Benchmark.Proxy.
+const (
+	SOCKS5ProxyScheme  = "socks5"
+	SOCKS5HProxyScheme = "socks5h"
+	HTTPProxyScheme    = "http"
+)
+
+// https://www.rfc-editor.org/rfc/rfc1928 and https://www.rfc-editor.org/rfc/rfc1929
+const (
+	socks5Version          = 0x05
+	socks5AuthNone         = 0x00
+	socks5AuthUserPass     = 0x02
+	socks5AuthNoAcceptable = 0xff
+	socks5UserPassVersion  = 0x01
+	socks5CmdConnect       = 0x01
+	socks5CmdUDPAssociate  = 0x03
+	socks5AddrIPv4         = 0x01
+	socks5AddrDomain       = 0x03
+	socks5AddrIPv6         = 0x04
+	socks5DefaultPort      = "1080"
+	httpProxyDefaultPort   = "80"
+	socks5UDPHeaderMaxSize = 262
+	socks5ReplySucceeded   = 0x00
+)
+
+var socks5Replies = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// initProxy parses and validates Benchmark.Proxy. Plain DNS over UDP can be sent only through SOCKS5 proxy using UDP ASSOCIATE command,
+// the QUIC based protocols cannot be sent through the proxy at all.
+func (b *Benchmark) initProxy() error {
+	proxyURL, err := url.Parse(b.Proxy)
+	if err != nil {
+		return fmt.Errorf("--proxy '%s' is not valid URL: %w", b.Proxy, err)
+	}
+	var defaultPort string
+	switch proxyURL.Scheme {
+	case SOCKS5ProxyScheme, SOCKS5HProxyScheme:
+		defaultPort = socks5DefaultPort
+	case HTTPProxyScheme:
+		defaultPort = httpProxyDefaultPort
+	default:
+		return fmt.Errorf("--proxy '%s' is not supported, only socks5://, socks5h:// and http:// proxies are supported", proxyURL.Redacted())
+	}
+	if len(proxyURL.Hostname()) == 0 {
+		return fmt.Errorf("--proxy '%s' does not contain host of the proxy", proxyURL.Redacted())
+	}
+	if len(proxyURL.Port()) == 0 {
+		proxyURL.Host = net.JoinHostPort(proxyURL.Hostname(), defaultPort)
+	}
+
+	if b.useQuic || b.useDoH && b.DohProtocol == HTTP3Proto {
+		return errors.New("--proxy is not supported for DoQ and DoH over HTTP/3")
+	}
+	if b.UDPEngine {
+		return errors.New("--proxy cannot be combined with --udp-engine")
+	}
+	if !b.useDoH && !b.TCP && !b.DOT && proxyURL.Scheme == HTTPProxyScheme {
+		return errors.New("plain DNS over UDP can be sent only through socks5:// proxy, use --tcp to send the queries through HTTP proxy")
+	}
+	b.proxy = proxyURL
+	return nil
+}
+
+// customDial returns true if the connections have to be dialed by Benchmark.dialNet, instead of the default dialers of the DNS and HTTP clients.
+func (b *Benchmark) customDial() bool {
+	return b.proxy != nil || b.proxyProtocol != nil || b.sourceBinding() || b.socketTuning()
+}
+
+// proxyResolves returns true if the host names are sent unresolved to the proxy configured in Benchmark.Proxy,
+// which is the case of socks5h:// and HTTP proxies. The host names are resolved locally for socks5:// proxies
+// and when PROXY protocol header is sent, so that the header carries the address of the server, not of the proxy.
+func (b *Benchmark) proxyResolves() bool {
+	return b.proxy != nil && b.proxy.Scheme != SOCKS5ProxyScheme && b.proxyProtocol == nil
+}
+
+// dialContext dials the connection to the address, either directly or through the proxy configured in Benchmark.Proxy.
+// The host name of the address is resolved by the proxy, when it is not resolved by the caller.
+func (b *Benchmark) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if b.proxy == nil {
+		return b.dialSource(ctx, network, addr)
+	}
+
+	conn, err := b.dialSource(ctx, TCPTransport, b.proxy.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy: %w", err)
+	}
+	if b.ConnectTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(b.ConnectTimeout))
+	}
+	// unblocks the handshake with the proxy when the context is done
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+
+	var proxied net.Conn
+	switch {
+	case b.proxy.Scheme == HTTPProxyScheme:
+		proxied, err = httpConnect(conn, addr, b.proxy.User)
+	case strings.HasPrefix(network, UDPTransport):
+		proxied, err = b.socks5Associate(ctx, conn, addr)
+	default:
+		proxied, err = socks5Connect(conn, addr, b.proxy.User)
+	}
+	if !stop() {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		if proxied != nil {
+			proxied.Close()
+		}
+		return nil, fmt.Errorf("proxy %s failed to connect to %s: %w", b.proxy.Host, addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return proxied, nil
+}
+
+// httpConnect establishes the tunnel to the address through HTTP proxy using CONNECT method.
+func httpConnect(conn net.Conn, addr string, user *url.Userinfo) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user != nil {
+		password, _ := user.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	// the response is read byte by byte, so that no data following the response are consumed from the tunnel
+	r := bufio.NewReaderSize(oneByteReader{conn}, 16)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("CONNECT request failed with status %s", resp.Status)
+	}
+	return conn, nil
+}
+
+// oneByteReader reads at most one byte at a time from the underlying reader.
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (r oneByteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return r.r.Read(p)
+}
+
+// socks5Connect establishes the TCP connection to the address through SOCKS5 proxy using CONNECT command.
+func socks5Connect(conn net.Conn, addr string, user *url.Userinfo) (net.Conn, error) {
+	if err := socks5Authenticate(conn, user); err != nil {
+		return nil, err
+	}
+	if _, err := socks5Request(conn, socks5CmdConnect, addr); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// socks5Associate establishes UDP association with SOCKS5 proxy using UDP ASSOCIATE command. The returned connection sends the datagrams
+// to the address through the UDP relay of the proxy, the association is terminated when the connection is closed.
+func (b *Benchmark) socks5Associate(ctx context.Context, conn net.Conn, addr string) (net.Conn, error) {
+	if err := socks5Authenticate(conn, b.proxy.User); err != nil {
+		return nil, err
+	}
+	relay, err := socks5Request(conn, socks5CmdUDPAssociate, "0.0.0.0:0")
+	if err != nil {
+		return nil, err
+	}
+	relayHost, relayPort, err := net.SplitHostPort(relay)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(relayHost); ip != nil && ip.IsUnspecified() {
+		// the relay is listening on the same address as the proxy
+		relayHost, _, _ = net.SplitHostPort(conn.RemoteAddr().String())
+	}
+	header, err := appendSocks5Addr([]byte{0, 0, 0}, addr)
+	if err != nil {
+		return nil, err
+	}
+	udpConn, err := b.dialSource(ctx, UDPTransport, net.JoinHostPort(relayHost, relayPort))
+	if err != nil {
+		return nil, err
+	}
+	return &socks5PacketConn{Conn: udpConn, ctrl: conn, header: header}, nil
+}
+
+func socks5Authenticate(conn net.Conn, user *url.Userinfo) error {
+	methods := []byte{socks5AuthNone}
+	if user != nil {
+		methods = append(methods, socks5AuthUserPass)
+	}
+	if _, err := conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	resp := make([]byte, 2)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	if resp[0] != socks5Version {
+		return fmt.Errorf("unexpected SOCKS version %d", resp[0])
+	}
+	switch resp[1] {
+	case socks5AuthNone:
+		return nil
+	case socks5AuthUserPass:
+		if user == nil {
+			return errors.New("SOCKS5 proxy requires username and password")
+		}
+		password, _ := user.Password()
+		if len(user.Username()) > 255 || len(password) > 255 {
+			return errors.New("SOCKS5 username and password must not be longer than 255 bytes")
+		}
+		req := append([]byte{socks5UserPassVersion, byte(len(user.Username()))}, user.Username()...)
+		req = append(append(req, byte(len(password))), password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, resp); err != nil {
+			return err
+		}
+		if resp[1] != socks5ReplySucceeded {
+			return errors.New("SOCKS5 authentication failed")
+		}
+		return nil
+	case socks5AuthNoAcceptable:
+		return errors.New("SOCKS5 proxy accepts none of the offered authentication methods")
+	default:
+		return fmt.Errorf("SOCKS5 proxy selected unsupported authentication method %d", resp[1])
+	}
+}
+
+// socks5Request sends the request with the command and the address to SOCKS5 proxy and returns the address bound by the proxy.
+func socks5Request(conn net.Conn, cmd byte, addr string) (string, error) {
+	req, err := appendSocks5Addr([]byte{socks5Version, cmd, 0}, addr)
+	if err != nil {
+		return "", err
+	}
+	if _, err := conn.Write(req); err != nil {
+		return "", err
+	}
+	resp := make([]byte, 3)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return "", err
+	}
+	if resp[0] != socks5Version {
+		return "", fmt.Errorf("unexpected SOCKS version %d", resp[0])
+	}
+	if resp[1] != socks5ReplySucceeded {
+		if msg, ok := socks5Replies[resp[1]]; ok {
+			return "", errors.New(msg)
+		}
+		return "", fmt.Errorf("SOCKS5 request failed with reply %d", resp[1])
+	}
+	return readSocks5Addr(conn)
+}
+
+// appendSocks5Addr appends the address in SOCKS5 format, the host names are sent to the proxy unresolved.
+func appendSocks5Addr(b []byte, addr string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port of address '%s'", addr)
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip.To4() != nil:
+		b = append(append(b, socks5AddrIPv4), ip.To4()...)
+	case ip != nil:
+		b = append(append(b, socks5AddrIPv6), ip.To16()...)
+	default:
+		if len(host) > 255 {
+			return nil, fmt.Errorf("host name '%s' is too long", host)
+		}
+		b = append(append(b, socks5AddrDomain, byte(len(host))), host...)
+	}
+	return append(b, byte(port>>8), byte(port)), nil
+}
+
+func readSocks5Addr(r io.Reader) (string, error) {
+	atyp := make([]byte, 1)
+	if _, err := io.ReadFull(r, atyp); err != nil {
+		return "", err
+	}
+	var host []byte
+	switch atyp[0] {
+	case socks5AddrIPv4:
+		host = make([]byte, net.IPv4len)
+	case socks5AddrIPv6:
+		host = make([]byte, net.IPv6len)
+	case socks5AddrDomain:
+		hostLen := make([]byte, 1)
+		if _, err := io.ReadFull(r, hostLen); err != nil {
+			return "", err
+		}
+		host = make([]byte, hostLen[0])
+	default:
+		return "", fmt.Errorf("unsupported SOCKS5 address type %d", atyp[0])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, host); err != nil {
+		return "", err
+	}
+	if _, err := io.ReadFull(r, port); err != nil {
+		return "", err
+	}
+	hostStr := string(host)
+	if atyp[0] != socks5AddrDomain {
+		hostStr = net.IP(host).String()
+	}
+	return net.JoinHostPort(hostStr, strconv.Itoa(int(port[0])<<8|int(port[1]))), nil
+}
+
+// socks5PacketConn sends and receives the datagrams through the UDP relay of SOCKS5 proxy, each datagram is prefixed by SOCKS5 UDP request header.
+// It implements net.PacketConn, so that it is handled as UDP connection by the DNS client.
+type socks5PacketConn struct {
+	net.Conn
+	ctrl   net.Conn
+	header []byte
+	buf    []byte
+}
+
+func (c *socks5PacketConn) Write(p []byte) (int, error) {
+	if _, err := c.Conn.Write(append(c.header[:len(c.header):len(c.header)], p...)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *socks5PacketConn) Read(p []byte) (int, error) {
+	if c.buf == nil {
+		c.buf = make([]byte, socks5UDPHeaderMaxSize+65535)
+	}
+	for {
+		n, err := c.Conn.Read(c.buf)
+		if err != nil {
+			return 0, err
+		}
+		payload, ok := socks5UDPPayload(c.buf[:n])
+		if !ok {
+			// fragmented or malformed datagrams are dropped
+			continue
+		}
+		return copy(p, payload), nil
+	}
+}
+
+func socks5UDPPayload(datagram []byte) ([]byte, bool) {
+	if len(datagram) < 4 || datagram[2] != 0 {
+		return nil, false
+	}
+	r := bytes.NewReader(datagram[3:])
+	if _, err := readSocks5Addr(r); err != nil {
+		return nil, false
+	}
+	return datagram[len(datagram)-r.Len():], true
+}
+
+func (c *socks5PacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, err := c.Read(p)
+	return n, c.RemoteAddr(), err
+}
+
+func (c *socks5PacketConn) WriteTo(p []byte, _ net.Addr) (int, error) {
+	return c.Write(p)
+}
+
+func (c *socks5PacketConn) Close() error {
+	c.ctrl.Close()
+	return c.Conn.Close()
+}
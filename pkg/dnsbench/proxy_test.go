@@ -0,0 +1,368 @@
+package dnsbench
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testProxyUser     = "user"
+	testProxyPassword = "password"
+)
+
+// socks5TestProxy is SOCKS5 proxy supporting CONNECT and UDP ASSOCIATE commands, which requires username and password authentication.
+type socks5TestProxy struct {
+	ln         net.Listener
+	connects   atomic.Int32
+	associates atomic.Int32
+	// hostnames counts CONNECT requests with unresolved host name.
+	hostnames atomic.Int32
+}
+
+func newSOCKS5TestProxy(t *testing.T) *socks5TestProxy {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	p := &socks5TestProxy{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+func (p *socks5TestProxy) serve(conn net.Conn) {
+	defer conn.Close()
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(conn, greeting); err != nil {
+		return
+	}
+	methods := make([]byte, greeting[1])
+	if _, err := io.ReadFull(conn, methods); err != nil || !bytes.Contains(methods, []byte{socks5AuthUserPass}) {
+		conn.Write([]byte{socks5Version, socks5AuthNoAcceptable})
+		return
+	}
+	conn.Write([]byte{socks5Version, socks5AuthUserPass})
+	auth := make([]byte, 2)
+	if _, err := io.ReadFull(conn, auth); err != nil {
+		return
+	}
+	user := make([]byte, auth[1])
+	io.ReadFull(conn, user)
+	io.ReadFull(conn, auth[:1])
+	password := make([]byte, auth[0])
+	io.ReadFull(conn, password)
+	if string(user) != testProxyUser || string(password) != testProxyPassword {
+		conn.Write([]byte{socks5UserPassVersion, 1})
+		return
+	}
+	conn.Write([]byte{socks5UserPassVersion, socks5ReplySucceeded})
+
+	req := make([]byte, 3)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return
+	}
+	addr, err := readSocks5Addr(conn)
+	if err != nil {
+		return
+	}
+	switch req[1] {
+	case socks5CmdConnect:
+		p.connects.Add(1)
+		if host, _, _ := net.SplitHostPort(addr); net.ParseIP(host) == nil {
+			p.hostnames.Add(1)
+		}
+		target, err := net.Dial("tcp", addr)
+		if err != nil {
+			conn.Write([]byte{socks5Version, 0x05, 0, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		reply, _ := appendSocks5Addr([]byte{socks5Version, socks5ReplySucceeded, 0}, target.LocalAddr().String())
+		conn.Write(reply)
+		pipeTestConns(conn, target)
+	case socks5CmdUDPAssociate:
+		p.associates.Add(1)
+		relay, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			return
+		}
+		defer relay.Close()
+		_, port, _ := net.SplitHostPort(relay.LocalAddr().String())
+		// the relay is reported on unspecified address, the client uses the address of the proxy
+		reply, _ := appendSocks5Addr([]byte{socks5Version, socks5ReplySucceeded, 0}, net.JoinHostPort("0.0.0.0", port))
+		conn.Write(reply)
+		go relayTestDatagrams(relay)
+		// the association is terminated when the control connection is closed
+		io.Copy(io.Discard, conn)
+	}
+}
+
+// relayTestDatagrams relays the datagrams of single client, the datagrams from the client are prefixed by SOCKS5 UDP request header.
+func relayTestDatagrams(relay net.PacketConn) {
+	var client net.Addr
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		n, from, err := relay.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if client == nil || from.String() == client.String() {
+			client = from
+			r := bytes.NewReader(buf[3:n])
+			addr, err := readSocks5Addr(r)
+			if err != nil {
+				continue
+			}
+			target, err := net.ResolveUDPAddr("udp", addr)
+			if err != nil {
+				continue
+			}
+			relay.WriteTo(buf[n-r.Len():n], target)
+			continue
+		}
+		datagram, _ := appendSocks5Addr([]byte{0, 0, 0}, from.String())
+		relay.WriteTo(append(datagram, buf[:n]...), client)
+	}
+}
+
+// newHTTPTestProxy creates HTTP proxy supporting CONNECT method, which requires basic authentication.
+func newHTTPTestProxy(t *testing.T, connects *atomic.Int32) *httptest.Server {
+	credentials := "Basic " + base64.StdEncoding.EncodeToString([]byte(testProxyUser+":"+testProxyPassword))
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != credentials {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		connects.Add(1)
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		pipeTestConns(conn, target)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func pipeTestConns(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+	}()
+	io.Copy(b, a)
+	b.Close()
+}
+
+func newProxyTestDNSServer(t *testing.T, network string) string {
+	started := make(chan struct{})
+	s := &dns.Server{Net: network, Addr: "127.0.0.1:0", NotifyStartedFunc: func() { close(started) }, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := dns.Msg{}
+		resp.SetReply(r)
+		resp.Answer = append(resp.Answer, &dns.A{Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.IPv4(127, 0, 0, 1)})
+		w.WriteMsg(&resp)
+	})}
+	go s.ListenAndServe()
+	<-started
+	t.Cleanup(func() { s.Shutdown() })
+	if network == UDPTransport {
+		return s.PacketConn.LocalAddr().String()
+	}
+	return s.Listener.Addr().String()
+}
+
+func newProxyTestDoHServer(t *testing.T) string {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := dns.Msg{}
+		if err := req.Unpack(body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp := dns.Msg{}
+		resp.SetReply(&req)
+		resp.Answer = append(resp.Answer, &dns.A{Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.IPv4(127, 0, 0, 1)})
+		packed, _ := resp.Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	}))
+	s.EnableHTTP2 = true
+	s.StartTLS()
+	t.Cleanup(s.Close)
+	return s.URL
+}
+
+func TestBenchmark_Run_proxy(t *testing.T) {
+	socks5Proxy := newSOCKS5TestProxy(t)
+	var httpConnects atomic.Int32
+	httpProxy := newHTTPTestProxy(t, &httpConnects)
+	socks5URL := "socks5://" + testProxyUser + ":" + testProxyPassword + "@" + socks5Proxy.ln.Addr().String()
+	httpURL := "http://" + testProxyUser + ":" + testProxyPassword + "@" + httpProxy.Listener.Addr().String()
+
+	tests := []struct {
+		name         string
+		benchmark    Benchmark
+		server       func(t *testing.T) string
+		wantIOErrors bool
+		wantTunnels  func() int32
+	}{
+		{
+			name:        "plain DNS over UDP through SOCKS5 proxy",
+			benchmark:   Benchmark{Proxy: socks5URL},
+			server:      func(t *testing.T) string { return newProxyTestDNSServer(t, UDPTransport) },
+			wantTunnels: socks5Proxy.associates.Load,
+		},
+		{
+			name:        "plain DNS over TCP through SOCKS5 proxy with phase timings",
+			benchmark:   Benchmark{Proxy: socks5URL, TCP: true, PhaseTimings: true},
+			server:      func(t *testing.T) string { return newProxyTestDNSServer(t, TCPTransport) },
+			wantTunnels: socks5Proxy.connects.Load,
+		},
+		{
+			name:        "plain DNS over TCP through HTTP proxy",
+			benchmark:   Benchmark{Proxy: httpURL, TCP: true},
+			server:      func(t *testing.T) string { return newProxyTestDNSServer(t, TCPTransport) },
+			wantTunnels: httpConnects.Load,
+		},
+		{
+			name:        "DoH over HTTP/1.1 through HTTP proxy",
+			benchmark:   Benchmark{Proxy: httpURL, Insecure: true},
+			server:      newProxyTestDoHServer,
+			wantTunnels: httpConnects.Load,
+		},
+		{
+			name:        "DoH over HTTP/2 through SOCKS5 proxy",
+			benchmark:   Benchmark{Proxy: socks5URL, Insecure: true, DohProtocol: HTTP2Proto},
+			server:      newProxyTestDoHServer,
+			wantTunnels: socks5Proxy.connects.Load,
+		},
+		{
+			name:         "invalid proxy credentials",
+			benchmark:    Benchmark{Proxy: "socks5://" + testProxyUser + ":wrong@" + socks5Proxy.ln.Addr().String(), TCP: true},
+			server:       func(t *testing.T) string { return newProxyTestDNSServer(t, TCPTransport) },
+			wantIOErrors: true,
+			wantTunnels:  socks5Proxy.connects.Load,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tunnels := tt.wantTunnels()
+			bench := tt.benchmark
+			bench.Server = tt.server(t)
+			bench.Queries = []string{"example.org"}
+			bench.Types = []string{"A"}
+			bench.Concurrency = 2
+			bench.Count = 3
+			bench.Probability = 1
+			bench.WriteTimeout = time.Second
+			bench.ReadTimeout = 3 * time.Second
+			bench.ConnectTimeout = time.Second
+			bench.RequestTimeout = 5 * time.Second
+			bench.SeparateWorkerConnections = true
+			bench.Writer = io.Discard
+
+			rs, err := bench.Run(context.Background())
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			require.Len(t, rs, 2)
+			for _, r := range rs {
+				if tt.wantIOErrors {
+					assert.EqualValues(t, 3, r.Counters.IOError)
+					continue
+				}
+				assert.EqualValues(t, 3, r.Counters.Success)
+			}
+			if tt.wantIOErrors {
+				assert.EqualValues(t, tunnels, tt.wantTunnels(), "no tunnel should be established")
+			} else {
+				assert.EqualValues(t, tunnels+2, tt.wantTunnels(), "each worker should open single tunnel")
+			}
+		})
+	}
+}
+
+func TestBenchmark_Run_proxy_resolution(t *testing.T) {
+	tests := []struct {
+		name          string
+		scheme        string
+		ipFamily      string
+		wantHostnames int32
+	}{
+		{
+			name:   "resolved locally for SOCKS5 proxy",
+			scheme: SOCKS5ProxyScheme,
+		},
+		{
+			name:     "resolved locally for SOCKS5 proxy with IP family",
+			scheme:   SOCKS5ProxyScheme,
+			ipFamily: IPv4Family,
+		},
+		{
+			name:          "resolved by SOCKS5H proxy",
+			scheme:        SOCKS5HProxyScheme,
+			wantHostnames: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := newSOCKS5TestProxy(t)
+			_, port, err := net.SplitHostPort(newProxyTestDNSServer(t, TCPTransport))
+			require.NoError(t, err)
+
+			bench := Benchmark{
+				Server:                    net.JoinHostPort("localhost", port),
+				Proxy:                     tt.scheme + "://" + testProxyUser + ":" + testProxyPassword + "@" + proxy.ln.Addr().String(),
+				IPFamily:                  tt.ipFamily,
+				TCP:                       true,
+				Queries:                   []string{"example.org"},
+				Types:                     []string{"A"},
+				Concurrency:               2,
+				Count:                     3,
+				Probability:               1,
+				WriteTimeout:              time.Second,
+				ReadTimeout:               3 * time.Second,
+				ConnectTimeout:            time.Second,
+				RequestTimeout:            5 * time.Second,
+				SeparateWorkerConnections: true,
+				Writer:                    io.Discard,
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			require.Len(t, rs, 2)
+			for _, r := range rs {
+				assert.EqualValues(t, 3, r.Counters.Success)
+			}
+			assert.EqualValues(t, 2, proxy.connects.Load())
+			assert.Equal(t, tt.wantHostnames, proxy.hostnames.Load())
+		})
+	}
+}
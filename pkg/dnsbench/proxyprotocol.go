@@ -0,0 +1,152 @@
+package dnsbench
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// Versions of HAProxy PROXY protocol supported in Benchmark.ProxyProtocol.
+const (
+	ProxyProtocolV1 = "v1"
+	ProxyProtocolV2 = "v2"
+)
+
+// https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+const (
+	proxyProtocolV2Command     = 0x21
+	proxyProtocolV2TCPOverIPv4 = 0x11
+	proxyProtocolV2TCPOverIPv6 = 0x21
+	minSyntheticSourcePort     = 1024
+)
+
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyProtocol generates PROXY protocol headers with synthetic source addresses, which are sent at the beginning of the TCP connections.
+type proxyProtocol struct {
+	version string
+	ipv4    []*net.IPNet
+	ipv6    []*net.IPNet
+}
+
+// initProxyProtocol validates Benchmark.ProxyProtocol and parses the source addresses and prefixes of Benchmark.ProxyProtocolSources.
+func (b *Benchmark) initProxyProtocol() error {
+	if b.ProxyProtocol != ProxyProtocolV1 && b.ProxyProtocol != ProxyProtocolV2 {
+		return fmt.Errorf("--proxy-protocol '%s' is not supported, supported versions are v1 and v2", b.ProxyProtocol)
+	}
+	if b.useQuic || b.useDoH && b.DohProtocol == HTTP3Proto || !b.useDoH && !b.TCP && !b.DOT {
+		return errors.New("--proxy-protocol is supported only for plain DNS over TCP, DoT and DoH over HTTP/1.1 and HTTP/2")
+	}
+	if len(b.ProxyProtocolSources) == 0 {
+		return errors.New("--proxy-protocol requires at least one --proxy-protocol-source")
+	}
+	p := proxyProtocol{version: b.ProxyProtocol}
+	for _, s := range b.ProxyProtocolSources {
+		prefix, err := parseSourcePrefix(s)
+		if err != nil {
+			return err
+		}
+		if prefix.IP.To4() != nil {
+			p.ipv4 = append(p.ipv4, prefix)
+		} else {
+			p.ipv6 = append(p.ipv6, prefix)
+		}
+	}
+	b.proxyProtocol = &p
+	return nil
+}
+
+// parseSourcePrefix parses IP address or prefix, the IP address is returned as prefix containing only the address.
+func parseSourcePrefix(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, prefix, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("--proxy-protocol-source '%s' is not valid IP prefix", s)
+		}
+		return prefix, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("--proxy-protocol-source '%s' is not valid IP address", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(8*net.IPv4len, 8*net.IPv4len)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(8*net.IPv6len, 8*net.IPv6len)}, nil
+}
+
+// header returns PROXY protocol header for the connection to the destination address. The source address is random address
+// of randomly selected source prefix with the same address family as the destination, the source port is random as well.
+func (p *proxyProtocol) header(dst *net.TCPAddr) ([]byte, error) {
+	prefixes := p.ipv6
+	dstIP := dst.IP.To4()
+	if dstIP != nil {
+		prefixes = p.ipv4
+	} else {
+		dstIP = dst.IP.To16()
+	}
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("no --proxy-protocol-source has the same address family as the server address %s", dst)
+	}
+	src := &net.TCPAddr{IP: randomIP(prefixes[rand.Intn(len(prefixes))]), Port: minSyntheticSourcePort + rand.Intn(1<<16-minSyntheticSourcePort)}
+	if p.version == ProxyProtocolV1 {
+		return proxyProtocolV1Header(src, &net.TCPAddr{IP: dstIP, Port: dst.Port}), nil
+	}
+	return proxyProtocolV2Header(src, &net.TCPAddr{IP: dstIP, Port: dst.Port}), nil
+}
+
+func randomIP(prefix *net.IPNet) net.IP {
+	ip := make(net.IP, len(prefix.IP))
+	for i := range ip {
+		ip[i] = prefix.IP[i] | byte(rand.Intn(256))&^prefix.Mask[i]
+	}
+	return ip
+}
+
+// proxyProtocolV1Header returns human-readable header of PROXY protocol version 1, the addresses must have the same address family.
+func proxyProtocolV1Header(src, dst *net.TCPAddr) []byte {
+	family := "TCP6"
+	if src.IP.To4() != nil {
+		family = "TCP4"
+	}
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", family, src.IP, dst.IP, src.Port, dst.Port))
+}
+
+// proxyProtocolV2Header returns binary header of PROXY protocol version 2, the addresses must have the same address family.
+func proxyProtocolV2Header(src, dst *net.TCPAddr) []byte {
+	family := byte(proxyProtocolV2TCPOverIPv6)
+	if src.IP.To4() != nil {
+		family = proxyProtocolV2TCPOverIPv4
+	}
+	addrs := append(append([]byte{}, src.IP...), dst.IP...)
+	addrs = binary.BigEndian.AppendUint16(addrs, uint16(src.Port))
+	addrs = binary.BigEndian.AppendUint16(addrs, uint16(dst.Port))
+
+	h := append(append([]byte{}, proxyProtocolV2Signature...), proxyProtocolV2Command, family)
+	h = binary.BigEndian.AppendUint16(h, uint16(len(addrs)))
+	return append(h, addrs...)
+}
+
+// sendProxyProtocolHeader sends PROXY protocol header at the beginning of the TCP connection to the address. The destination address
+// of the header is the dialed address, or the remote address of the connection, when the host of the address was not resolved before dialing.
+// The host is always resolved before dialing through Benchmark.Proxy, so the remote address is used only for the direct connections.
+func (b *Benchmark) sendProxyProtocolHeader(conn net.Conn, addr string) error {
+	var dst *net.TCPAddr
+	if addrPort, err := netip.ParseAddrPort(addr); err == nil {
+		dst = net.TCPAddrFromAddrPort(addrPort)
+	} else if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		dst = tcpAddr
+	} else {
+		return fmt.Errorf("PROXY protocol header cannot be sent over connection to %s", addr)
+	}
+	header, err := b.proxyProtocol.header(dst)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(header)
+	return err
+}
@@ -0,0 +1,229 @@
+package dnsbench
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_proxyProtocolV1Header(t *testing.T) {
+	assert.Equal(t, "PROXY TCP4 192.0.2.1 127.0.0.1 40000 53\r\n",
+		string(proxyProtocolV1Header(&net.TCPAddr{IP: net.ParseIP("192.0.2.1").To4(), Port: 40000}, &net.TCPAddr{IP: net.ParseIP("127.0.0.1").To4(), Port: 53})))
+	assert.Equal(t, "PROXY TCP6 2001:db8::1 ::1 40000 853\r\n",
+		string(proxyProtocolV1Header(&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 40000}, &net.TCPAddr{IP: net.ParseIP("::1"), Port: 853})))
+}
+
+func Test_proxyProtocolV2Header(t *testing.T) {
+	ipv4 := proxyProtocolV2Header(&net.TCPAddr{IP: net.ParseIP("192.0.2.1").To4(), Port: 40000}, &net.TCPAddr{IP: net.ParseIP("127.0.0.1").To4(), Port: 53})
+	assert.Equal(t, "0d0a0d0a000d0a515549540a"+"21"+"11"+"000c"+"c0000201"+"7f000001"+"9c40"+"0035", hex.EncodeToString(ipv4))
+
+	ipv6 := proxyProtocolV2Header(&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 40000}, &net.TCPAddr{IP: net.ParseIP("::1"), Port: 853})
+	assert.Equal(t, "0d0a0d0a000d0a515549540a"+"21"+"21"+"0024"+"20010db8000000000000000000000001"+"00000000000000000000000000000001"+"9c40"+"0355",
+		hex.EncodeToString(ipv6))
+}
+
+func Test_proxyProtocol_header(t *testing.T) {
+	b := Benchmark{Server: "127.0.0.1", TCP: true, ProxyProtocol: ProxyProtocolV1, ProxyProtocolSources: []string{"198.51.100.0/24", "2001:db8::1"}}
+	require.NoError(t, b.init())
+
+	header, err := b.proxyProtocol.header(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53})
+	require.NoError(t, err)
+	assert.Regexp(t, `^PROXY TCP4 198\.51\.100\.\d+ 127\.0\.0\.1 \d+ 53\r\n$`, string(header))
+
+	header, err = b.proxyProtocol.header(&net.TCPAddr{IP: net.ParseIP("::1"), Port: 53})
+	require.NoError(t, err)
+	assert.Regexp(t, `^PROXY TCP6 2001:db8::1 ::1 \d+ 53\r\n$`, string(header))
+
+	b = Benchmark{Server: "127.0.0.1", TCP: true, ProxyProtocol: ProxyProtocolV2, ProxyProtocolSources: []string{"2001:db8::1"}}
+	require.NoError(t, b.init())
+	_, err = b.proxyProtocol.header(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53})
+	require.Error(t, err, "source address of the same address family as the server is required")
+}
+
+// proxyProtocolTestServer is DNS server over TCP, which expects PROXY protocol header at the beginning of each connection
+// and records the source and destination addresses of the headers.
+type proxyProtocolTestServer struct {
+	ln           net.Listener
+	mu           sync.Mutex
+	sources      []net.IP
+	destinations []string
+}
+
+func newProxyProtocolTestServer(t *testing.T) *proxyProtocolTestServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	s := &proxyProtocolTestServer{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *proxyProtocolTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	src, dst, err := readProxyProtocolAddrs(r)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.sources = append(s.sources, src)
+	s.destinations = append(s.destinations, dst)
+	s.mu.Unlock()
+
+	for {
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return
+		}
+		query := make([]byte, length)
+		if _, err := io.ReadFull(r, query); err != nil {
+			return
+		}
+		req := dns.Msg{}
+		if err := req.Unpack(query); err != nil {
+			return
+		}
+		resp := dns.Msg{}
+		resp.SetReply(&req)
+		resp.Answer = append(resp.Answer, &dns.A{Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.IPv4(127, 0, 0, 1)})
+		packed, _ := resp.Pack()
+		conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(packed))), packed...))
+	}
+}
+
+// readProxyProtocolAddrs reads PROXY protocol header and returns its source IP address and destination address.
+func readProxyProtocolAddrs(r *bufio.Reader) (net.IP, string, error) {
+	prefix, err := r.Peek(len(proxyProtocolV2Signature))
+	if err != nil {
+		return nil, "", err
+	}
+	if !bytes.Equal(prefix, proxyProtocolV2Signature) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, "", err
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 6 || fields[0] != "PROXY" {
+			return nil, "", io.ErrUnexpectedEOF
+		}
+		return net.ParseIP(fields[2]), net.JoinHostPort(fields[3], fields[5]), nil
+	}
+	header := make([]byte, len(proxyProtocolV2Signature)+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, "", err
+	}
+	addrs := make([]byte, binary.BigEndian.Uint16(header[len(header)-2:]))
+	if _, err := io.ReadFull(r, addrs); err != nil {
+		return nil, "", err
+	}
+	ipLen := net.IPv6len
+	if header[len(proxyProtocolV2Signature)+1] == proxyProtocolV2TCPOverIPv4 {
+		ipLen = net.IPv4len
+	}
+	dstPort := binary.BigEndian.Uint16(addrs[2*ipLen+2:])
+	return net.IP(addrs[:ipLen]), net.JoinHostPort(net.IP(addrs[ipLen:2*ipLen]).String(), strconv.Itoa(int(dstPort))), nil
+}
+
+func TestBenchmark_Run_proxy_protocol(t *testing.T) {
+	_, prefix, err := net.ParseCIDR("198.51.100.0/24")
+	require.NoError(t, err)
+	for _, version := range []string{ProxyProtocolV1, ProxyProtocolV2} {
+		t.Run(version, func(t *testing.T) {
+			s := newProxyProtocolTestServer(t)
+
+			bench := Benchmark{
+				Server:                    s.ln.Addr().String(),
+				TCP:                       true,
+				ProxyProtocol:             version,
+				ProxyProtocolSources:      []string{"198.51.100.0/24", "2001:db8::1"},
+				Queries:                   []string{"example.org"},
+				Types:                     []string{"A"},
+				Concurrency:               2,
+				Count:                     4,
+				QperConn:                  2,
+				Probability:               1,
+				WriteTimeout:              time.Second,
+				ReadTimeout:               3 * time.Second,
+				ConnectTimeout:            time.Second,
+				RequestTimeout:            5 * time.Second,
+				SeparateWorkerConnections: true,
+				Writer:                    io.Discard,
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			require.Len(t, rs, 2)
+			for _, r := range rs {
+				assert.EqualValues(t, 4, r.Counters.Success)
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			assert.Len(t, s.sources, 4, "each connection should send PROXY protocol header")
+			for _, src := range s.sources {
+				assert.True(t, prefix.Contains(src), "source address %s should be from the configured prefix", src)
+			}
+			for _, dst := range s.destinations {
+				assert.Equal(t, s.ln.Addr().String(), dst)
+			}
+		})
+	}
+}
+
+func TestBenchmark_Run_proxy_protocol_through_proxy(t *testing.T) {
+	proxy := newSOCKS5TestProxy(t)
+	s := newProxyProtocolTestServer(t)
+	_, port, err := net.SplitHostPort(s.ln.Addr().String())
+	require.NoError(t, err)
+
+	bench := Benchmark{
+		Server:               net.JoinHostPort("localhost", port),
+		Proxy:                "socks5h://" + testProxyUser + ":" + testProxyPassword + "@" + proxy.ln.Addr().String(),
+		TCP:                  true,
+		ProxyProtocol:        ProxyProtocolV2,
+		ProxyProtocolSources: []string{"198.51.100.0/24"},
+		Queries:              []string{"example.org"},
+		Types:                []string{"A"},
+		Concurrency:          1,
+		Count:                2,
+		Probability:          1,
+		WriteTimeout:         time.Second,
+		ReadTimeout:          3 * time.Second,
+		ConnectTimeout:       time.Second,
+		RequestTimeout:       5 * time.Second,
+		Writer:               io.Discard,
+	}
+
+	rs, err := bench.Run(context.Background())
+
+	require.NoError(t, err, "expected no error from benchmark run")
+	require.Len(t, rs, 1)
+	assert.EqualValues(t, 2, rs[0].Counters.Success)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	require.Len(t, s.destinations, 1)
+	// the destination is the server resolved locally, not the proxy
+	assert.Equal(t, s.ln.Addr().String(), s.destinations[0])
+	assert.Zero(t, proxy.hostnames.Load(), "server host name should be resolved locally")
+}
@@ -0,0 +1,89 @@
+package dnsbench
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiterSlack is number of requests, which can be sent immediately after the limiter was not used for a while.
+const rateLimiterSlack = 10
+
+// rateLimiter limits the rate of the DNS requests. Unlike go.uber.org/ratelimit, waiting for the permission is context aware
+// and does not require spawning goroutine for each request.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rate int) *rateLimiter {
+	return &rateLimiter{interval: time.Second / time.Duration(rate)}
+}
+
+// reserve reserves the permission for single request and returns how long the caller has to wait before sending the request.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.next.IsZero() {
+		l.next = now
+	}
+	if earliest := now.Add(-rateLimiterSlack * l.interval); l.next.Before(earliest) {
+		l.next = earliest
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	return wait
+}
+
+// tryReserve reserves the permission without waiting, false is returned if the permission is not available now.
+// Unlike reserve, the permissions are not accumulated when the limiter is not used.
+func (l *rateLimiter) tryReserve() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.next.After(now) {
+		return false
+	}
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(l.interval)
+	return true
+}
+
+// wait blocks until the request can be sent or the context is done. The timer is reused between the calls to avoid allocations,
+// it must be stopped and drained before the call.
+func (l *rateLimiter) wait(ctx context.Context, timer *time.Timer) error {
+	return sleep(ctx, timer, l.reserve())
+}
+
+// sleep blocks for the duration or until the context is done, the timer must be stopped and drained before the call and is stopped and drained on return.
+func sleep(ctx context.Context, timer *time.Timer, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer.Reset(d)
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// newStoppedTimer returns timer, which can be used with sleep.
+func newStoppedTimer() *time.Timer {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	return timer
+}
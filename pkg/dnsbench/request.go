@@ -0,0 +1,205 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	msgHeaderLen = 12
+
+	flagsRD = 0x01
+	flagsAD = 0x20
+	flagsCD = 0x10
+)
+
+// requestTemplate is DNS request for single question and query type prepared once before the benchmark starts,
+// so that only the ID, header flags and letter case of the question name are changed for each request.
+type requestTemplate struct {
+	msg dns.Msg
+	// packed is wire format of msg, nil if the msg cannot be packed or the question name cannot be patched in the wire format.
+	packed []byte
+}
+
+// newRequestTemplates prepares request templates for all combinations of questions and query types in the order
+// in which they are sent by the benchmark workers.
+func (b *Benchmark) newRequestTemplates(questions []string, qTypes []uint16) []requestTemplate {
+	var edns0 *dns.OPT
+	if b.Edns0 > 0 || b.ednsOpt != nil || b.DNSSEC || b.DNSSECValidation || b.TCPKeepalive {
+		edns0 = &dns.OPT{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeOPT}}
+		edns0.SetUDPSize(DefaultEdns0BufferSize)
+		if b.Edns0 > 0 {
+			edns0.SetUDPSize(b.Edns0)
+		}
+		if b.ednsOpt != nil {
+			edns0.Option = append(edns0.Option, b.ednsOpt)
+		}
+		if b.TCPKeepalive {
+			// the client sends the option without the timeout (RFC 7828)
+			edns0.Option = append(edns0.Option, &dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE})
+		}
+		if b.DNSSEC || b.DNSSECValidation {
+			edns0.SetDo(true)
+		}
+	}
+
+	templates := make([]requestTemplate, 0, len(questions)*len(qTypes))
+	for _, q := range questions {
+		for _, qt := range qTypes {
+			t := requestTemplate{}
+			t.msg.Opcode = b.opcode
+			t.msg.RecursionDesired = b.Recurse
+			t.msg.CheckingDisabled = b.CheckingDisabled
+			t.msg.AuthenticatedData = b.AuthenticatedData
+			t.msg.Question = []dns.Question{{Name: q, Qtype: qt, Qclass: b.qclass}}
+			if edns0 != nil {
+				t.msg.Extra = []dns.RR{edns0}
+			}
+			if packed, err := t.msg.Pack(); err == nil && !strings.Contains(q, `\`) {
+				t.packed = packed
+			}
+			templates = append(templates, t)
+		}
+	}
+	return templates
+}
+
+// newRequest returns new request based on the template. The returned request shares the question and EDNS0 records with the template,
+// so they must not be modified.
+func (b *Benchmark) newRequest(t *requestTemplate, rando *rand.Rand) dns.Msg {
+	req := t.msg
+	b.randomizeFlags(&req, rando)
+	if b.CaseRandomization {
+		question := req.Question[0]
+		question.Name = randomizeCase(question.Name, rando)
+		req.Question = []dns.Question{question}
+	}
+	if !b.useQuic {
+		req.Id = uint16(rando.Uint32())
+	}
+	return req
+}
+
+// packRequest writes wire format of the request created from the template into buf by patching the packed template,
+// nil is returned if the template cannot be patched.
+func packRequest(req *dns.Msg, t *requestTemplate, buf []byte) []byte {
+	if t.packed == nil {
+		return nil
+	}
+	buf = append(buf[:0], t.packed...)
+	binary.BigEndian.PutUint16(buf, req.Id)
+
+	buf[2] &^= flagsRD
+	if req.RecursionDesired {
+		buf[2] |= flagsRD
+	}
+	buf[3] &^= flagsAD | flagsCD
+	if req.AuthenticatedData {
+		buf[3] |= flagsAD
+	}
+	if req.CheckingDisabled {
+		buf[3] |= flagsCD
+	}
+
+	if name := req.Question[0].Name; name != t.msg.Question[0].Name {
+		// the letter case of the name was randomized, labels of the name follow the 12 bytes long header
+		off, i := msgHeaderLen, 0
+		for l := int(buf[off]); l != 0; l = int(buf[off]) {
+			off++
+			copy(buf[off:off+l], name[i:i+l])
+			off += l
+			i += l + 1
+		}
+	}
+	return buf
+}
+
+// exchangePacked sends the packed request over the connection and reads the response the same way as dns.Client.ExchangeWithConnContext does,
+// but without packing the request and with reusable read buffer. Unidirectional DSO messages preceding the response are handled, when the DSO session is given.
+func (b *Benchmark) exchangePacked(ctx context.Context, co *dns.Conn, req *dns.Msg, packed []byte, buf []byte, dso *dsoSession) (*dns.Msg, error) {
+	t := time.Now()
+	writeDeadline := t.Add(b.exchangeTimeout(b.WriteTimeout))
+	readDeadline := t.Add(b.exchangeTimeout(b.ReadTimeout))
+	if deadline, ok := ctx.Deadline(); ok {
+		if deadline.Before(writeDeadline) {
+			writeDeadline = deadline
+		}
+		if deadline.Before(readDeadline) {
+			readDeadline = deadline
+		}
+	}
+	co.SetWriteDeadline(writeDeadline)
+	co.SetReadDeadline(readDeadline)
+
+	_, isPacketConn := co.Conn.(net.PacketConn)
+	if isPacketConn {
+		if _, err := co.Conn.Write(packed); err != nil {
+			return nil, err
+		}
+	} else {
+		// buf is used for the length prefixed request first, then for the response
+		buf = binary.BigEndian.AppendUint16(buf[:0], uint16(len(packed)))
+		buf = append(buf, packed...)
+		if _, err := co.Conn.Write(buf); err != nil {
+			return nil, err
+		}
+	}
+	buf = buf[:cap(buf)]
+
+	for {
+		n, err := readPacked(co.Conn, isPacketConn, buf)
+		if err != nil {
+			return nil, err
+		}
+		if dso != nil && n >= msgHeaderLen && dsoMessageOpcode(buf[:n]) == dsoOpcode {
+			// the server may send unidirectional DSO messages over the session at any time
+			if err := dso.unidirectional(ctx, buf[:n]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		r := new(dns.Msg)
+		if err := r.Unpack(buf[:n]); err != nil {
+			return r, err
+		}
+		if r.Id == req.Id {
+			return r, nil
+		}
+		if !isPacketConn {
+			return r, dns.ErrId
+		}
+		// Ignore replies with mismatched IDs because they might be responses to earlier queries that timed out.
+	}
+}
+
+func readPacked(conn net.Conn, isPacketConn bool, buf []byte) (int, error) {
+	if isPacketConn {
+		return conn.Read(buf)
+	}
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return 0, err
+	}
+	length := int(binary.BigEndian.Uint16(buf))
+	if length > len(buf) {
+		return 0, io.ErrShortBuffer
+	}
+	return io.ReadFull(conn, buf[:length])
+}
+
+// exchangeTimeout returns timeout for writing or reading the DNS message, request timeout has priority as in dns.Client.
+func (b *Benchmark) exchangeTimeout(timeout time.Duration) time.Duration {
+	if b.RequestTimeout != 0 {
+		return b.RequestTimeout
+	}
+	if timeout != 0 {
+		return timeout
+	}
+	return 2 * time.Second
+}
@@ -0,0 +1,239 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/hex"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/ratelimit"
+)
+
+func Test_packRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		benchmark Benchmark
+		question  string
+	}{
+		{
+			name:      "plain",
+			benchmark: Benchmark{Server: "8.8.8.8", Recurse: true},
+			question:  "example.org.",
+		},
+		{
+			name:      "random flags",
+			benchmark: Benchmark{Server: "8.8.8.8", RandomFlags: []string{"rd", "cd", "ad"}},
+			question:  "example.org.",
+		},
+		{
+			name:      "0x20",
+			benchmark: Benchmark{Server: "8.8.8.8", CaseRandomization: true},
+			question:  "www.example.org.",
+		},
+		{
+			name:      "EDNS0",
+			benchmark: Benchmark{Server: "8.8.8.8", Edns0: 1024, EdnsOpt: "65518:fddddddd100000000000000000000001", DNSSEC: true},
+			question:  "example.org.",
+		},
+		{
+			name:      "root",
+			benchmark: Benchmark{Server: "8.8.8.8", CaseRandomization: true, Opcode: "NOTIFY", Class: "CH"},
+			question:  ".",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.benchmark.init())
+			templates := tt.benchmark.newRequestTemplates([]string{tt.question}, []uint16{dns.TypeA, dns.TypeAAAA})
+			require.Len(t, templates, 2)
+
+			// nolint:gosec
+			rando := rand.New(rand.NewSource(1))
+			var buf []byte
+			for i := 0; i < 10; i++ {
+				for ti := range templates {
+					req := tt.benchmark.newRequest(&templates[ti], rando)
+
+					buf = packRequest(&req, &templates[ti], buf)
+
+					want, err := req.Pack()
+					require.NoError(t, err)
+					assert.Equal(t, want, buf)
+				}
+			}
+		})
+	}
+}
+
+func Test_packRequest_escaped(t *testing.T) {
+	b := Benchmark{Server: "8.8.8.8", CaseRandomization: true}
+	require.NoError(t, b.init())
+	templates := b.newRequestTemplates([]string{`exa\.mple.org.`}, []uint16{dns.TypeA})
+
+	req := b.newRequest(&templates[0], rand.New(rand.NewSource(1))) // nolint:gosec
+
+	assert.Nil(t, packRequest(&req, &templates[0], nil))
+}
+
+func Test_rateLimiter(t *testing.T) {
+	l := newRateLimiter(100)
+	timer := newStoppedTimer()
+	start := time.Now()
+	for i := 0; i < 50; i++ {
+		require.NoError(t, l.wait(context.Background(), timer))
+	}
+	assert.InDelta(t, 490*time.Millisecond, time.Since(start), float64(100*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = newRateLimiter(1)
+	require.NoError(t, l.wait(context.Background(), timer))
+	assert.ErrorIs(t, l.wait(ctx, timer), context.Canceled)
+}
+
+func Test_rateLimiter_tryReserve(t *testing.T) {
+	l := newRateLimiter(10)
+	assert.True(t, l.tryReserve())
+	assert.False(t, l.tryReserve())
+
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, l.tryReserve())
+	assert.False(t, l.tryReserve())
+
+	// permissions are not accumulated while the limiter is not used
+	time.Sleep(300 * time.Millisecond)
+	assert.True(t, l.tryReserve())
+	assert.False(t, l.tryReserve())
+}
+
+func Benchmark_request(b *testing.B) {
+	bench := Benchmark{Server: "8.8.8.8", Recurse: true, Edns0: 1232, EdnsOpt: "65518:fddddddd100000000000000000000001"}
+	if err := bench.init(); err != nil {
+		b.Fatal(err)
+	}
+	// nolint:gosec
+	rando := rand.New(rand.NewSource(1))
+
+	b.Run("build and pack", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			req := dns.Msg{}
+			req.RecursionDesired = bench.Recurse
+			req.Question = []dns.Question{{Name: "example.org.", Qtype: dns.TypeA, Qclass: dns.ClassINET}}
+			req.Id = uint16(rando.Uint32())
+			req.SetEdns0(bench.Edns0, false)
+			s := strings.Split(bench.EdnsOpt, ":")
+			data, _ := hex.DecodeString(s[1])
+			code, _ := strconv.ParseUint(s[0], 10, 16)
+			req.IsEdns0().Option = append(req.IsEdns0().Option, &dns.EDNS0_LOCAL{Code: uint16(code), Data: data})
+			if _, err := req.Pack(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("template", func(b *testing.B) {
+		templates := bench.newRequestTemplates([]string{"example.org."}, []uint16{dns.TypeA})
+		var buf []byte
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			req := bench.newRequest(&templates[0], rando)
+			buf = packRequest(&req, &templates[0], buf)
+		}
+	})
+}
+
+func Benchmark_rateLimiter(b *testing.B) {
+	ctx := context.Background()
+	// the rate is low enough for each call to wait for the permission
+	rate := 10_000
+
+	b.Run("goroutine per take", func(b *testing.B) {
+		l := ratelimit.New(rate)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			done := make(chan struct{})
+			go func() {
+				l.Take()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-ctx.Done():
+				b.Fatal(ctx.Err())
+			}
+		}
+	})
+
+	b.Run("reused timer", func(b *testing.B) {
+		l := newRateLimiter(rate)
+		timer := newStoppedTimer()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := l.wait(ctx, timer); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func Benchmark_connQuery(b *testing.B) {
+	pc, err := net.ListenUDP(UDPTransport, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pc.Close()
+	// the server echoes the query back as the response without allocating, so that only the client is measured
+	go func() {
+		buf := make([]byte, dns.MaxMsgSize)
+		for {
+			n, addr, err := pc.ReadFromUDPAddrPort(buf)
+			if err != nil {
+				return
+			}
+			buf[2] |= 0x80
+			pc.WriteToUDPAddrPort(buf[:n], addr)
+		}
+	}()
+
+	bench := Benchmark{Server: pc.LocalAddr().String(), Recurse: true, RequestTimeout: time.Second}
+	if err := bench.init(); err != nil {
+		b.Fatal(err)
+	}
+	templates := bench.newRequestTemplates([]string{"example.org."}, []uint16{dns.TypeA})
+	// nolint:gosec
+	rando := rand.New(rand.NewSource(1))
+	ctx := context.Background()
+
+	b.Run("msg", func(b *testing.B) {
+		cq := bench.newConnQuery(bench.getDNSClient())
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			req := bench.newRequest(&templates[0], rando)
+			if _, err := cq.query(ctx, bench.Server, &req); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("packed", func(b *testing.B) {
+		cq := bench.newConnQuery(bench.getDNSClient())
+		var buf []byte
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			req := bench.newRequest(&templates[0], rando)
+			buf = packRequest(&req, &templates[0], buf)
+			if _, err := cq.queryPacked(ctx, &req, buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// resolverMode returns true, if the Benchmark is configured to mimic stub resolver behaviour (retries or TCP fallback).
+func (b *Benchmark) resolverMode() bool {
+	return b.Retries > 0 || b.TCPFallback
+}
+
+// tcpFallbackQuery returns queryFunc used for retrying truncated UDP responses over TCP, nil is returned if the TCP fallback is not applicable.
+func (b *Benchmark) tcpFallbackQuery() queryFunc {
+	if !b.TCPFallback || b.useDoH || b.useQuic || b.TCP || b.DOT {
+		return nil
+	}
+	dnsClient := b.getDNSClient()
+	dnsClient.Net = TCPTransport
+	if b.useDNSCrypt {
+		return b.newDNSCryptQuery(dnsClient).query
+	}
+	return b.newConnQuery(dnsClient).query
+}
+
+// retry continues resolution of the DNS request after the first attempt the same way as stub resolver does. Timed out requests
+// are retried with exponential backoff and truncated UDP responses are retried over TCP. Latency of each attempt is recorded in ResultStats.AttemptHist.
+func (b *Benchmark) retry(ctx context.Context, query, tcpQuery queryFunc, req *dns.Msg, resp *dns.Msg, err error, start time.Time, st *ResultStats) (*dns.Msg, error) {
+	st.recordAttempt(time.Since(start))
+
+	backoff := b.RetryBackoff
+	for i := 0; i < b.Retries && isTimeout(err); i++ {
+		waitFor(ctx, backoff)
+		backoff *= 2
+		if ctx.Err() != nil {
+			return resp, err
+		}
+		st.Counters.Retries++
+		resp, err = b.attempt(ctx, query, req, st)
+	}
+
+	if tcpQuery != nil && err == nil && resp.Truncated {
+		st.Counters.TCPFallback++
+		resp, err = b.attempt(ctx, tcpQuery, req, st)
+	}
+	return resp, err
+}
+
+func (b *Benchmark) attempt(ctx context.Context, query queryFunc, req *dns.Msg, st *ResultStats) (*dns.Msg, error) {
+	start := time.Now()
+	reqTimeoutCtx, cancel := context.WithTimeout(ctx, b.RequestTimeout)
+	defer cancel()
+	resp, err := query(reqTimeoutCtx, b.Server, req)
+	st.recordAttempt(time.Since(start))
+	return resp, err
+}
+
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -2,11 +2,11 @@ package dnsbench
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/miekg/dns"
-	"github.com/tantalor93/doh-go/doh"
 )
 
 // Counters represents various counters of benchmark results.
@@ -25,6 +25,33 @@ type Counters struct {
 	IDmismatch int64
 	// Truncated is counter of all responses which had truncated flag.
 	Truncated int64
+	// WrongAnswer is counter of all responses which did not match the expected response declared for the domain (see Benchmark.ExpectationsFile).
+	WrongAnswer int64
+	// Retries is counter of all retries of timed out requests (see Benchmark.Retries).
+	Retries int64
+	// TCPFallback is counter of all truncated UDP responses retried over TCP (see Benchmark.TCPFallback).
+	TCPFallback int64
+	// Late is counter of all responses received after the request timed out, counted only by the asynchronous UDP engine (see Benchmark.UDPEngine).
+	Late int64
+	// Duplicate is counter of all responses received for already answered requests, counted only by the asynchronous UDP engine (see Benchmark.UDPEngine).
+	Duplicate int64
+	// CaseMismatch is counter of all responses which did not preserve the letter case of the question name randomized using DNS 0x20 encoding.
+	CaseMismatch int64
+	// QuestionMismatch is counter of all responses which question name differed from the question name of the request other than by the letter case,
+	// such responses answer different question, for example they were spoofed. The question names are verified only with DNS 0x20 encoding.
+	QuestionMismatch int64
+}
+
+// DNSSECCounters represents counters of results of the local DNSSEC validation of DNS responses.
+type DNSSECCounters struct {
+	// Secure is counter of responses with valid chain of trust from the trust anchor.
+	Secure int64
+	// Insecure is counter of responses from unsigned zones.
+	Insecure int64
+	// Bogus is counter of responses, which should be signed, but failed the validation.
+	Bogus int64
+	// Indeterminate is counter of responses, for which the chain of trust could not be built.
+	Indeterminate int64
 }
 
 // Datapoint one datapoint of benchmark (single DNS request).
@@ -40,6 +67,14 @@ type ErrorDatapoint struct {
 	Err   error
 }
 
+// ExtendedError represents single Extended DNS Error (RFC 8914) returned in the DNS response.
+type ExtendedError struct {
+	// InfoCode is the Extended DNS Error info-code, see dns.ExtendedErrorCodeToString.
+	InfoCode uint16
+	// ExtraText is the optional free-form text sent by the server together with the info-code.
+	ExtraText string
+}
+
 // ResultStats is a representation of benchmark results of single concurrent thread.
 type ResultStats struct {
 	Codes                map[int]int64
@@ -50,6 +85,36 @@ type ResultStats struct {
 	Errors               []ErrorDatapoint
 	AuthenticatedDomains map[string]struct{}
 	DoHStatusCodes       map[int]int64
+	ExtendedErrors       map[ExtendedError]int64
+	DNSSECValidation     *DNSSECCounters
+	// WrongAnswers counts responses not matching the expectations per domain.
+	WrongAnswers map[string]int64
+	// AttemptHist is histogram of latencies of individual attempts (including retries and TCP fallbacks), while Hist contains
+	// end-to-end resolution latencies. AttemptHist is filled only when Benchmark.Retries or Benchmark.TCPFallback is configured.
+	AttemptHist *hdrhistogram.Histogram
+	// PipelineDepth is histogram of numbers of in-flight queries over the connection observed when sending a query.
+	// PipelineDepth is filled only when Benchmark.Pipeline is configured.
+	PipelineDepth *hdrhistogram.Histogram
+	// PhaseHists contains histograms of durations of the request phases. PhaseHists is filled only when Benchmark.PhaseTimings is configured.
+	PhaseHists *PhaseHistograms
+	// Handshakes contains durations of the TLS and QUIC handshakes. Handshakes is filled only when Benchmark.TLSSessionResumption is configured.
+	Handshakes *HandshakeStats
+	// Conns contains the lifecycle of the connections. Conns is filled only when Benchmark.ConnectionStats is configured.
+	Conns *ConnStats
+	// DoH contains HTTP level statistics of the DoH responses. DoH is filled only when Benchmark.DoHAnalytics is configured for DoH benchmark.
+	DoH *DoHStats
+	// SourceAddress is the source address of the worker, SourceAddress is filled only when Benchmark.SourceAddresses is configured.
+	SourceAddress string
+	// ODoH contains the latency breakdown of ODoH queries. ODoH is filled only when Benchmark.ODoHProxy is configured.
+	ODoH *ODoHStats
+	// DSO contains the statistics of DSO sessions. DSO is filled only when Benchmark.DSO is configured.
+	DSO *DSOStats
+	// Idle contains the idle timeouts of the connections. Idle is filled only when Benchmark.TCPKeepalive or Benchmark.IdleProbe is configured.
+	Idle *IdleStats
+
+	verifyCase   bool
+	expectations expectations
+	dohProto     string
 }
 
 func newResultStats(b *Benchmark) *ResultStats {
@@ -62,6 +127,53 @@ func newResultStats(b *Benchmark) *ResultStats {
 		st.DoHStatusCodes = make(map[int]int64)
 	}
 	st.Counters = &Counters{}
+	if b.DNSSECValidation {
+		st.DNSSECValidation = &DNSSECCounters{}
+	}
+	if b.Retries > 0 || b.TCPFallback {
+		st.AttemptHist = hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)
+	}
+	if b.PhaseTimings {
+		st.PhaseHists = &PhaseHistograms{NewConn: make(map[Phase]*hdrhistogram.Histogram), ReusedConn: make(map[Phase]*hdrhistogram.Histogram)}
+	}
+	if b.TLSSessionResumption {
+		st.Handshakes = &HandshakeStats{
+			Full:    hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+			Resumed: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+		}
+	}
+	if b.ConnectionStats {
+		st.Conns = &ConnStats{
+			FirstQuery:  hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+			ReusedQuery: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+		}
+	}
+	if b.useODoH() {
+		st.ODoH = &ODoHStats{
+			Crypto:  hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+			Proxied: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+			Target:  hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+			Proxy:   hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+		}
+	}
+	if b.DSO {
+		st.DSO = &DSOStats{
+			Setup:      hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+			Keepalives: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+		}
+	}
+	if b.idleTimeouts() {
+		st.Idle = &IdleStats{}
+	}
+	if b.useDoH && b.DoHAnalytics {
+		st.DoH = newDoHStats()
+		st.dohProto = b.DohProtocol
+		if len(st.dohProto) == 0 {
+			st.dohProto = HTTP1Proto
+		}
+	}
+	st.verifyCase = b.CaseRandomization
+	st.expectations = b.expectations
 	return st
 }
 
@@ -69,7 +181,8 @@ func (rs *ResultStats) record(req *dns.Msg, resp *dns.Msg, err error, time time.
 	rs.Counters.Total++
 
 	if rs.DoHStatusCodes != nil {
-		statusError := doh.UnexpectedServerHTTPStatusError{}
+		// the status is provided by doh.UnexpectedServerHTTPStatusError and by the errors of DoH JSON API client
+		var statusError interface{ HTTPStatus() int }
 		if err != nil && errors.As(err, &statusError) {
 			rs.DoHStatusCodes[statusError.HTTPStatus()]++
 		}
@@ -92,6 +205,16 @@ func (rs *ResultStats) record(req *dns.Msg, resp *dns.Msg, err error, time time.
 		rs.Counters.Truncated++
 	}
 
+	if rs.verifyCase && len(resp.Question) > 0 && resp.Question[0].Name != req.Question[0].Name {
+		if strings.EqualFold(resp.Question[0].Name, req.Question[0].Name) {
+			rs.Counters.CaseMismatch++
+		} else {
+			rs.Counters.QuestionMismatch++
+		}
+		rs.recordLatency(time, duration)
+		return
+	}
+
 	if resp.Rcode == dns.RcodeSuccess {
 		if resp.Id != req.Id {
 			rs.Counters.IDmismatch++
@@ -112,6 +235,14 @@ func (rs *ResultStats) record(req *dns.Msg, resp *dns.Msg, err error, time time.
 		rs.Counters.Error++
 	}
 
+	if rs.expectations != nil && resp.Id == req.Id && !rs.expectations.matches(req.Question[0], resp) {
+		rs.Counters.WrongAnswer++
+		if rs.WrongAnswers == nil {
+			rs.WrongAnswers = make(map[string]int64)
+		}
+		rs.WrongAnswers[dns.CanonicalName(req.Question[0].Name)]++
+	}
+
 	if rs.Codes != nil {
 		var c int64
 		if v, ok := rs.Codes[resp.Rcode]; ok {
@@ -120,13 +251,49 @@ func (rs *ResultStats) record(req *dns.Msg, resp *dns.Msg, err error, time time.
 		c++
 		rs.Codes[resp.Rcode] = c
 	}
+	if opt := resp.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			if ede, ok := o.(*dns.EDNS0_EDE); ok {
+				if rs.ExtendedErrors == nil {
+					rs.ExtendedErrors = make(map[ExtendedError]int64)
+				}
+				rs.ExtendedErrors[ExtendedError{InfoCode: ede.InfoCode, ExtraText: ede.ExtraText}]++
+			}
+		}
+	}
 	if resp.AuthenticatedData {
 		if rs.AuthenticatedDomains == nil {
 			rs.AuthenticatedDomains = make(map[string]struct{})
 		}
-		rs.AuthenticatedDomains[req.Question[0].Name] = struct{}{}
+		rs.AuthenticatedDomains[dns.CanonicalName(req.Question[0].Name)] = struct{}{}
 	}
 
+	rs.recordLatency(time, duration)
+}
+
+func (rs *ResultStats) recordLatency(time time.Time, duration time.Duration) {
 	rs.Hist.RecordValue(duration.Nanoseconds())
 	rs.Timings = append(rs.Timings, Datapoint{Duration: duration, Start: time})
 }
+
+func (rs *ResultStats) recordDNSSECStatus(status DNSSECStatus) {
+	if rs.DNSSECValidation == nil {
+		return
+	}
+	switch status {
+	case DNSSECSecure:
+		rs.DNSSECValidation.Secure++
+	case DNSSECInsecure:
+		rs.DNSSECValidation.Insecure++
+	case DNSSECBogus:
+		rs.DNSSECValidation.Bogus++
+	case DNSSECIndeterminate:
+		rs.DNSSECValidation.Indeterminate++
+	}
+}
+
+func (rs *ResultStats) recordAttempt(duration time.Duration) {
+	if rs.AttemptHist != nil {
+		rs.AttemptHist.RecordValue(duration.Nanoseconds())
+	}
+}
@@ -20,6 +20,7 @@ func TestResultStats_record(t *testing.T) {
 		time         time.Time
 		duration     time.Duration
 		dohBenchmark bool
+		verifyCase   bool
 	}
 	tests := []struct {
 		name string
@@ -369,12 +370,251 @@ func TestResultStats_record(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "record extended DNS error",
+			args: args{
+				req: &dns.Msg{
+					MsgHdr: dns.MsgHdr{Id: 1},
+					Question: []dns.Question{
+						{
+							Name:   "example.org.",
+							Qclass: dns.ClassINET,
+							Qtype:  dns.TypeA,
+						},
+					},
+				},
+				resp: &dns.Msg{
+					MsgHdr: dns.MsgHdr{Id: 1, Rcode: dns.RcodeServerFailure, Response: true},
+					Extra: []dns.RR{
+						&dns.OPT{
+							Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeOPT},
+							Option: []dns.EDNS0{
+								&dns.EDNS0_EDE{InfoCode: dns.ExtendedErrorCodeDNSBogus, ExtraText: "bogus"},
+							},
+						},
+					},
+				},
+				time:     time.Now(),
+				duration: time.Millisecond,
+			},
+			want: &ResultStats{
+				Codes: map[int]int64{
+					dns.RcodeServerFailure: 1,
+				},
+				Qtypes: map[string]int64{
+					"A": 1,
+				},
+				Timings: []Datapoint{
+					{
+						Duration: time.Millisecond,
+						Start:    now,
+					},
+				},
+				Counters: &Counters{
+					Total: 1,
+					Error: 1,
+				},
+				ExtendedErrors: map[ExtendedError]int64{
+					{InfoCode: dns.ExtendedErrorCodeDNSBogus, ExtraText: "bogus"}: 1,
+				},
+			},
+		},
+		{
+			name: "record case mismatch",
+			args: args{
+				req: &dns.Msg{
+					MsgHdr: dns.MsgHdr{Id: 1},
+					Question: []dns.Question{
+						{
+							Name:   "eXaMpLe.org.",
+							Qclass: dns.ClassINET,
+							Qtype:  dns.TypeA,
+						},
+					},
+				},
+				resp: &dns.Msg{
+					MsgHdr: dns.MsgHdr{Id: 1, Rcode: dns.RcodeSuccess, Response: true},
+					Question: []dns.Question{
+						{
+							Name:   "example.org.",
+							Qclass: dns.ClassINET,
+							Qtype:  dns.TypeA,
+						},
+					},
+					Answer: []dns.RR{&dns.A{A: net.ParseIP("127.0.0.1")}},
+				},
+				time:       time.Now(),
+				duration:   time.Millisecond,
+				verifyCase: true,
+			},
+			want: &ResultStats{
+				Codes: map[int]int64{},
+				Qtypes: map[string]int64{
+					"A": 1,
+				},
+				Timings: []Datapoint{
+					{
+						Duration: time.Millisecond,
+						Start:    now,
+					},
+				},
+				Counters: &Counters{
+					Total:        1,
+					CaseMismatch: 1,
+				},
+				verifyCase: true,
+			},
+		},
+		{
+			name: "record question mismatch",
+			args: args{
+				req: &dns.Msg{
+					MsgHdr: dns.MsgHdr{Id: 1},
+					Question: []dns.Question{
+						{
+							Name:   "eXaMpLe.org.",
+							Qclass: dns.ClassINET,
+							Qtype:  dns.TypeA,
+						},
+					},
+				},
+				resp: &dns.Msg{
+					MsgHdr: dns.MsgHdr{Id: 1, Rcode: dns.RcodeSuccess, Response: true},
+					Question: []dns.Question{
+						{
+							Name:   "example.com.",
+							Qclass: dns.ClassINET,
+							Qtype:  dns.TypeA,
+						},
+					},
+					Answer: []dns.RR{&dns.A{A: net.ParseIP("127.0.0.1")}},
+				},
+				time:       time.Now(),
+				duration:   time.Millisecond,
+				verifyCase: true,
+			},
+			want: &ResultStats{
+				Codes: map[int]int64{},
+				Qtypes: map[string]int64{
+					"A": 1,
+				},
+				Timings: []Datapoint{
+					{
+						Duration: time.Millisecond,
+						Start:    now,
+					},
+				},
+				Counters: &Counters{
+					Total:            1,
+					QuestionMismatch: 1,
+				},
+				verifyCase: true,
+			},
+		},
+		{
+			name: "record different question without case randomization",
+			args: args{
+				req: &dns.Msg{
+					MsgHdr: dns.MsgHdr{Id: 1},
+					Question: []dns.Question{
+						{
+							Name:   "example.org.",
+							Qclass: dns.ClassINET,
+							Qtype:  dns.TypeA,
+						},
+					},
+				},
+				resp: &dns.Msg{
+					MsgHdr: dns.MsgHdr{Id: 1, Rcode: dns.RcodeSuccess, Response: true},
+					Question: []dns.Question{
+						{
+							Name:   "example.com.",
+							Qclass: dns.ClassINET,
+							Qtype:  dns.TypeA,
+						},
+					},
+					Answer: []dns.RR{&dns.A{A: net.ParseIP("127.0.0.1")}},
+				},
+				time:     time.Now(),
+				duration: time.Millisecond,
+			},
+			want: &ResultStats{
+				Codes: map[int]int64{
+					dns.RcodeSuccess: 1,
+				},
+				Qtypes: map[string]int64{
+					"A": 1,
+				},
+				Timings: []Datapoint{
+					{
+						Duration: time.Millisecond,
+						Start:    now,
+					},
+				},
+				Counters: &Counters{
+					Total:   1,
+					Success: 1,
+				},
+			},
+		},
+		{
+			name: "record authenticated domain with randomized case",
+			args: args{
+				req: &dns.Msg{
+					MsgHdr: dns.MsgHdr{Id: 1},
+					Question: []dns.Question{
+						{
+							Name:   "eXaMpLe.org.",
+							Qclass: dns.ClassINET,
+							Qtype:  dns.TypeA,
+						},
+					},
+				},
+				resp: &dns.Msg{
+					MsgHdr: dns.MsgHdr{Id: 1, Rcode: dns.RcodeSuccess, Response: true, AuthenticatedData: true},
+					Question: []dns.Question{
+						{
+							Name:   "eXaMpLe.org.",
+							Qclass: dns.ClassINET,
+							Qtype:  dns.TypeA,
+						},
+					},
+					Answer: []dns.RR{&dns.A{A: net.ParseIP("127.0.0.1")}},
+				},
+				time:       time.Now(),
+				duration:   time.Millisecond,
+				verifyCase: true,
+			},
+			want: &ResultStats{
+				Codes: map[int]int64{
+					dns.RcodeSuccess: 1,
+				},
+				Qtypes: map[string]int64{
+					"A": 1,
+				},
+				Timings: []Datapoint{
+					{
+						Duration: time.Millisecond,
+						Start:    now,
+					},
+				},
+				Counters: &Counters{
+					Total:   1,
+					Success: 1,
+				},
+				AuthenticatedDomains: map[string]struct{}{
+					"example.org.": {},
+				},
+				verifyCase: true,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := Benchmark{
-				Rcodes: true,
-				useDoH: tt.args.dohBenchmark,
+				Rcodes:            true,
+				useDoH:            tt.args.dohBenchmark,
+				CaseRandomization: tt.args.verifyCase,
 			}
 			rs := newResultStats(&b)
 
@@ -0,0 +1,52 @@
+package dnsbench
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// tlsSessionCacheCapacity is capacity of the client session cache shared by all workers.
+const tlsSessionCacheCapacity = 1024
+
+// HandshakeStats represents durations of the TLS handshakes (including QUIC handshakes) of the connections opened by the benchmark,
+// full handshakes are kept separately from the handshakes resuming the earlier TLS session.
+type HandshakeStats struct {
+	// Full is histogram of durations of the full handshakes.
+	Full *hdrhistogram.Histogram
+	// Resumed is histogram of durations of the resumed handshakes.
+	Resumed *hdrhistogram.Histogram
+	// ZeroRTT is counter of the QUIC connections, which sent the query in 0-RTT data (see Benchmark.ZeroRTT).
+	ZeroRTT int64
+}
+
+// traceRequests returns true if the phases of the requests have to be traced. Besides the phase timings and the handshakes,
+// the connection statistics and DoH analytics need to know whether the request opened new connection.
+func (b *Benchmark) traceRequests() bool {
+	return b.PhaseTimings || b.TLSSessionResumption || b.ConnectionStats || b.DoHAnalytics
+}
+
+// handshakeDone records the duration of the TLS or QUIC handshake and whether the session was resumed.
+func (t *phaseTrace) handshakeDone(p Phase, start time.Time, state tls.ConnectionState, used0RTT bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handshake = time.Since(start)
+	t.durations[p] = t.handshake
+	t.resumed = state.DidResume
+	t.used0RTT = used0RTT
+}
+
+func (rs *ResultStats) recordHandshake(trace *phaseTrace) {
+	if rs.Handshakes == nil || trace.handshake == 0 {
+		return
+	}
+	if trace.resumed {
+		rs.Handshakes.Resumed.RecordValue(trace.handshake.Nanoseconds())
+	} else {
+		rs.Handshakes.Full.RecordValue(trace.handshake.Nanoseconds())
+	}
+	if trace.used0RTT {
+		rs.Handshakes.ZeroRTT++
+	}
+}
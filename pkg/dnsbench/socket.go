@@ -0,0 +1,279 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/quic-go/quic-go"
+)
+
+// Address families supported in Benchmark.IPFamily.
+const (
+	IPv4Family = "ipv4"
+	IPv6Family = "ipv6"
+)
+
+const maxDSCP = 63
+
+// SocketSettings are the effective options of the socket, as read back from the socket after the connection is established.
+// The operating system may adjust the requested values, for example Linux doubles the requested buffer sizes.
+type SocketSettings struct {
+	// Network is the network of the socket, for example "udp4" or "tcp6".
+	Network string
+	// ReceiveBufferSize is the size of the socket receive buffer in bytes (SO_RCVBUF).
+	ReceiveBufferSize int
+	// SendBufferSize is the size of the socket send buffer in bytes (SO_SNDBUF).
+	SendBufferSize int
+	// DSCP is Differentiated Services Code Point of the IP packets sent over the socket.
+	DSCP int
+	// TCPNoDelay is true when TCP_NODELAY is enabled, only for TCP sockets.
+	TCPNoDelay bool
+	// TCPFastOpen is true when TCP Fast Open is enabled, only for TCP sockets.
+	TCPFastOpen bool
+}
+
+// socketRecorder records the settings of the first socket of each network opened by the benchmark.
+type socketRecorder struct {
+	mu       sync.Mutex
+	settings map[string]SocketSettings
+}
+
+// recorded returns true if the settings of the socket of the network were recorded already.
+func (r *socketRecorder) recorded(network string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.settings[network]
+	return ok
+}
+
+// store records the settings, unless the settings of the socket of the same network were recorded already.
+func (r *socketRecorder) store(s SocketSettings) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.settings[s.Network]; !ok {
+		r.settings[s.Network] = s
+	}
+}
+
+func socketNetwork(addr net.Addr) string {
+	var network string
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		network, ip = TCPTransport, a.IP
+	case *net.UDPAddr:
+		network, ip = UDPTransport, a.IP
+	default:
+		return ""
+	}
+	if ip.To4() != nil {
+		return network + "4"
+	}
+	return network + "6"
+}
+
+// initSocket validates the socket options of the Benchmark.
+func (b *Benchmark) initSocket() error {
+	if b.ReceiveBufferSize < 0 || b.SendBufferSize < 0 {
+		return errors.New("--recv-buffer and --send-buffer must not be negative")
+	}
+	if b.DSCP < 0 || b.DSCP > maxDSCP {
+		return fmt.Errorf("--dscp must be in range 0-%d", maxDSCP)
+	}
+	if !socketOptionsSupported && (b.TCPFastOpen || b.ReceiveBufferSize != 0 || b.SendBufferSize != 0 || b.DSCP != 0) {
+		return errors.New("--tcp-fastopen, --recv-buffer, --send-buffer and --dscp are supported only on Linux")
+	}
+	if b.TCPFastOpen && (b.useQuic || b.useDoH && b.DohProtocol == HTTP3Proto) {
+		return errors.New("--tcp-fastopen is not applicable for DoQ and DoH over HTTP/3")
+	}
+	if len(b.IPFamily) != 0 {
+		if b.IPFamily != IPv4Family && b.IPFamily != IPv6Family {
+			return fmt.Errorf("--ip-family '%s' is not supported, supported families are ipv4 and ipv6", b.IPFamily)
+		}
+		if b.proxyResolves() {
+			return errors.New("--ip-family can be combined only with socks5:// proxy, the server is resolved by socks5h:// and HTTP proxies")
+		}
+	}
+	if b.socketTuning() {
+		b.sockets = &socketRecorder{settings: make(map[string]SocketSettings)}
+	}
+	return nil
+}
+
+// socketTuning returns true if any socket option or the address family is configured.
+func (b *Benchmark) socketTuning() bool {
+	return b.TCPFastOpen || b.nagle() || b.ReceiveBufferSize != 0 || b.SendBufferSize != 0 || b.DSCP != 0 || len(b.IPFamily) != 0
+}
+
+// nagle returns true if TCP_NODELAY is disabled by Benchmark.TCPNoDelay and the benchmark opens TCP connections.
+func (b *Benchmark) nagle() bool {
+	return !b.TCPNoDelay && (b.TCP || b.DOT || b.useDoH && b.DohProtocol != HTTP3Proto)
+}
+
+// SocketSettings returns the effective settings of the first socket of each network opened by the benchmark, ordered by the network.
+// The settings are recorded only when any socket option or the address family is configured and are available after the benchmark is finished.
+// The settings can be read back from the sockets only on Linux, no settings are returned on other platforms.
+func (b *Benchmark) SocketSettings() []SocketSettings {
+	if b.sockets == nil {
+		return nil
+	}
+	b.sockets.mu.Lock()
+	defer b.sockets.mu.Unlock()
+	res := make([]SocketSettings, 0, len(b.sockets.settings))
+	for _, s := range b.sockets.settings {
+		res = append(res, s)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Network < res[j].Network
+	})
+	return res
+}
+
+// ipNetwork returns the network restricted to the address family configured by Benchmark.IPFamily.
+func (b *Benchmark) ipNetwork(network string) string {
+	if network != UDPTransport && network != TCPTransport {
+		return network
+	}
+	switch b.IPFamily {
+	case IPv4Family:
+		return network + "4"
+	case IPv6Family:
+		return network + "6"
+	default:
+		return network
+	}
+}
+
+// lookupNetwork returns the network used for resolving the server hostname.
+func (b *Benchmark) lookupNetwork() string {
+	switch b.IPFamily {
+	case IPv4Family:
+		return "ip4"
+	case IPv6Family:
+		return "ip6"
+	default:
+		return "ip"
+	}
+}
+
+// tuneConn applies the socket options, which can be set only after the connection is established, and records the effective settings.
+func (b *Benchmark) tuneConn(conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok && !b.TCPNoDelay {
+		tcpConn.SetNoDelay(false)
+	}
+	if b.sockets == nil {
+		return
+	}
+	network := socketNetwork(conn.LocalAddr())
+	if len(network) == 0 || b.sockets.recorded(network) {
+		return
+	}
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return
+	}
+	if s, err := b.readSocketSettings(raw, network); err == nil {
+		b.sockets.store(s)
+	}
+}
+
+// dialQUICConn dials QUIC connection, 0-RTT connection is dialed if early is true. When socket options are configured,
+// the connection uses its own UDP socket created with the options, which is closed together with the connection.
+func (b *Benchmark) dialQUICConn(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config, early bool) (quic.EarlyConnection, error) {
+	if !b.socketTuning() {
+		if early {
+			return quic.DialAddrEarly(ctx, addr, tlsCfg, cfg)
+		}
+		conn, err := quic.DialAddr(ctx, addr, tlsCfg, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return conn.(quic.EarlyConnection), nil
+	}
+	udpAddr, err := net.ResolveUDPAddr(b.ipNetwork(UDPTransport), addr)
+	if err != nil {
+		return nil, err
+	}
+	network := UDPTransport + "4"
+	if udpAddr.IP.To4() == nil {
+		network = UDPTransport + "6"
+	}
+	lc := net.ListenConfig{Control: b.socketControl}
+	pc, err := lc.ListenPacket(ctx, network, "")
+	if err != nil {
+		return nil, err
+	}
+	tr := &quic.Transport{Conn: pc.(*net.UDPConn)}
+	closeTransport := func() {
+		tr.Close()
+		pc.Close()
+	}
+	var conn quic.EarlyConnection
+	if early {
+		conn, err = tr.DialEarly(ctx, udpAddr, tlsCfg, cfg)
+	} else {
+		var c quic.Connection
+		c, err = tr.Dial(ctx, udpAddr, tlsCfg, cfg)
+		if err == nil {
+			conn = c.(quic.EarlyConnection)
+		}
+	}
+	if err != nil {
+		closeTransport()
+		return nil, err
+	}
+	// the socket settings are read back after QUIC library adjusted the buffer sizes
+	b.tuneConn(pc.(*net.UDPConn))
+	go func() {
+		<-conn.Context().Done()
+		closeTransport()
+	}()
+	return conn, nil
+}
+
+// controls returns the function applying all the control functions to the socket.
+func controls(fns ...func(network, address string, c syscall.RawConn) error) func(network, address string, c syscall.RawConn) error {
+	var res []func(network, address string, c syscall.RawConn) error
+	for _, fn := range fns {
+		if fn != nil {
+			res = append(res, fn)
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		for _, fn := range res {
+			if err := fn(network, address, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// socketControl sets the socket options configured by the Benchmark before the socket is connected.
+func (b *Benchmark) socketControl(network, _ string, c syscall.RawConn) error {
+	if b.ReceiveBufferSize == 0 && b.SendBufferSize == 0 && b.DSCP == 0 && !b.TCPFastOpen {
+		return nil
+	}
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = b.setSocketOptions(fd, strings.HasPrefix(network, TCPTransport), strings.HasSuffix(network, "6"))
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
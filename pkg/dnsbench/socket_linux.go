@@ -0,0 +1,81 @@
+//go:build linux
+
+package dnsbench
+
+import (
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const socketOptionsSupported = true
+
+// setSocketOptions sets the buffer sizes, DSCP marking and TCP Fast Open of the socket.
+func (b *Benchmark) setSocketOptions(fd uintptr, tcp, ipv6 bool) error {
+	if b.ReceiveBufferSize != 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF, b.ReceiveBufferSize); err != nil {
+			return err
+		}
+	}
+	if b.SendBufferSize != 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_SNDBUF, b.SendBufferSize); err != nil {
+			return err
+		}
+	}
+	if b.DSCP != 0 {
+		// DSCP occupies the upper 6 bits of the traffic class, the lower 2 bits are used by ECN
+		if ipv6 {
+			if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, b.DSCP<<2); err != nil {
+				return err
+			}
+		} else if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, b.DSCP<<2); err != nil {
+			return err
+		}
+	}
+	if b.TCPFastOpen && tcp {
+		if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSocketSettings reads back the effective options of the socket.
+func (b *Benchmark) readSocketSettings(c syscall.RawConn, network string) (SocketSettings, error) {
+	s := SocketSettings{Network: network}
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		if s.ReceiveBufferSize, sockErr = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF); sockErr != nil {
+			return
+		}
+		if s.SendBufferSize, sockErr = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_SNDBUF); sockErr != nil {
+			return
+		}
+		var tos int
+		if strings.HasSuffix(network, "6") {
+			tos, sockErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS)
+		} else {
+			tos, sockErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS)
+		}
+		if sockErr != nil {
+			return
+		}
+		s.DSCP = tos >> 2
+		if !strings.HasPrefix(network, TCPTransport) {
+			return
+		}
+		var noDelay, fastOpen int
+		if noDelay, sockErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_NODELAY); sockErr != nil {
+			return
+		}
+		if fastOpen, sockErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT); sockErr != nil {
+			return
+		}
+		s.TCPNoDelay, s.TCPFastOpen = noDelay != 0, fastOpen != 0
+	})
+	if err != nil {
+		return s, err
+	}
+	return s, sockErr
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package dnsbench
+
+import (
+	"errors"
+	"syscall"
+)
+
+const socketOptionsSupported = false
+
+// setSocketOptions is no-op on platforms other than Linux, the socket options are rejected when the benchmark is initialized.
+func (b *Benchmark) setSocketOptions(_ uintptr, _, _ bool) error {
+	return nil
+}
+
+// readSocketSettings fails on platforms other than Linux, where the socket options cannot be read back, so no settings are recorded.
+func (b *Benchmark) readSocketSettings(_ syscall.RawConn, _ string) (SocketSettings, error) {
+	return SocketSettings{}, errors.New("reading socket settings is supported only on Linux")
+}
@@ -0,0 +1,79 @@
+package dnsbench
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark_ipNetwork(t *testing.T) {
+	b := Benchmark{IPFamily: IPv4Family}
+	assert.Equal(t, "udp4", b.ipNetwork(UDPTransport))
+	assert.Equal(t, "tcp4", b.ipNetwork(TCPTransport))
+	assert.Equal(t, "ip4", b.lookupNetwork())
+
+	b = Benchmark{IPFamily: IPv6Family}
+	assert.Equal(t, "tcp6", b.ipNetwork(TCPTransport))
+	assert.Equal(t, "ip6", b.lookupNetwork())
+
+	b = Benchmark{}
+	assert.Equal(t, "udp", b.ipNetwork(UDPTransport))
+	assert.Equal(t, "ip", b.lookupNetwork())
+}
+
+func TestBenchmark_Run_socket_options(t *testing.T) {
+	for _, network := range []string{UDPTransport, TCPTransport} {
+		t.Run(network, func(t *testing.T) {
+			_, port, err := net.SplitHostPort(newProxyTestDNSServer(t, network))
+			require.NoError(t, err)
+
+			bench := Benchmark{
+				Server:         net.JoinHostPort("localhost", port),
+				TCP:            network == TCPTransport,
+				IPFamily:       IPv4Family,
+				Queries:        []string{"example.org"},
+				Types:          []string{"A"},
+				Concurrency:    2,
+				Count:          3,
+				Probability:    1,
+				WriteTimeout:   time.Second,
+				ReadTimeout:    3 * time.Second,
+				ConnectTimeout: time.Second,
+				RequestTimeout: 5 * time.Second,
+				Writer:         io.Discard,
+			}
+			if socketOptionsSupported {
+				bench.TCPFastOpen = network == TCPTransport
+				bench.ReceiveBufferSize = 65536
+				bench.SendBufferSize = 65536
+				bench.DSCP = 46
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			require.Len(t, rs, 2)
+			for _, r := range rs {
+				assert.EqualValues(t, 3, r.Counters.Success)
+			}
+			settings := bench.SocketSettings()
+			if !socketOptionsSupported {
+				assert.Empty(t, settings, "socket settings can be read back only on Linux")
+				return
+			}
+			require.Len(t, settings, 1)
+			assert.Equal(t, network+"4", settings[0].Network)
+			assert.False(t, settings[0].TCPNoDelay)
+			// Linux doubles the requested buffer sizes to account for the bookkeeping overhead
+			assert.GreaterOrEqual(t, settings[0].ReceiveBufferSize, 65536)
+			assert.GreaterOrEqual(t, settings[0].SendBufferSize, 65536)
+			assert.Equal(t, 46, settings[0].DSCP)
+			assert.Equal(t, network == TCPTransport, settings[0].TCPFastOpen)
+		})
+	}
+}
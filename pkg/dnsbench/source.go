@@ -0,0 +1,149 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+// maxSourcePortAttempts limits the number of source ports tried, when the dialing fails because the source port is in use.
+const maxSourcePortAttempts = 16
+
+// sourcePorts selects the source ports from the range configured by Benchmark.SourcePortRange in round-robin fashion.
+type sourcePorts struct {
+	from uint32
+	size uint32
+	next atomic.Uint32
+}
+
+func (p *sourcePorts) port() int {
+	return int(p.from + (p.next.Add(1)-1)%p.size)
+}
+
+type sourceAddrKey struct{}
+
+// withSourceAddr returns the context carrying the source address of the worker, nil address leaves the context unchanged.
+func withSourceAddr(ctx context.Context, ip net.IP) context.Context {
+	if ip == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, sourceAddrKey{}, ip)
+}
+
+func sourceAddrFrom(ctx context.Context) net.IP {
+	ip, _ := ctx.Value(sourceAddrKey{}).(net.IP)
+	return ip
+}
+
+// initSource parses and validates Benchmark.SourceAddresses, Benchmark.SourceInterface and Benchmark.SourcePortRange.
+func (b *Benchmark) initSource() error {
+	for _, a := range b.SourceAddresses {
+		ip := net.ParseIP(a)
+		if ip == nil {
+			return fmt.Errorf("--source-address '%s' is not valid IP address", a)
+		}
+		b.sourceIPs = append(b.sourceIPs, ip)
+	}
+	if len(b.SourceInterface) != 0 {
+		if !bindToDeviceSupported {
+			return errors.New("--source-interface is supported only on Linux")
+		}
+		if _, err := net.InterfaceByName(b.SourceInterface); err != nil {
+			return fmt.Errorf("--source-interface '%s' is not valid network interface: %w", b.SourceInterface, err)
+		}
+	}
+	if len(b.SourcePortRange) != 0 {
+		from, to, err := parsePortRange(b.SourcePortRange)
+		if err != nil {
+			return err
+		}
+		b.sourcePorts = &sourcePorts{from: from, size: to - from + 1}
+	}
+	if !b.sourceBinding() {
+		return nil
+	}
+	if b.useQuic || b.useDoH && b.DohProtocol == HTTP3Proto {
+		return errors.New("--source-address, --source-interface and --source-port-range are not supported for DoQ and DoH over HTTP/3")
+	}
+	if b.UDPEngine {
+		return errors.New("--source-address, --source-interface and --source-port-range cannot be combined with --udp-engine")
+	}
+	return nil
+}
+
+func parsePortRange(s string) (uint32, uint32, error) {
+	fromStr, toStr, ok := strings.Cut(s, "-")
+	if !ok {
+		toStr = fromStr
+	}
+	from, err := strconv.ParseUint(fromStr, 10, 16)
+	if err != nil || from == 0 {
+		return 0, 0, fmt.Errorf("--source-port-range '%s' is not in format <from>-<to>", s)
+	}
+	to, err := strconv.ParseUint(toStr, 10, 16)
+	if err != nil || to < from {
+		return 0, 0, fmt.Errorf("--source-port-range '%s' is not in format <from>-<to>", s)
+	}
+	return uint32(from), uint32(to), nil
+}
+
+// sourceBinding returns true if the sockets are bound to the source addresses, network interface or source ports.
+func (b *Benchmark) sourceBinding() bool {
+	return len(b.sourceIPs) != 0 || len(b.SourceInterface) != 0 || b.sourcePorts != nil
+}
+
+// workerSourceAddr returns the source address of the worker, the workers are spread across Benchmark.SourceAddresses in round-robin fashion.
+func (b *Benchmark) workerSourceAddr(workerID uint32) net.IP {
+	if len(b.sourceIPs) == 0 {
+		return nil
+	}
+	return b.sourceIPs[int(workerID)%len(b.sourceIPs)]
+}
+
+// dialSource dials the connection from the source address of the worker present in the context, bound to Benchmark.SourceInterface
+// and to the next port of Benchmark.SourcePortRange. When the source port is in use, next ports are tried.
+func (b *Benchmark) dialSource(ctx context.Context, network, addr string) (net.Conn, error) {
+	attempts := 1
+	if b.sourcePorts != nil {
+		attempts = min(int(b.sourcePorts.size), maxSourcePortAttempts)
+	}
+	for i := 1; ; i++ {
+		d := b.dialer(ctx, network)
+		conn, err := d.DialContext(ctx, b.ipNetwork(network), addr)
+		if err == nil {
+			b.tuneConn(conn)
+			return conn, nil
+		}
+		if i >= attempts || !errors.Is(err, syscall.EADDRINUSE) {
+			return nil, err
+		}
+	}
+}
+
+func (b *Benchmark) dialer(ctx context.Context, network string) net.Dialer {
+	d := net.Dialer{Timeout: b.ConnectTimeout}
+	if len(b.SourceInterface) != 0 {
+		d.Control = controls(bindToDeviceControl(b.SourceInterface), b.socketControl)
+	} else {
+		d.Control = b.socketControl
+	}
+	ip := sourceAddrFrom(ctx)
+	if ip == nil && b.sourcePorts == nil {
+		return d
+	}
+	var port int
+	if b.sourcePorts != nil {
+		port = b.sourcePorts.port()
+	}
+	if strings.HasPrefix(network, UDPTransport) {
+		d.LocalAddr = &net.UDPAddr{IP: ip, Port: port}
+	} else {
+		d.LocalAddr = &net.TCPAddr{IP: ip, Port: port}
+	}
+	return d
+}
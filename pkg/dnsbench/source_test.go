@@ -0,0 +1,114 @@
+package dnsbench
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parsePortRange(t *testing.T) {
+	from, to, err := parsePortRange("40000-40100")
+	require.NoError(t, err)
+	assert.EqualValues(t, 40000, from)
+	assert.EqualValues(t, 40100, to)
+
+	from, to, err = parsePortRange("40000")
+	require.NoError(t, err)
+	assert.EqualValues(t, 40000, from)
+	assert.EqualValues(t, 40000, to)
+
+	for _, s := range []string{"", "0-10", "10-5", "1-65536", "a-b"} {
+		_, _, err := parsePortRange(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func Test_sourcePorts_port(t *testing.T) {
+	p := sourcePorts{from: 40000, size: 3}
+	var ports []int
+	for i := 0; i < 4; i++ {
+		ports = append(ports, p.port())
+	}
+	assert.Equal(t, []int{40000, 40001, 40002, 40000}, ports)
+}
+
+// newSourceTestDNSServer creates DNS server, which records the source addresses of the queries.
+func newSourceTestDNSServer(t *testing.T, network string) (string, func() []net.Addr) {
+	var mu sync.Mutex
+	var sources []net.Addr
+	started := make(chan struct{})
+	s := &dns.Server{Net: network, Addr: "127.0.0.1:0", NotifyStartedFunc: func() { close(started) }, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		sources = append(sources, w.RemoteAddr())
+		mu.Unlock()
+		resp := dns.Msg{}
+		resp.SetReply(r)
+		resp.Answer = append(resp.Answer, &dns.A{Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60}, A: net.IPv4(127, 0, 0, 1)})
+		w.WriteMsg(&resp)
+	})}
+	go s.ListenAndServe()
+	<-started
+	t.Cleanup(func() { s.Shutdown() })
+	recorded := func() []net.Addr {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]net.Addr{}, sources...)
+	}
+	if network == UDPTransport {
+		return s.PacketConn.LocalAddr().String(), recorded
+	}
+	return s.Listener.Addr().String(), recorded
+}
+
+func TestBenchmark_Run_source(t *testing.T) {
+	for _, network := range []string{UDPTransport, TCPTransport} {
+		t.Run(network, func(t *testing.T) {
+			server, sources := newSourceTestDNSServer(t, network)
+
+			bench := Benchmark{
+				Server:          server,
+				TCP:             network == TCPTransport,
+				SourceAddresses: []string{"127.0.0.1", "127.0.0.2"},
+				SourcePortRange: "41000-41999",
+				Queries:         []string{"example.org"},
+				Types:           []string{"A"},
+				Concurrency:     4,
+				Count:           3,
+				Probability:     1,
+				WriteTimeout:    time.Second,
+				ReadTimeout:     3 * time.Second,
+				ConnectTimeout:  time.Second,
+				RequestTimeout:  5 * time.Second,
+				Writer:          io.Discard,
+			}
+			if bindToDeviceSupported {
+				bench.SourceInterface = "lo"
+			}
+
+			rs, err := bench.Run(context.Background())
+
+			require.NoError(t, err, "expected no error from benchmark run")
+			require.Len(t, rs, 4)
+			for i, r := range rs {
+				assert.EqualValues(t, 3, r.Counters.Success)
+				assert.Equal(t, bench.SourceAddresses[i%2], r.SourceAddress)
+			}
+			perSource := make(map[string]int)
+			for _, addr := range sources() {
+				host, port, err := net.SplitHostPort(addr.String())
+				require.NoError(t, err)
+				assert.GreaterOrEqual(t, port, "41000")
+				assert.LessOrEqual(t, port, "41999")
+				perSource[host]++
+			}
+			assert.Equal(t, map[string]int{"127.0.0.1": 6, "127.0.0.2": 6}, perSource)
+		})
+	}
+}
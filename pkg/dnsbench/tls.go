@@ -0,0 +1,105 @@
+package dnsbench
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P-256":  tls.CurveP256,
+	"P-384":  tls.CurveP384,
+	"P-521":  tls.CurveP521,
+}
+
+// initTLSConfig builds TLS client configuration from the TLS settings of the Benchmark.
+func (b *Benchmark) initTLSConfig() error {
+	b.tlsConfig = &tls.Config{
+		// nolint:gosec
+		InsecureSkipVerify: b.Insecure,
+		ServerName:         b.TLSServerName,
+		ClientSessionCache: b.tlsSessionCache,
+		NextProtos:         b.ALPN,
+	}
+
+	if len(b.CACertFile) != 0 {
+		pem, err := os.ReadFile(b.CACertFile)
+		if err != nil {
+			return fmt.Errorf("failed to read --ca-cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("--ca-cert '%s' does not contain any PEM encoded certificate", b.CACertFile)
+		}
+		b.tlsConfig.RootCAs = pool
+	}
+
+	if len(b.ClientCertFile) != 0 || len(b.ClientKeyFile) != 0 {
+		if len(b.ClientCertFile) == 0 || len(b.ClientKeyFile) == 0 {
+			return errors.New("--client-cert and --client-key must be specified together")
+		}
+		cert, err := tls.LoadX509KeyPair(b.ClientCertFile, b.ClientKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		b.tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(b.TLSMinVersion) != 0 {
+		v, ok := tlsVersions[b.TLSMinVersion]
+		if !ok {
+			return fmt.Errorf("--tls-min-version '%s' is not supported TLS version, supported versions are 1.0, 1.1, 1.2 and 1.3", b.TLSMinVersion)
+		}
+		b.tlsConfig.MinVersion = v
+	}
+	if len(b.TLSMaxVersion) != 0 {
+		v, ok := tlsVersions[b.TLSMaxVersion]
+		if !ok {
+			return fmt.Errorf("--tls-max-version '%s' is not supported TLS version, supported versions are 1.0, 1.1, 1.2 and 1.3", b.TLSMaxVersion)
+		}
+		b.tlsConfig.MaxVersion = v
+	}
+	if b.tlsConfig.MinVersion != 0 && b.tlsConfig.MaxVersion != 0 && b.tlsConfig.MinVersion > b.tlsConfig.MaxVersion {
+		return errors.New("--tls-min-version must not be greater than --tls-max-version")
+	}
+
+	if len(b.TLSCipherSuites) != 0 {
+		suites := make(map[string]uint16)
+		for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			suites[s.Name] = s.ID
+		}
+		for _, name := range b.TLSCipherSuites {
+			id, ok := suites[strings.ToUpper(name)]
+			if !ok {
+				return fmt.Errorf("--tls-cipher-suite '%s' is not supported cipher suite", name)
+			}
+			b.tlsConfig.CipherSuites = append(b.tlsConfig.CipherSuites, id)
+		}
+	}
+
+	for _, name := range b.TLSCurves {
+		id, ok := tlsCurves[strings.ToUpper(name)]
+		if !ok {
+			return fmt.Errorf("--tls-curve '%s' is not supported curve, supported curves are X25519, P-256, P-384 and P-521", name)
+		}
+		b.tlsConfig.CurvePreferences = append(b.tlsConfig.CurvePreferences, id)
+	}
+	return nil
+}
+
+// newTLSConfig returns TLS client configuration used by all the protocols, the client session cache is shared by all the workers
+// so that the connections created by any worker can resume the sessions.
+func (b *Benchmark) newTLSConfig() *tls.Config {
+	return b.tlsConfig.Clone()
+}
@@ -0,0 +1,78 @@
+package dnsbench
+
+import (
+	"context"
+	"time"
+)
+
+// requestTraces are the traces of single request. Each trace is passed in its own context value and is nil
+// when it is not needed by the statistics configured in the Benchmark.
+type requestTraces struct {
+	phase *phaseTrace
+	doh   *dohResponse
+	odoh  *odohTiming
+	dso   *dsoTrace
+	idle  *idleTrace
+}
+
+// traceRequest returns the context of the request with the traces needed by the Benchmark. The connections closed during the request
+// are recorded into conns and the DoH connections opened by the request are registered into dohConns, when they are not nil.
+func (b *Benchmark) traceRequest(ctx context.Context, conns *connTracker, dohConns *dohConnRegistry) (context.Context, requestTraces) {
+	var t requestTraces
+	if b.traceRequests() {
+		t.phase = &phaseTrace{}
+		ctx = withPhaseTrace(ctx, t.phase)
+	}
+	if conns != nil {
+		ctx = withConnTracker(ctx, conns)
+	}
+	if dohConns != nil {
+		ctx = withDoHConnRegistry(ctx, dohConns)
+	}
+	if b.DoHAnalytics {
+		t.doh = &dohResponse{}
+		ctx = withDoHResponse(ctx, t.doh)
+	}
+	if b.useODoH() {
+		t.odoh = &odohTiming{}
+		ctx = withODoHTiming(ctx, t.odoh)
+	}
+	if b.DSO {
+		t.dso = &dsoTrace{}
+		ctx = withDSOTrace(ctx, t.dso)
+	}
+	if b.TCPKeepalive {
+		t.idle = &idleTrace{}
+		ctx = withIdleTrace(ctx, t.idle)
+	}
+	return ctx, t
+}
+
+// withoutTraces returns the context, in which nothing is traced. It is used for the queries sent on behalf of the traced request,
+// which must not be recorded as part of it.
+func withoutTraces(ctx context.Context) context.Context {
+	ctx = withPhaseTrace(ctx, nil)
+	ctx = withConnTracker(ctx, nil)
+	ctx = withDoHConnRegistry(ctx, nil)
+	ctx = withDoHResponse(ctx, nil)
+	ctx = withODoHTiming(ctx, nil)
+	ctx = withDSOTrace(ctx, nil)
+	return withIdleTrace(ctx, nil)
+}
+
+func (t requestTraces) traced() bool {
+	return t.phase != nil || t.doh != nil || t.odoh != nil || t.dso != nil || t.idle != nil
+}
+
+// recordTraces records the traces of the request.
+func (rs *ResultStats) recordTraces(t requestTraces, err error, duration time.Duration) {
+	var newConn bool
+	if t.phase != nil {
+		rs.recordTrace(t.phase, err, duration)
+		newConn = t.phase.openedConn()
+	}
+	rs.recordDoH(t.doh, newConn)
+	rs.recordODoH(t.odoh)
+	rs.recordDSO(t.dso)
+	rs.recordIdle(t.idle)
+}
@@ -0,0 +1,403 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// DefaultUDPEngineSockets is default number of sockets used by the asynchronous UDP engine.
+	DefaultUDPEngineSockets = 4
+	// DefaultMaxOutstanding is default maximum number of outstanding queries of the asynchronous UDP engine.
+	DefaultMaxOutstanding = 10000
+
+	// udpEngineBatchSize is maximum number of datagrams sent or received by single sendmmsg/recvmmsg call.
+	udpEngineBatchSize = 64
+	// udpEngineRecentWindow is how long are answered and timed out queries remembered to detect duplicate and late responses.
+	udpEngineRecentWindow = 3 * time.Second
+	// maxQueriesPerSocket is maximum number of outstanding queries per socket, queries are matched by 16-bit ID.
+	maxQueriesPerSocket = 1 << 16
+)
+
+var errUDPEngineClosed = errors.New("asynchronous UDP engine closed")
+
+// udpQuery is DNS query sent by the asynchronous UDP engine.
+type udpQuery struct {
+	msg *dns.Msg
+	// packed is optional wire format of msg, which is copied by the engine when the query is sent.
+	packed   []byte
+	deadline time.Time
+	// done is called exactly once, when the response is received, the query times out or fails to be sent,
+	// end is the time the response was received or the query failed.
+	done func(resp *dns.Msg, err error, end time.Time)
+	// stray is called for each response received for the query after done was called, late is false for duplicate responses.
+	stray func(late bool)
+}
+
+type recentQuery struct {
+	q        *udpQuery
+	answered bool
+	until    time.Time
+}
+
+// completedQuery is response handed over by the receiver goroutine to the completer goroutine of the socket.
+type completedQuery struct {
+	q    *udpQuery
+	resp *dns.Msg
+	err  error
+	end  time.Time
+	// stray is true for late and duplicate responses, answered distinguishes the duplicate ones.
+	stray    bool
+	answered bool
+}
+
+type outgoingQuery struct {
+	id     uint16
+	packed *[]byte
+}
+
+// packetPool holds the buffers for the packed queries waiting to be sent.
+var packetPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 512)
+		return &buf
+	},
+}
+
+// batchConn sends and receives multiple datagrams at once, on Linux using sendmmsg and recvmmsg.
+type batchConn interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+// udpEngine sends DNS queries over small number of UDP sockets without dedicating goroutine to each in-flight query.
+// Each socket has single sender and single receiver goroutine, the responses are matched to the queries by socket (source port) and ID.
+// The matched responses are passed to the completer goroutine of the socket, so that recording the results does not slow down receiving.
+type udpEngine struct {
+	b       *Benchmark
+	sockets []*udpSocket
+	next    int
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+}
+
+type udpSocket struct {
+	e     *udpEngine
+	conn  *net.UDPConn
+	batch batchConn
+	out   chan outgoingQuery
+	// completed is closed by the receiver goroutine when it exits.
+	completed chan completedQuery
+
+	mu      sync.Mutex
+	pending map[uint16]*udpQuery
+	recent  map[uint16]recentQuery
+	closed  bool
+}
+
+func (b *Benchmark) initUDPEngine() error {
+	if b.useDoH || b.useQuic || b.useDNSCrypt || b.TCP || b.DOT {
+		return errors.New("--udp-engine is supported only for plain DNS over UDP")
+	}
+	if b.Retries > 0 || b.TCPFallback || b.Pipeline > 1 || b.DNSSECValidation || b.churn() {
+		return errors.New("--udp-engine cannot be combined with --retries, --tcp-fallback, --pipeline, --dnssec-validate, --query-per-conn, --conn-per-query or --conn-rate")
+	}
+	if b.UDPEngineSockets == 0 {
+		b.UDPEngineSockets = DefaultUDPEngineSockets
+	}
+	if b.MaxOutstanding == 0 {
+		b.MaxOutstanding = DefaultMaxOutstanding
+	}
+	if b.UDPEngineSockets < 0 || b.MaxOutstanding < 0 {
+		return errors.New("--udp-engine-sockets and --max-outstanding must not be negative")
+	}
+	if b.MaxOutstanding > b.UDPEngineSockets*maxQueriesPerSocket {
+		return fmt.Errorf("--max-outstanding must not exceed %d outstanding queries per socket", maxQueriesPerSocket)
+	}
+	return nil
+}
+
+func (b *Benchmark) newUDPEngine(ctx context.Context) (*udpEngine, error) {
+	e := &udpEngine{b: b}
+	dialer := net.Dialer{Timeout: b.ConnectTimeout, Control: b.socketControl}
+	for i := 0; i < b.UDPEngineSockets; i++ {
+		c, err := dialer.DialContext(ctx, b.ipNetwork(UDPTransport), b.Server)
+		if err != nil {
+			e.close()
+			return nil, err
+		}
+		b.tuneConn(c)
+		conn := c.(*net.UDPConn)
+		s := &udpSocket{
+			e:         e,
+			conn:      conn,
+			out:       make(chan outgoingQuery, udpEngineBatchSize*16),
+			completed: make(chan completedQuery, udpEngineBatchSize*16),
+			pending:   make(map[uint16]*udpQuery),
+			recent:    make(map[uint16]recentQuery),
+		}
+		if addr, ok := conn.RemoteAddr().(*net.UDPAddr); ok && addr.IP.To4() == nil {
+			s.batch = ipv6.NewPacketConn(conn)
+		} else {
+			s.batch = ipv4.NewPacketConn(conn)
+		}
+		e.sockets = append(e.sockets, s)
+	}
+	for _, s := range e.sockets {
+		e.wg.Add(4)
+		go s.sendLoop()
+		go s.receiveLoop()
+		go s.completeLoop()
+		go s.expireLoop()
+	}
+	return e, nil
+}
+
+// send sends the query asynchronously, the ID of the query message is assigned by the engine.
+func (e *udpEngine) send(q *udpQuery) {
+	e.mu.Lock()
+	start := e.next
+	e.next = (e.next + 1) % len(e.sockets)
+	e.mu.Unlock()
+
+	for i := range e.sockets {
+		s := e.sockets[(start+i)%len(e.sockets)]
+		if s.send(q) {
+			return
+		}
+	}
+	q.done(nil, errors.New("no free query ID, too many outstanding queries"), time.Now())
+}
+
+// close closes the sockets and waits for the sender, receiver and completer goroutines to finish.
+func (e *udpEngine) close() {
+	for _, s := range e.sockets {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.out)
+		s.conn.Close()
+	}
+	e.wg.Wait()
+}
+
+// send registers the query as outstanding and queues it for sending, false is returned when all IDs of the socket are used.
+func (s *udpSocket) send(q *udpQuery) bool {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		q.done(nil, errUDPEngineClosed, time.Now())
+		return true
+	}
+	if len(s.pending) >= maxQueriesPerSocket {
+		s.mu.Unlock()
+		return false
+	}
+	id := s.freeID()
+	q.msg.Id = id
+	packed := packetPool.Get().(*[]byte)
+	if q.packed != nil {
+		*packed = append((*packed)[:0], q.packed...)
+		binary.BigEndian.PutUint16(*packed, id)
+	} else {
+		buf, err := q.msg.PackBuffer((*packed)[:cap(*packed)])
+		if err != nil {
+			s.mu.Unlock()
+			packetPool.Put(packed)
+			q.done(nil, err, time.Now())
+			return true
+		}
+		*packed = buf
+	}
+	s.pending[id] = q
+	s.mu.Unlock()
+
+	s.out <- outgoingQuery{id: id, packed: packed}
+	return true
+}
+
+// freeID returns random ID not used by any outstanding query, IDs of recently answered or timed out queries are avoided if possible,
+// so that the late and duplicate responses can be detected.
+func (s *udpSocket) freeID() uint16 {
+	for i := 0; i < 16; i++ {
+		id := dns.Id()
+		_, pending := s.pending[id]
+		_, recent := s.recent[id]
+		if !pending && !recent {
+			return id
+		}
+	}
+	for {
+		id := dns.Id()
+		if _, pending := s.pending[id]; !pending {
+			delete(s.recent, id)
+			return id
+		}
+	}
+}
+
+func (s *udpSocket) sendLoop() {
+	defer s.e.wg.Done()
+
+	batch := make([]outgoingQuery, 0, udpEngineBatchSize)
+	ms := make([]ipv4.Message, udpEngineBatchSize)
+	for o := range s.out {
+		batch = append(batch[:0], o)
+	gather:
+		for len(batch) < udpEngineBatchSize {
+			select {
+			case o, ok := <-s.out:
+				if !ok {
+					break gather
+				}
+				batch = append(batch, o)
+			default:
+				break gather
+			}
+		}
+
+		for i, o := range batch {
+			if ms[i].Buffers == nil {
+				ms[i].Buffers = make([][]byte, 1)
+			}
+			ms[i].Buffers[0] = *o.packed
+		}
+		for sent := 0; sent < len(batch); {
+			n, err := s.batch.WriteBatch(ms[sent:len(batch)], 0)
+			if err != nil {
+				for _, o := range batch[sent:] {
+					s.fail(o.id, err)
+				}
+				break
+			}
+			sent += n
+		}
+		for _, o := range batch {
+			packetPool.Put(o.packed)
+		}
+	}
+}
+
+func (s *udpSocket) receiveLoop() {
+	defer s.e.wg.Done()
+	defer close(s.completed)
+
+	ms := make([]ipv4.Message, udpEngineBatchSize)
+	for i := range ms {
+		ms[i].Buffers = [][]byte{make([]byte, dns.MaxMsgSize)}
+	}
+	for {
+		n, err := s.batch.ReadBatch(ms, 0)
+		if err != nil {
+			if s.isClosed() {
+				return
+			}
+			continue
+		}
+		now := time.Now()
+		for _, m := range ms[:n] {
+			if m.N < 2 {
+				continue
+			}
+			buf := m.Buffers[0][:m.N]
+			id := binary.BigEndian.Uint16(buf)
+			resp := new(dns.Msg)
+			err := resp.Unpack(buf)
+			if err != nil {
+				resp = nil
+			}
+			s.deliver(id, resp, err, now)
+		}
+	}
+}
+
+func (s *udpSocket) deliver(id uint16, resp *dns.Msg, err error, now time.Time) {
+	s.mu.Lock()
+	if q, ok := s.pending[id]; ok {
+		delete(s.pending, id)
+		s.recent[id] = recentQuery{q: q, answered: true, until: now.Add(udpEngineRecentWindow)}
+		s.mu.Unlock()
+		s.completed <- completedQuery{q: q, resp: resp, err: err, end: now}
+		return
+	}
+	r, ok := s.recent[id]
+	s.mu.Unlock()
+	if ok && r.q.stray != nil {
+		s.completed <- completedQuery{q: r.q, stray: true, answered: r.answered}
+	}
+}
+
+// completeLoop calls the callbacks of the queries matched by the receiver goroutine.
+func (s *udpSocket) completeLoop() {
+	defer s.e.wg.Done()
+
+	for c := range s.completed {
+		if c.stray {
+			c.q.stray(!c.answered)
+		} else {
+			c.q.done(c.resp, c.err, c.end)
+		}
+	}
+}
+
+// expireLoop times out the outstanding queries and forgets the recent queries.
+func (s *udpSocket) expireLoop() {
+	defer s.e.wg.Done()
+
+	interval := min(max(s.e.b.RequestTimeout/10, time.Millisecond), 100*time.Millisecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var expired []*udpQuery
+	for range ticker.C {
+		if s.isClosed() {
+			return
+		}
+		now := time.Now()
+		expired = expired[:0]
+		s.mu.Lock()
+		for id, q := range s.pending {
+			if now.After(q.deadline) {
+				delete(s.pending, id)
+				s.recent[id] = recentQuery{q: q, until: now.Add(udpEngineRecentWindow)}
+				expired = append(expired, q)
+			}
+		}
+		for id, r := range s.recent {
+			if now.After(r.until) {
+				delete(s.recent, id)
+			}
+		}
+		s.mu.Unlock()
+
+		for _, q := range expired {
+			q.done(nil, &net.OpError{Op: "read", Net: UDPTransport, Source: s.conn.LocalAddr(), Addr: s.conn.RemoteAddr(), Err: os.ErrDeadlineExceeded}, now)
+		}
+	}
+}
+
+func (s *udpSocket) fail(id uint16, err error) {
+	s.mu.Lock()
+	q, ok := s.pending[id]
+	delete(s.pending, id)
+	s.mu.Unlock()
+	if ok {
+		q.done(nil, err, time.Now())
+	}
+}
+
+func (s *udpSocket) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
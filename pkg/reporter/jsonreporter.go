@@ -3,9 +3,12 @@ package reporter
 import (
 	"encoding/json"
 	"math"
+	"strings"
 	"time"
 
+	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/miekg/dns"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
 )
 
 type jsonReporter struct{}
@@ -22,27 +25,373 @@ type latencyStats struct {
 	P50Ms  int64 `json:"p50Ms"`
 }
 
+// phaseLatencyStats are latency stats of the request phases keyed by the phase name, see phaseKeys.
+type phaseLatencyStats struct {
+	NewConnection    map[string]latencyStats `json:"newConnection,omitempty"`
+	ReusedConnection map[string]latencyStats `json:"reusedConnection,omitempty"`
+}
+
+var phaseKeys = map[dnsbench.Phase]string{
+	dnsbench.PhaseDNSResolve:    "dnsResolve",
+	dnsbench.PhaseConnect:       "connect",
+	dnsbench.PhaseTLSHandshake:  "tlsHandshake",
+	dnsbench.PhaseQUICHandshake: "quicHandshake",
+	dnsbench.PhaseFirstByte:     "firstByte",
+}
+
+func newPhaseStats(hists map[dnsbench.Phase]*hdrhistogram.Histogram) map[string]latencyStats {
+	if len(hists) == 0 {
+		return nil
+	}
+	stats := make(map[string]latencyStats)
+	for p, hist := range hists {
+		stats[phaseKeys[p]] = newLatencyStats(hist)
+	}
+	return stats
+}
+
+type tlsHandshakes struct {
+	Full                int64         `json:"full"`
+	Resumed             int64         `json:"resumed"`
+	ResumedRatio        float64       `json:"resumedRatio"`
+	ZeroRTT             int64         `json:"zeroRTT"`
+	FullLatencyStats    *latencyStats `json:"fullLatencyStats,omitempty"`
+	ResumedLatencyStats *latencyStats `json:"resumedLatencyStats,omitempty"`
+	// MeanDifferenceMs is difference between mean durations of the resumed and full handshakes.
+	MeanDifferenceMs *float64 `json:"meanDifferenceMs,omitempty"`
+}
+
+func newTLSHandshakes(h *dnsbench.HandshakeStats) *tlsHandshakes {
+	res := tlsHandshakes{
+		Full:    h.Full.TotalCount(),
+		Resumed: h.Resumed.TotalCount(),
+		ZeroRTT: h.ZeroRTT,
+	}
+	if total := res.Full + res.Resumed; total > 0 {
+		res.ResumedRatio = math.Round(float64(res.Resumed)/float64(total)*10000) / 10000
+	}
+	if res.Full > 0 {
+		stats := newLatencyStats(h.Full)
+		res.FullLatencyStats = &stats
+	}
+	if res.Resumed > 0 {
+		stats := newLatencyStats(h.Resumed)
+		res.ResumedLatencyStats = &stats
+	}
+	if res.Full > 0 && res.Resumed > 0 {
+		diff := math.Round((h.Resumed.Mean()-h.Full.Mean())/float64(time.Millisecond)*100) / 100
+		res.MeanDifferenceMs = &diff
+	}
+	return &res
+}
+
+type connections struct {
+	Opened                   int64         `json:"opened"`
+	ReusedQueries            int64         `json:"reusedQueries"`
+	ClosedByServer           int64         `json:"closedByServer"`
+	ResetOnError             int64         `json:"resetOnError"`
+	FirstQueryLatencyStats   *latencyStats `json:"firstQueryLatencyStats,omitempty"`
+	LaterQueriesLatencyStats *latencyStats `json:"laterQueriesLatencyStats,omitempty"`
+}
+
+func newConnections(c *dnsbench.ConnStats) *connections {
+	res := connections{
+		Opened:         c.Opened,
+		ReusedQueries:  c.Reused,
+		ClosedByServer: c.ClosedByServer,
+		ResetOnError:   c.ResetOnError,
+	}
+	if c.FirstQuery.TotalCount() > 0 {
+		stats := newLatencyStats(c.FirstQuery)
+		res.FirstQueryLatencyStats = &stats
+	}
+	if c.ReusedQuery.TotalCount() > 0 {
+		stats := newLatencyStats(c.ReusedQuery)
+		res.LaterQueriesLatencyStats = &stats
+	}
+	return &res
+}
+
+type valueStats struct {
+	Min  int64   `json:"min"`
+	Mean float64 `json:"mean"`
+	Max  int64   `json:"max"`
+	P50  int64   `json:"p50"`
+	P99  int64   `json:"p99"`
+}
+
+func newValueStats(hist *hdrhistogram.Histogram) *valueStats {
+	if hist.TotalCount() == 0 {
+		return nil
+	}
+	return &valueStats{
+		Min:  hist.Min(),
+		Mean: math.Round(hist.Mean()*100) / 100,
+		Max:  hist.Max(),
+		P50:  hist.ValueAtQuantile(50),
+		P99:  hist.ValueAtQuantile(99),
+	}
+}
+
+type dohAnalytics struct {
+	Protocols             map[string]int64 `json:"protocols"`
+	Fallback              int64            `json:"fallback"`
+	CacheControl          int64            `json:"cacheControl"`
+	MaxAgeSeconds         *valueStats      `json:"maxAgeSeconds,omitempty"`
+	Cached                int64            `json:"cached"`
+	AgeSeconds            *valueStats      `json:"ageSeconds,omitempty"`
+	HeaderSizeBytes       *valueStats      `json:"headerSizeBytes,omitempty"`
+	ReusedConnection      int64            `json:"reusedConnection"`
+	RequestsPerConnection *valueStats      `json:"requestsPerConnection,omitempty"`
+}
+
+func newDoHAnalytics(d *dnsbench.DoHStats) *dohAnalytics {
+	return &dohAnalytics{
+		Protocols:             d.Protocols,
+		Fallback:              d.Fallback,
+		CacheControl:          d.CacheControl,
+		MaxAgeSeconds:         newValueStats(d.MaxAge),
+		Cached:                d.Cached,
+		AgeSeconds:            newValueStats(d.Age),
+		HeaderSizeBytes:       newValueStats(d.HeaderSize),
+		ReusedConnection:      d.ReusedConn,
+		RequestsPerConnection: newValueStats(d.StreamsPerConn),
+	}
+}
+
+// odohLatencyStats is the latency breakdown of ODoH queries, the target and proxy stats are present only when the direct probes are enabled.
+type odohLatencyStats struct {
+	Crypto   latencyStats  `json:"cryptoLatencyStats"`
+	ViaProxy latencyStats  `json:"viaProxyLatencyStats"`
+	Target   *latencyStats `json:"targetLatencyStats,omitempty"`
+	Proxy    *latencyStats `json:"proxyLatencyStats,omitempty"`
+}
+
+func newODoHLatencyStats(o *dnsbench.ODoHStats) *odohLatencyStats {
+	res := odohLatencyStats{
+		Crypto:   newLatencyStats(o.Crypto),
+		ViaProxy: newLatencyStats(o.Proxied),
+	}
+	if o.Target.TotalCount() > 0 {
+		target := newLatencyStats(o.Target)
+		proxy := newLatencyStats(o.Proxy)
+		res.Target, res.Proxy = &target, &proxy
+	}
+	return &res
+}
+
+// dsoSessions are the statistics of DSO sessions, the negotiated timeouts are -1 when the server disabled them.
+type dsoSessions struct {
+	Sessions              int64         `json:"sessions"`
+	SetupLatencyStats     *latencyStats `json:"setupLatencyStats,omitempty"`
+	InactivityTimeoutMs   *int64        `json:"inactivityTimeoutMs,omitempty"`
+	KeepaliveIntervalMs   *int64        `json:"keepaliveIntervalMs,omitempty"`
+	Keepalives            int64         `json:"keepalives"`
+	KeepaliveLatencyStats *latencyStats `json:"keepaliveLatencyStats,omitempty"`
+	KeepaliveUpdates      int64         `json:"keepaliveUpdates"`
+	InactivityCloses      int64         `json:"inactivityCloses"`
+	Rejected              int64         `json:"rejected"`
+	RetryDelays           int64         `json:"retryDelays"`
+	Malformed             int64         `json:"malformed"`
+}
+
+func newDSOSessions(d *dnsbench.DSOStats) *dsoSessions {
+	res := dsoSessions{
+		Sessions:         d.Sessions,
+		Keepalives:       d.Keepalives.TotalCount(),
+		KeepaliveUpdates: d.KeepaliveUpdates,
+		InactivityCloses: d.InactivityCloses,
+		Rejected:         d.Rejected,
+		RetryDelays:      d.RetryDelays,
+		Malformed:        d.Malformed,
+	}
+	if d.Setup.TotalCount() > 0 {
+		setup := newLatencyStats(d.Setup)
+		inactivityTimeout, keepaliveInterval := dsoTimeoutMs(d.InactivityTimeout), dsoTimeoutMs(d.KeepaliveInterval)
+		res.SetupLatencyStats, res.InactivityTimeoutMs, res.KeepaliveIntervalMs = &setup, &inactivityTimeout, &keepaliveInterval
+	}
+	if d.Keepalives.TotalCount() > 0 {
+		keepalives := newLatencyStats(d.Keepalives)
+		res.KeepaliveLatencyStats = &keepalives
+	}
+	return &res
+}
+
+func dsoTimeoutMs(d time.Duration) int64 {
+	if d == dnsbench.DSOInfinite {
+		return -1
+	}
+	return d.Milliseconds()
+}
+
+// idleTimeout are the idle timeouts of the connections, the advertised timeout is present only when edns-tcp-keepalive option is sent
+// and the probed idle gaps only when the idle timeout is probed.
+type idleTimeout struct {
+	KeepaliveResponses   *int64 `json:"keepaliveResponses,omitempty"`
+	AdvertisedTimeoutMs  *int64 `json:"advertisedTimeoutMs,omitempty"`
+	ExpiredConnections   *int64 `json:"expiredConnections,omitempty"`
+	IdleProbes           *int64 `json:"idleProbes,omitempty"`
+	IdleProbesClosed     *int64 `json:"idleProbesClosed,omitempty"`
+	LongestOpenIdleMs    *int64 `json:"longestOpenIdleMs,omitempty"`
+	ShortestClosedIdleMs *int64 `json:"shortestClosedIdleMs,omitempty"`
+}
+
+func newIdleTimeout(b *dnsbench.Benchmark, i *dnsbench.IdleStats) *idleTimeout {
+	res := idleTimeout{}
+	if b.TCPKeepalive {
+		responses, expired := i.KeepaliveResponses, i.KeepaliveExpired
+		res.KeepaliveResponses, res.ExpiredConnections = &responses, &expired
+		if responses > 0 {
+			timeout := i.KeepaliveTimeout.Milliseconds()
+			res.AdvertisedTimeoutMs = &timeout
+		}
+	}
+	if b.IdleProbe > 0 {
+		probes, closed := i.Probes, i.ProbesClosed
+		res.IdleProbes, res.IdleProbesClosed = &probes, &closed
+		if i.LongestOpen > 0 {
+			longestOpen := i.LongestOpen.Milliseconds()
+			res.LongestOpenIdleMs = &longestOpen
+		}
+		if i.ShortestClosed > 0 {
+			shortestClosed := i.ShortestClosed.Milliseconds()
+			res.ShortestClosedIdleMs = &shortestClosed
+		}
+	}
+	return &res
+}
+
+// sourceStats are the results of the workers sending the queries from the same source address.
+type sourceStats struct {
+	SourceAddress          string       `json:"sourceAddress"`
+	TotalRequests          int64        `json:"totalRequests"`
+	TotalSuccessResponses  int64        `json:"totalSuccessResponses"`
+	TotalNegativeResponses int64        `json:"totalNegativeResponses"`
+	TotalErrorResponses    int64        `json:"totalErrorResponses"`
+	TotalIOErrors          int64        `json:"totalIOErrors"`
+	LatencyStats           latencyStats `json:"latencyStats"`
+}
+
+func newSourceStats(sources map[string]*SourceStats) []sourceStats {
+	var res []sourceStats
+	for _, addr := range sortedSources(sources) {
+		src := sources[addr]
+		res = append(res, sourceStats{
+			SourceAddress:          addr,
+			TotalRequests:          src.Counters.Total,
+			TotalSuccessResponses:  src.Counters.Success,
+			TotalNegativeResponses: src.Counters.Negative,
+			TotalErrorResponses:    src.Counters.Error,
+			TotalIOErrors:          src.Counters.IOError,
+			LatencyStats:           newLatencyStats(src.Hist),
+		})
+	}
+	return res
+}
+
+// socketSettings are the effective options of the first socket of the network opened by the benchmark.
+type socketSettings struct {
+	Network            string `json:"network"`
+	ReceiveBufferBytes int    `json:"receiveBufferBytes"`
+	SendBufferBytes    int    `json:"sendBufferBytes"`
+	DSCP               int    `json:"dscp"`
+	TCPNoDelay         *bool  `json:"tcpNoDelay,omitempty"`
+	TCPFastOpen        *bool  `json:"tcpFastOpen,omitempty"`
+}
+
+func newSocketSettings(settings []dnsbench.SocketSettings) []socketSettings {
+	var res []socketSettings
+	for _, s := range settings {
+		js := socketSettings{
+			Network:            s.Network,
+			ReceiveBufferBytes: s.ReceiveBufferSize,
+			SendBufferBytes:    s.SendBufferSize,
+			DSCP:               s.DSCP,
+		}
+		if strings.HasPrefix(s.Network, dnsbench.TCPTransport) {
+			noDelay, fastOpen := s.TCPNoDelay, s.TCPFastOpen
+			js.TCPNoDelay, js.TCPFastOpen = &noDelay, &fastOpen
+		}
+		res = append(res, js)
+	}
+	return res
+}
+
+type pipelineDepth struct {
+	MeanInFlight float64 `json:"meanInFlight"`
+	MaxInFlight  int64   `json:"maxInFlight"`
+}
+
+func newLatencyStats(hist *hdrhistogram.Histogram) latencyStats {
+	return latencyStats{
+		MinMs:  time.Duration(hist.Min()).Milliseconds(),
+		MeanMs: time.Duration(hist.Mean()).Milliseconds(),
+		StdMs:  time.Duration(hist.StdDev()).Milliseconds(),
+		MaxMs:  time.Duration(hist.Max()).Milliseconds(),
+		P99Ms:  time.Duration(hist.ValueAtQuantile(99)).Milliseconds(),
+		P95Ms:  time.Duration(hist.ValueAtQuantile(95)).Milliseconds(),
+		P90Ms:  time.Duration(hist.ValueAtQuantile(90)).Milliseconds(),
+		P75Ms:  time.Duration(hist.ValueAtQuantile(75)).Milliseconds(),
+		P50Ms:  time.Duration(hist.ValueAtQuantile(50)).Milliseconds(),
+	}
+}
+
 type histogramPoint struct {
 	LatencyMs int64 `json:"latencyMs"`
 	Count     int64 `json:"count"`
 }
 
+type extendedError struct {
+	InfoCode     uint16 `json:"infoCode"`
+	InfoCodeName string `json:"infoCodeName"`
+	ExtraText    string `json:"extraText,omitempty"`
+	Count        int64  `json:"count"`
+}
+
+type dnssecValidation struct {
+	Secure        int64 `json:"secure"`
+	Insecure      int64 `json:"insecure"`
+	Bogus         int64 `json:"bogus"`
+	Indeterminate int64 `json:"indeterminate"`
+}
+
 type jsonResult struct {
-	TotalRequests              int64            `json:"totalRequests"`
-	TotalSuccessResponses      int64            `json:"totalSuccessResponses"`
-	TotalNegativeResponses     int64            `json:"totalNegativeResponses"`
-	TotalErrorResponses        int64            `json:"totalErrorResponses"`
-	TotalIOErrors              int64            `json:"totalIOErrors"`
-	TotalIDmismatch            int64            `json:"totalIDmismatch"`
-	TotalTruncatedResponses    int64            `json:"totalTruncatedResponses"`
-	ResponseRcodes             map[string]int64 `json:"responseRcodes,omitempty"`
-	QuestionTypes              map[string]int64 `json:"questionTypes"`
-	QueriesPerSecond           float64          `json:"queriesPerSecond"`
-	BenchmarkDurationSeconds   float64          `json:"benchmarkDurationSeconds"`
-	LatencyStats               latencyStats     `json:"latencyStats"`
-	LatencyDistribution        []histogramPoint `json:"latencyDistribution,omitempty"`
-	TotalDNSSECSecuredDomains  *int             `json:"totalDNSSECSecuredDomains,omitempty"`
-	DohHTTPResponseStatusCodes map[int]int64    `json:"dohHTTPResponseStatusCodes,omitempty"`
+	TotalRequests              int64              `json:"totalRequests"`
+	TotalSuccessResponses      int64              `json:"totalSuccessResponses"`
+	TotalNegativeResponses     int64              `json:"totalNegativeResponses"`
+	TotalErrorResponses        int64              `json:"totalErrorResponses"`
+	TotalIOErrors              int64              `json:"totalIOErrors"`
+	TotalIDmismatch            int64              `json:"totalIDmismatch"`
+	TotalTruncatedResponses    int64              `json:"totalTruncatedResponses"`
+	TotalCaseMismatch          int64              `json:"totalCaseMismatch,omitempty"`
+	TotalQuestionMismatch      int64              `json:"totalQuestionMismatch,omitempty"`
+	TotalWrongAnswers          int64              `json:"totalWrongAnswers,omitempty"`
+	TotalRetries               int64              `json:"totalRetries,omitempty"`
+	TotalTCPFallbacks          int64              `json:"totalTCPFallbacks,omitempty"`
+	TotalLateResponses         int64              `json:"totalLateResponses,omitempty"`
+	TotalDuplicateResponses    int64              `json:"totalDuplicateResponses,omitempty"`
+	WrongAnswersPerDomain      map[string]int64   `json:"wrongAnswersPerDomain,omitempty"`
+	ResponseRcodes             map[string]int64   `json:"responseRcodes,omitempty"`
+	QuestionTypes              map[string]int64   `json:"questionTypes"`
+	QueriesPerSecond           float64            `json:"queriesPerSecond"`
+	BenchmarkDurationSeconds   float64            `json:"benchmarkDurationSeconds"`
+	LatencyStats               latencyStats       `json:"latencyStats"`
+	LatencyDistribution        []histogramPoint   `json:"latencyDistribution,omitempty"`
+	AttemptLatencyStats        *latencyStats      `json:"attemptLatencyStats,omitempty"`
+	PipelineDepth              *pipelineDepth     `json:"pipelineDepth,omitempty"`
+	PhaseLatencyStats          *phaseLatencyStats `json:"phaseLatencyStats,omitempty"`
+	TLSHandshakes              *tlsHandshakes     `json:"tlsHandshakes,omitempty"`
+	Connections                *connections       `json:"connections,omitempty"`
+	DoHAnalytics               *dohAnalytics      `json:"dohAnalytics,omitempty"`
+	ODoH                       *odohLatencyStats  `json:"odoh,omitempty"`
+	DSO                        *dsoSessions       `json:"dso,omitempty"`
+	IdleTimeout                *idleTimeout       `json:"idleTimeout,omitempty"`
+	Sources                    []sourceStats      `json:"sources,omitempty"`
+	SocketSettings             []socketSettings   `json:"socketSettings,omitempty"`
+	TotalDNSSECSecuredDomains  *int               `json:"totalDNSSECSecuredDomains,omitempty"`
+	DohHTTPResponseStatusCodes map[int]int64      `json:"dohHTTPResponseStatusCodes,omitempty"`
+	ExtendedDNSErrors          []extendedError    `json:"extendedDNSErrors,omitempty"`
+	DNSSECValidation           *dnssecValidation  `json:"dnssecValidation,omitempty"`
 }
 
 func (s *jsonReporter) print(params reportParameters) error {
@@ -82,31 +431,84 @@ func (s *jsonReporter) print(params reportParameters) error {
 	}
 
 	result := jsonResult{
-		TotalRequests:            params.totalCounters.Total,
-		TotalSuccessResponses:    params.totalCounters.Success,
-		TotalNegativeResponses:   params.totalCounters.Negative,
-		TotalErrorResponses:      params.totalCounters.Error,
-		TotalIOErrors:            params.totalCounters.IOError,
-		TotalIDmismatch:          params.totalCounters.IDmismatch,
-		TotalTruncatedResponses:  params.totalCounters.Truncated,
-		QueriesPerSecond:         math.Round(float64(params.totalCounters.Total)/params.benchmarkDuration.Seconds()*100) / 100,
-		BenchmarkDurationSeconds: roundDuration(params.benchmarkDuration).Seconds(),
-		ResponseRcodes:           codeTotalsMapped,
-		QuestionTypes:            params.qtypeTotals,
-		LatencyStats: latencyStats{
-			MinMs:  time.Duration(params.hist.Min()).Milliseconds(),
-			MeanMs: time.Duration(params.hist.Mean()).Milliseconds(),
-			StdMs:  time.Duration(params.hist.StdDev()).Milliseconds(),
-			MaxMs:  time.Duration(params.hist.Max()).Milliseconds(),
-			P99Ms:  time.Duration(params.hist.ValueAtQuantile(99)).Milliseconds(),
-			P95Ms:  time.Duration(params.hist.ValueAtQuantile(95)).Milliseconds(),
-			P90Ms:  time.Duration(params.hist.ValueAtQuantile(90)).Milliseconds(),
-			P75Ms:  time.Duration(params.hist.ValueAtQuantile(75)).Milliseconds(),
-			P50Ms:  time.Duration(params.hist.ValueAtQuantile(50)).Milliseconds(),
-		},
+		TotalRequests:              params.totalCounters.Total,
+		TotalSuccessResponses:      params.totalCounters.Success,
+		TotalNegativeResponses:     params.totalCounters.Negative,
+		TotalErrorResponses:        params.totalCounters.Error,
+		TotalIOErrors:              params.totalCounters.IOError,
+		TotalIDmismatch:            params.totalCounters.IDmismatch,
+		TotalTruncatedResponses:    params.totalCounters.Truncated,
+		TotalCaseMismatch:          params.totalCounters.CaseMismatch,
+		TotalQuestionMismatch:      params.totalCounters.QuestionMismatch,
+		TotalWrongAnswers:          params.totalCounters.WrongAnswer,
+		TotalRetries:               params.totalCounters.Retries,
+		TotalTCPFallbacks:          params.totalCounters.TCPFallback,
+		WrongAnswersPerDomain:      params.wrongAnswersTotals,
+		QueriesPerSecond:           math.Round(float64(params.totalCounters.Total)/params.benchmarkDuration.Seconds()*100) / 100,
+		BenchmarkDurationSeconds:   roundDuration(params.benchmarkDuration).Seconds(),
+		ResponseRcodes:             codeTotalsMapped,
+		QuestionTypes:              params.qtypeTotals,
+		LatencyStats:               newLatencyStats(params.hist),
 		LatencyDistribution:        res,
 		DohHTTPResponseStatusCodes: params.dohResponseStatusesTotals,
 	}
+	if params.attemptHist != nil {
+		attemptLatencyStats := newLatencyStats(params.attemptHist)
+		result.AttemptLatencyStats = &attemptLatencyStats
+	}
+	if params.pipelineDepth != nil {
+		result.PipelineDepth = &pipelineDepth{
+			MeanInFlight: params.pipelineDepth.Mean(),
+			MaxInFlight:  params.pipelineDepth.Max(),
+		}
+	}
+	if params.phaseHists != nil {
+		result.PhaseLatencyStats = &phaseLatencyStats{
+			NewConnection:    newPhaseStats(params.phaseHists.NewConn),
+			ReusedConnection: newPhaseStats(params.phaseHists.ReusedConn),
+		}
+	}
+	if params.handshakes != nil {
+		result.TLSHandshakes = newTLSHandshakes(params.handshakes)
+	}
+	if params.conns != nil {
+		result.Connections = newConnections(params.conns)
+	}
+	if params.doh != nil {
+		result.DoHAnalytics = newDoHAnalytics(params.doh)
+	}
+	if params.odoh != nil && params.odoh.Proxied.TotalCount() > 0 {
+		result.ODoH = newODoHLatencyStats(params.odoh)
+	}
+	if params.dso != nil {
+		result.DSO = newDSOSessions(params.dso)
+	}
+	if params.idle != nil {
+		result.IdleTimeout = newIdleTimeout(params.benchmark, params.idle)
+	}
+	if len(params.sources) > 0 {
+		result.Sources = newSourceStats(params.sources)
+	}
+	if len(params.socketSettings) > 0 {
+		result.SocketSettings = newSocketSettings(params.socketSettings)
+	}
+	for _, e := range sortedExtendedErrors(params.extendedErrorsTotals) {
+		result.ExtendedDNSErrors = append(result.ExtendedDNSErrors, extendedError{
+			InfoCode:     e.InfoCode,
+			InfoCodeName: dns.ExtendedErrorCodeToString[e.InfoCode],
+			ExtraText:    e.ExtraText,
+			Count:        params.extendedErrorsTotals[e],
+		})
+	}
+	if params.benchmark.DNSSECValidation {
+		v := params.dnssecValidationTotals
+		result.DNSSECValidation = &dnssecValidation{
+			Secure:        v.Secure,
+			Insecure:      v.Insecure,
+			Bogus:         v.Bogus,
+			Indeterminate: v.Indeterminate,
+		}
+	}
 	if params.benchmark.DNSSEC {
 		totalDNSSECSecuredDomains := len(params.authenticatedDomains)
 		result.TotalDNSSECSecuredDomains = &totalDNSSECSecuredDomains
@@ -20,6 +20,26 @@ type BenchmarkResultStats struct {
 	GroupedErrors        map[string]int
 	AuthenticatedDomains map[string]struct{}
 	DoHStatusCodes       map[int]int64
+	ExtendedErrors       map[dnsbench.ExtendedError]int64
+	DNSSECValidation     dnsbench.DNSSECCounters
+	WrongAnswers         map[string]int64
+	AttemptHist          *hdrhistogram.Histogram
+	PipelineDepth        *hdrhistogram.Histogram
+	PhaseHists           *dnsbench.PhaseHistograms
+	Handshakes           *dnsbench.HandshakeStats
+	Conns                *dnsbench.ConnStats
+	DoH                  *dnsbench.DoHStats
+	ODoH                 *dnsbench.ODoHStats
+	DSO                  *dnsbench.DSOStats
+	Idle                 *dnsbench.IdleStats
+	// Sources contains the results per source address, Sources is filled only when dnsbench.Benchmark.SourceAddresses is configured.
+	Sources map[string]*SourceStats
+}
+
+// SourceStats represents merged results of the workers sending the queries from the same source address.
+type SourceStats struct {
+	Counters dnsbench.Counters
+	Hist     *hdrhistogram.Histogram
 }
 
 // Merge takes results of the executed dnsbench.Benchmark and merges them.
@@ -31,6 +51,8 @@ func Merge(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats) BenchmarkResult
 		GroupedErrors:        make(map[string]int),
 		AuthenticatedDomains: make(map[string]struct{}),
 		DoHStatusCodes:       make(map[int]int64),
+		ExtendedErrors:       make(map[dnsbench.ExtendedError]int64),
+		WrongAnswers:         make(map[string]int64),
 	}
 
 	for _, s := range stats {
@@ -46,6 +68,124 @@ func Merge(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats) BenchmarkResult
 		totals.Errors = append(totals.Errors, s.Errors...)
 
 		totals.Hist.Merge(s.Hist)
+		if s.AttemptHist != nil {
+			if totals.AttemptHist == nil {
+				totals.AttemptHist = hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)
+			}
+			totals.AttemptHist.Merge(s.AttemptHist)
+		}
+		if s.PipelineDepth != nil {
+			if totals.PipelineDepth == nil {
+				totals.PipelineDepth = hdrhistogram.New(1, int64(b.Pipeline), 3)
+			}
+			totals.PipelineDepth.Merge(s.PipelineDepth)
+		}
+		if s.PhaseHists != nil {
+			if totals.PhaseHists == nil {
+				totals.PhaseHists = &dnsbench.PhaseHistograms{
+					NewConn:    make(map[dnsbench.Phase]*hdrhistogram.Histogram),
+					ReusedConn: make(map[dnsbench.Phase]*hdrhistogram.Histogram),
+				}
+			}
+			mergePhaseHists(b, totals.PhaseHists.NewConn, s.PhaseHists.NewConn)
+			mergePhaseHists(b, totals.PhaseHists.ReusedConn, s.PhaseHists.ReusedConn)
+		}
+		if s.Handshakes != nil {
+			if totals.Handshakes == nil {
+				totals.Handshakes = &dnsbench.HandshakeStats{
+					Full:    hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+					Resumed: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+				}
+			}
+			totals.Handshakes.Full.Merge(s.Handshakes.Full)
+			totals.Handshakes.Resumed.Merge(s.Handshakes.Resumed)
+			totals.Handshakes.ZeroRTT += s.Handshakes.ZeroRTT
+		}
+		if s.Conns != nil {
+			if totals.Conns == nil {
+				totals.Conns = &dnsbench.ConnStats{
+					FirstQuery:  hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+					ReusedQuery: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+				}
+			}
+			totals.Conns.Opened += s.Conns.Opened
+			totals.Conns.Reused += s.Conns.Reused
+			totals.Conns.ClosedByServer += s.Conns.ClosedByServer
+			totals.Conns.ResetOnError += s.Conns.ResetOnError
+			totals.Conns.FirstQuery.Merge(s.Conns.FirstQuery)
+			totals.Conns.ReusedQuery.Merge(s.Conns.ReusedQuery)
+		}
+		if s.DoH != nil {
+			if totals.DoH == nil {
+				totals.DoH = &dnsbench.DoHStats{
+					Protocols:      make(map[string]int64),
+					MaxAge:         emptyHistogram(s.DoH.MaxAge),
+					Age:            emptyHistogram(s.DoH.Age),
+					HeaderSize:     emptyHistogram(s.DoH.HeaderSize),
+					StreamsPerConn: emptyHistogram(s.DoH.StreamsPerConn),
+				}
+			}
+			for k, v := range s.DoH.Protocols {
+				totals.DoH.Protocols[k] += v
+			}
+			totals.DoH.Fallback += s.DoH.Fallback
+			totals.DoH.CacheControl += s.DoH.CacheControl
+			totals.DoH.Cached += s.DoH.Cached
+			totals.DoH.ReusedConn += s.DoH.ReusedConn
+			totals.DoH.MaxAge.Merge(s.DoH.MaxAge)
+			totals.DoH.Age.Merge(s.DoH.Age)
+			totals.DoH.HeaderSize.Merge(s.DoH.HeaderSize)
+			totals.DoH.StreamsPerConn.Merge(s.DoH.StreamsPerConn)
+		}
+		if s.ODoH != nil {
+			if totals.ODoH == nil {
+				totals.ODoH = &dnsbench.ODoHStats{
+					Crypto:  hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+					Proxied: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+					Target:  hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+					Proxy:   hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+				}
+			}
+			totals.ODoH.Crypto.Merge(s.ODoH.Crypto)
+			totals.ODoH.Proxied.Merge(s.ODoH.Proxied)
+			totals.ODoH.Target.Merge(s.ODoH.Target)
+			totals.ODoH.Proxy.Merge(s.ODoH.Proxy)
+		}
+		if s.DSO != nil {
+			if totals.DSO == nil {
+				totals.DSO = &dnsbench.DSOStats{
+					Setup:      hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+					Keepalives: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+				}
+			}
+			totals.DSO.Sessions += s.DSO.Sessions
+			totals.DSO.Setup.Merge(s.DSO.Setup)
+			totals.DSO.Keepalives.Merge(s.DSO.Keepalives)
+			if s.DSO.InactivityTimeout != 0 || s.DSO.KeepaliveInterval != 0 {
+				totals.DSO.InactivityTimeout, totals.DSO.KeepaliveInterval = s.DSO.InactivityTimeout, s.DSO.KeepaliveInterval
+			}
+			totals.DSO.KeepaliveUpdates += s.DSO.KeepaliveUpdates
+			totals.DSO.InactivityCloses += s.DSO.InactivityCloses
+			totals.DSO.Rejected += s.DSO.Rejected
+			totals.DSO.RetryDelays += s.DSO.RetryDelays
+			totals.DSO.Malformed += s.DSO.Malformed
+		}
+		if s.Idle != nil {
+			if totals.Idle == nil {
+				totals.Idle = &dnsbench.IdleStats{}
+			}
+			totals.Idle.KeepaliveResponses += s.Idle.KeepaliveResponses
+			if s.Idle.KeepaliveResponses > 0 {
+				totals.Idle.KeepaliveTimeout = s.Idle.KeepaliveTimeout
+			}
+			totals.Idle.KeepaliveExpired += s.Idle.KeepaliveExpired
+			totals.Idle.Probes += s.Idle.Probes
+			totals.Idle.ProbesClosed += s.Idle.ProbesClosed
+			totals.Idle.LongestOpen = max(totals.Idle.LongestOpen, s.Idle.LongestOpen)
+			if s.Idle.ShortestClosed != 0 && (totals.Idle.ShortestClosed == 0 || s.Idle.ShortestClosed < totals.Idle.ShortestClosed) {
+				totals.Idle.ShortestClosed = s.Idle.ShortestClosed
+			}
+		}
 		totals.Timings = append(totals.Timings, s.Timings...)
 		if s.Codes != nil {
 			for k, v := range s.Codes {
@@ -62,15 +202,35 @@ func Merge(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats) BenchmarkResult
 				totals.DoHStatusCodes[k] += v
 			}
 		}
+		for k, v := range s.ExtendedErrors {
+			totals.ExtendedErrors[k] += v
+		}
+		for k, v := range s.WrongAnswers {
+			totals.WrongAnswers[k] += v
+		}
 		if s.Counters != nil {
-			totals.Counters = dnsbench.Counters{
-				Total:      totals.Counters.Total + s.Counters.Total,
-				IOError:    totals.Counters.IOError + s.Counters.IOError,
-				Success:    totals.Counters.Success + s.Counters.Success,
-				Negative:   totals.Counters.Negative + s.Counters.Negative,
-				Error:      totals.Counters.Error + s.Counters.Error,
-				IDmismatch: totals.Counters.IDmismatch + s.Counters.IDmismatch,
-				Truncated:  totals.Counters.Truncated + s.Counters.Truncated,
+			totals.Counters = addCounters(totals.Counters, *s.Counters)
+		}
+		if len(s.SourceAddress) != 0 {
+			if totals.Sources == nil {
+				totals.Sources = make(map[string]*SourceStats)
+			}
+			src, ok := totals.Sources[s.SourceAddress]
+			if !ok {
+				src = &SourceStats{Hist: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)}
+				totals.Sources[s.SourceAddress] = src
+			}
+			src.Hist.Merge(s.Hist)
+			if s.Counters != nil {
+				src.Counters = addCounters(src.Counters, *s.Counters)
+			}
+		}
+		if s.DNSSECValidation != nil {
+			totals.DNSSECValidation = dnsbench.DNSSECCounters{
+				Secure:        totals.DNSSECValidation.Secure + s.DNSSECValidation.Secure,
+				Insecure:      totals.DNSSECValidation.Insecure + s.DNSSECValidation.Insecure,
+				Bogus:         totals.DNSSECValidation.Bogus + s.DNSSECValidation.Bogus,
+				Indeterminate: totals.DNSSECValidation.Indeterminate + s.DNSSECValidation.Indeterminate,
 			}
 		}
 		if b.DNSSEC {
@@ -92,6 +252,34 @@ func Merge(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats) BenchmarkResult
 	return totals
 }
 
+func addCounters(a, b dnsbench.Counters) dnsbench.Counters {
+	return dnsbench.Counters{
+		Total:            a.Total + b.Total,
+		IOError:          a.IOError + b.IOError,
+		Success:          a.Success + b.Success,
+		Negative:         a.Negative + b.Negative,
+		Error:            a.Error + b.Error,
+		IDmismatch:       a.IDmismatch + b.IDmismatch,
+		Truncated:        a.Truncated + b.Truncated,
+		CaseMismatch:     a.CaseMismatch + b.CaseMismatch,
+		QuestionMismatch: a.QuestionMismatch + b.QuestionMismatch,
+		WrongAnswer:      a.WrongAnswer + b.WrongAnswer,
+		Retries:          a.Retries + b.Retries,
+		TCPFallback:      a.TCPFallback + b.TCPFallback,
+		Late:             a.Late + b.Late,
+		Duplicate:        a.Duplicate + b.Duplicate,
+	}
+}
+
+func mergePhaseHists(b *dnsbench.Benchmark, totals, hists map[dnsbench.Phase]*hdrhistogram.Histogram) {
+	for p, h := range hists {
+		if _, ok := totals[p]; !ok {
+			totals[p] = hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)
+		}
+		totals[p].Merge(h)
+	}
+}
+
 func errString(err dnsbench.ErrorDatapoint) string {
 	var errorString string
 	var netOpErr *net.OpError
@@ -110,3 +298,8 @@ func errString(err dnsbench.ErrorDatapoint) string {
 	}
 	return errorString
 }
+
+// emptyHistogram returns new empty histogram with the same range and precision as the given histogram.
+func emptyHistogram(h *hdrhistogram.Histogram) *hdrhistogram.Histogram {
+	return hdrhistogram.New(h.LowestTrackableValue(), h.HighestTrackableValue(), int(h.SignificantFigures()))
+}
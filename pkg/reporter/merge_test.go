@@ -41,13 +41,18 @@ func TestMerge(t *testing.T) {
 				},
 			},
 			Counters: &dnsbench.Counters{
-				Success:    2,
-				Negative:   1,
-				Truncated:  1,
-				IOError:    2,
-				Error:      1,
-				IDmismatch: 1,
-				Total:      8,
+				Success:      2,
+				Negative:     1,
+				Truncated:    1,
+				IOError:      2,
+				Error:        1,
+				IDmismatch:   1,
+				CaseMismatch: 1,
+				WrongAnswer:  1,
+				Retries:      2,
+				TCPFallback:  1,
+				Late:         2,
+				Total:        8,
 			},
 			Errors: []dnsbench.ErrorDatapoint{
 				{
@@ -66,6 +71,12 @@ func TestMerge(t *testing.T) {
 				200: 5,
 				503: 1,
 			},
+			ExtendedErrors: map[dnsbench.ExtendedError]int64{
+				{InfoCode: dns.ExtendedErrorCodeDNSBogus}: 1,
+			},
+			WrongAnswers: map[string]int64{
+				"google.com.": 1,
+			},
 		},
 		{
 			Codes: map[int]int64{
@@ -89,13 +100,18 @@ func TestMerge(t *testing.T) {
 				},
 			},
 			Counters: &dnsbench.Counters{
-				Success:    1,
-				Negative:   1,
-				Truncated:  1,
-				IOError:    1,
-				Error:      1,
-				IDmismatch: 1,
-				Total:      6,
+				Success:      1,
+				Negative:     1,
+				Truncated:    1,
+				IOError:      1,
+				Error:        1,
+				IDmismatch:   1,
+				CaseMismatch: 2,
+				WrongAnswer:  2,
+				Retries:      1,
+				Late:         1,
+				Duplicate:    1,
+				Total:        6,
 			},
 			Errors: []dnsbench.ErrorDatapoint{
 				{
@@ -110,6 +126,14 @@ func TestMerge(t *testing.T) {
 				200: 4,
 				500: 1,
 			},
+			ExtendedErrors: map[dnsbench.ExtendedError]int64{
+				{InfoCode: dns.ExtendedErrorCodeDNSBogus}:                        2,
+				{InfoCode: dns.ExtendedErrorCodeStaleAnswer, ExtraText: "stale"}: 1,
+			},
+			WrongAnswers: map[string]int64{
+				"google.com.":  1,
+				"example.org.": 1,
+			},
 		},
 	}
 
@@ -147,13 +171,19 @@ func TestMerge(t *testing.T) {
 			},
 		},
 		Counters: dnsbench.Counters{
-			Success:    3,
-			Negative:   2,
-			Truncated:  2,
-			IOError:    3,
-			Error:      2,
-			IDmismatch: 2,
-			Total:      14,
+			Success:      3,
+			Negative:     2,
+			Truncated:    2,
+			IOError:      3,
+			Error:        2,
+			IDmismatch:   2,
+			CaseMismatch: 3,
+			WrongAnswer:  3,
+			Retries:      3,
+			TCPFallback:  1,
+			Late:         3,
+			Duplicate:    1,
+			Total:        14,
 		},
 		Errors: []dnsbench.ErrorDatapoint{
 			{
@@ -181,6 +211,14 @@ func TestMerge(t *testing.T) {
 			500: 1,
 			503: 1,
 		},
+		ExtendedErrors: map[dnsbench.ExtendedError]int64{
+			{InfoCode: dns.ExtendedErrorCodeDNSBogus}:                        3,
+			{InfoCode: dns.ExtendedErrorCodeStaleAnswer, ExtraText: "stale"}: 1,
+		},
+		WrongAnswers: map[string]int64{
+			"google.com.":  2,
+			"example.org.": 1,
+		},
 	}
 
 	res := reporter.Merge(&dnsbench.Benchmark{DNSSEC: true, HistMin: 0, HistMax: 5 * time.Second, HistPre: 1}, stats)
@@ -73,8 +73,8 @@ func plotBoxPlotLatency(file, server string, times []dnsbench.Datapoint) {
 	}
 }
 
-func plotResponses(file string, rcodes map[int]int64) {
-	if len(rcodes) == 0 {
+func plotResponses(file string, rcodes map[int]int64, extendedErrors map[dnsbench.ExtendedError]int64) {
+	if len(rcodes) == 0 && len(extendedErrors) == 0 {
 		// nothing to plot
 		return
 	}
@@ -102,7 +102,7 @@ func plotResponses(file string, rcodes map[int]int64) {
 	width := vg.Points(40)
 
 	c := 0
-	off := -vg.Length(len(rcodes)/2) * width
+	off := -vg.Length((len(rcodes)+len(extendedErrors))/2) * width
 	for _, v := range sortedKeys {
 		bar, err := plotter.NewBarChart(plotter.Values{float64(rcodes[v])}, width)
 		if err != nil {
@@ -115,6 +115,18 @@ func plotResponses(file string, rcodes map[int]int64) {
 		c++
 		off += width
 	}
+	for _, e := range sortedExtendedErrors(extendedErrors) {
+		bar, err := plotter.NewBarChart(plotter.Values{float64(extendedErrors[e])}, width)
+		if err != nil {
+			panic(err)
+		}
+		p.Legend.Add("EDE "+extendedErrorString(e), bar)
+		bar.Color = colors[c%len(colors)]
+		bar.Offset = off
+		p.Add(bar)
+		c++
+		off += width
+	}
 
 	p.Y.Label.Text = "Number of requests"
 	p.Y.Tick.Marker = hplot.Ticks{N: 3, Format: "%.0f"}
@@ -75,7 +75,7 @@ func Test_plotResponses(t *testing.T) {
 	dir := t.TempDir()
 
 	file := dir + "/responses-barchart.svg"
-	plotResponses(file, testRcodes)
+	plotResponses(file, testRcodes, nil)
 
 	expected, err := os.ReadFile("testdata/test-responses-barchart.svg")
 	require.NoError(t, err)
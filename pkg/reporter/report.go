@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
 	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
 )
 
@@ -26,6 +28,20 @@ type reportParameters struct {
 	authenticatedDomains      map[string]struct{}
 	benchmarkDuration         time.Duration
 	dohResponseStatusesTotals map[int]int64
+	extendedErrorsTotals      map[dnsbench.ExtendedError]int64
+	dnssecValidationTotals    dnsbench.DNSSECCounters
+	wrongAnswersTotals        map[string]int64
+	attemptHist               *hdrhistogram.Histogram
+	pipelineDepth             *hdrhistogram.Histogram
+	phaseHists                *dnsbench.PhaseHistograms
+	handshakes                *dnsbench.HandshakeStats
+	conns                     *dnsbench.ConnStats
+	doh                       *dnsbench.DoHStats
+	odoh                      *dnsbench.ODoHStats
+	dso                       *dnsbench.DSOStats
+	idle                      *dnsbench.IdleStats
+	sources                   map[string]*SourceStats
+	socketSettings            []dnsbench.SocketSettings
 }
 
 // PrintReport prints formatted benchmark result to stdout, exports graphs and generates CSV output if configured.
@@ -59,7 +75,7 @@ func PrintReport(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats, benchStar
 		}
 		plotHistogramLatency(fileName(b, dir, "latency-histogram"), totals.Timings)
 		plotBoxPlotLatency(fileName(b, dir, "latency-boxplot"), b.Server, totals.Timings)
-		plotResponses(fileName(b, dir, "responses-barchart"), totals.Codes)
+		plotResponses(fileName(b, dir, "responses-barchart"), totals.Codes, totals.ExtendedErrors)
 		plotLineThroughput(fileName(b, dir, "throughput-lineplot"), benchStart, totals.Timings)
 		plotLineLatencies(fileName(b, dir, "latency-lineplot"), benchStart, totals.Timings)
 		plotErrorRate(fileName(b, dir, "errorrate-lineplot"), benchStart, totals.Errors)
@@ -100,6 +116,20 @@ func PrintReport(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats, benchStar
 		authenticatedDomains:      totals.AuthenticatedDomains,
 		benchmarkDuration:         benchDuration,
 		dohResponseStatusesTotals: totals.DoHStatusCodes,
+		extendedErrorsTotals:      totals.ExtendedErrors,
+		dnssecValidationTotals:    totals.DNSSECValidation,
+		wrongAnswersTotals:        totals.WrongAnswers,
+		attemptHist:               totals.AttemptHist,
+		pipelineDepth:             totals.PipelineDepth,
+		phaseHists:                totals.PhaseHists,
+		handshakes:                totals.Handshakes,
+		conns:                     totals.Conns,
+		doh:                       totals.DoH,
+		odoh:                      totals.ODoH,
+		dso:                       totals.DSO,
+		idle:                      totals.Idle,
+		sources:                   totals.Sources,
+		socketSettings:            b.SocketSettings(),
 	}
 	if b.JSON {
 		j := jsonReporter{}
@@ -109,6 +139,43 @@ func PrintReport(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats, benchStar
 	return s.print(params)
 }
 
+// sortedSources returns the source addresses ordered alphabetically.
+func sortedSources(m map[string]*SourceStats) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedExtendedErrors returns keys of the extended errors map ordered by info-code and extra text.
+func sortedExtendedErrors(m map[dnsbench.ExtendedError]int64) []dnsbench.ExtendedError {
+	keys := make([]dnsbench.ExtendedError, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].InfoCode != keys[j].InfoCode {
+			return keys[i].InfoCode < keys[j].InfoCode
+		}
+		return keys[i].ExtraText < keys[j].ExtraText
+	})
+	return keys
+}
+
+func extendedErrorString(e dnsbench.ExtendedError) string {
+	name, ok := dns.ExtendedErrorCodeToString[e.InfoCode]
+	if !ok {
+		name = "Unknown"
+	}
+	res := fmt.Sprintf("%s (%d)", name, e.InfoCode)
+	if len(e.ExtraText) != 0 {
+		res += fmt.Sprintf(" %q", e.ExtraText)
+	}
+	return res
+}
+
 func fileName(b *dnsbench.Benchmark, dir, name string) string {
 	return dir + "/" + name + "." + b.PlotFormat
 }
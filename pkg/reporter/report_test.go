@@ -38,6 +38,247 @@ func Test_PrintReport_dnssec(t *testing.T) {
 	assert.Equal(t, readResource("dnssecReport"), buffer.String())
 }
 
+func Test_PrintReport_dnssec_validation(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.DNSSECValidation = true
+	rs.DNSSECValidation = &dnsbench.DNSSECCounters{Secure: 4, Insecure: 3, Bogus: 2, Indeterminate: 1}
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("dnssecValidationReport"), buffer.String())
+}
+
+func Test_PrintReport_wrong_answers(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	rs.Counters.WrongAnswer = 3
+	rs.WrongAnswers = map[string]int64{
+		"example.org.": 2,
+		"example.com.": 1,
+	}
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("wrongAnswersReport"), buffer.String())
+}
+
+func Test_PrintReport_retries(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	rs.Counters.Retries = 3
+	rs.Counters.TCPFallback = 2
+	rs.AttemptHist = hdrhistogram.New(0, 0, 1)
+	rs.AttemptHist.RecordValue(5)
+	rs.AttemptHist.RecordValue(5)
+	rs.AttemptHist.RecordValue(10)
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("retriesReport"), buffer.String())
+}
+
+func Test_PrintReport_pipeline(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.Pipeline = 4
+	rs.PipelineDepth = hdrhistogram.New(1, 4, 3)
+	rs.PipelineDepth.RecordValue(1)
+	rs.PipelineDepth.RecordValue(2)
+	rs.PipelineDepth.RecordValue(4)
+	rs.PipelineDepth.RecordValue(4)
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("pipelineReport"), buffer.String())
+}
+
+func Test_PrintReport_phases(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.HistMax = time.Second
+	rs.PhaseHists = testPhaseHists()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("phasesReport"), buffer.String())
+}
+
+func Test_PrintReport_json_phases(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.JSON = true
+	b.HistMax = time.Second
+	rs.PhaseHists = testPhaseHists()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("jsonPhasesReport"), buffer.String())
+}
+
+func Test_PrintReport_handshakes(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.HistMax = time.Second
+	rs.Handshakes = testHandshakes()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("handshakesReport"), buffer.String())
+}
+
+func Test_PrintReport_json_handshakes(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.JSON = true
+	b.HistMax = time.Second
+	rs.Handshakes = testHandshakes()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("jsonHandshakesReport"), buffer.String())
+}
+
+func Test_PrintReport_conns(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.HistMax = time.Second
+	rs.Conns = testConns()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("connsReport"), buffer.String())
+}
+
+func Test_PrintReport_json_conns(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.JSON = true
+	b.HistMax = time.Second
+	rs.Conns = testConns()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("jsonConnsReport"), buffer.String())
+}
+
+func Test_PrintReport_doh_analytics(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	rs.DoH = testDoHStats()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("dohAnalyticsReport"), buffer.String())
+}
+
+func Test_PrintReport_json_doh_analytics(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.JSON = true
+	rs.DoH = testDoHStats()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("jsonDohAnalyticsReport"), buffer.String())
+}
+
+func Test_PrintReport_odoh(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.HistMax = time.Second
+	rs.ODoH = testODoHStats()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("odohReport"), buffer.String())
+}
+
+func Test_PrintReport_json_odoh(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.JSON = true
+	b.HistMax = time.Second
+	rs.ODoH = testODoHStats()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("jsonOdohReport"), buffer.String())
+}
+
+func Test_PrintReport_dso(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.HistMax = time.Second
+	rs.DSO = testDSOStats()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("dsoReport"), buffer.String())
+}
+
+func Test_PrintReport_json_dso(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.JSON = true
+	b.HistMax = time.Second
+	rs.DSO = testDSOStats()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("jsonDsoReport"), buffer.String())
+}
+
+func Test_PrintReport_idle(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.TCPKeepalive = true
+	b.IdleProbe = time.Second
+	rs.Idle = testIdleStats()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("idleReport"), buffer.String())
+}
+
+func Test_PrintReport_json_idle(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.JSON = true
+	b.TCPKeepalive = true
+	b.IdleProbe = time.Second
+	rs.Idle = testIdleStats()
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("jsonIdleReport"), buffer.String())
+}
+
+func Test_PrintReport_sources(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	rs.SourceAddress = "127.0.0.1"
+	rs2 := rs
+	rs2.SourceAddress = "::1"
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs, &rs2}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("sourcesReport"), buffer.String())
+}
+
+func Test_PrintReport_json_sources(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.JSON = true
+	rs.SourceAddress = "127.0.0.1"
+	rs2 := rs
+	rs2.SourceAddress = "::1"
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs, &rs2}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("jsonSourcesReport"), buffer.String())
+}
+
 func Test_PrintReport_doh(t *testing.T) {
 	buffer := bytes.Buffer{}
 	b, rs := testReportData(&buffer)
@@ -92,6 +333,35 @@ func Test_PrintReport_json_doh(t *testing.T) {
 	assert.Equal(t, readResource("jsonDohReport"), buffer.String())
 }
 
+func Test_PrintReport_extended_errors(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	rs.ExtendedErrors = map[dnsbench.ExtendedError]int64{
+		{InfoCode: dns.ExtendedErrorCodeDNSBogus}:                              2,
+		{InfoCode: dns.ExtendedErrorCodeStaleAnswer, ExtraText: "serve-stale"}: 1,
+	}
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("extendedErrorsReport"), buffer.String())
+}
+
+func Test_PrintReport_json_extended_errors(t *testing.T) {
+	buffer := bytes.Buffer{}
+	b, rs := testReportData(&buffer)
+	b.JSON = true
+	b.Rcodes = true
+	b.HistDisplay = true
+	rs.ExtendedErrors = map[dnsbench.ExtendedError]int64{
+		{InfoCode: dns.ExtendedErrorCodeDNSBogus}:                              2,
+		{InfoCode: dns.ExtendedErrorCodeStaleAnswer, ExtraText: "serve-stale"}: 1,
+	}
+
+	err := reporter.PrintReport(&b, []*dnsbench.ResultStats{&rs}, time.Now(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, readResource("jsonExtendedErrorsReport"), buffer.String())
+}
+
 func Test_PrintReport_errors(t *testing.T) {
 	buffer := bytes.Buffer{}
 	b, rs := testReportDataWithServerDNSErrors(&buffer)
@@ -154,6 +424,120 @@ func testReportData(testOutputWriter io.Writer) (dnsbench.Benchmark, dnsbench.Re
 	return b, rs
 }
 
+func testPhaseHists() *dnsbench.PhaseHistograms {
+	hist := func(values ...time.Duration) *hdrhistogram.Histogram {
+		h := hdrhistogram.New(1, int64(time.Second), 3)
+		for _, v := range values {
+			h.RecordValue(v.Nanoseconds())
+		}
+		return h
+	}
+	return &dnsbench.PhaseHistograms{
+		NewConn: map[dnsbench.Phase]*hdrhistogram.Histogram{
+			dnsbench.PhaseConnect:      hist(time.Millisecond, 3*time.Millisecond),
+			dnsbench.PhaseTLSHandshake: hist(4*time.Millisecond, 6*time.Millisecond),
+			dnsbench.PhaseFirstByte:    hist(10*time.Millisecond, 20*time.Millisecond),
+		},
+		ReusedConn: map[dnsbench.Phase]*hdrhistogram.Histogram{
+			dnsbench.PhaseFirstByte: hist(10*time.Millisecond, 10*time.Millisecond, 30*time.Millisecond),
+		},
+	}
+}
+
+func testHandshakes() *dnsbench.HandshakeStats {
+	full := hdrhistogram.New(1, int64(time.Second), 3)
+	full.RecordValue((20 * time.Millisecond).Nanoseconds())
+	resumed := hdrhistogram.New(1, int64(time.Second), 3)
+	resumed.RecordValue((5 * time.Millisecond).Nanoseconds())
+	resumed.RecordValue((5 * time.Millisecond).Nanoseconds())
+	resumed.RecordValue((10 * time.Millisecond).Nanoseconds())
+	return &dnsbench.HandshakeStats{Full: full, Resumed: resumed, ZeroRTT: 2}
+}
+
+func testConns() *dnsbench.ConnStats {
+	first := hdrhistogram.New(1, int64(time.Second), 3)
+	first.RecordValue((30 * time.Millisecond).Nanoseconds())
+	first.RecordValue((40 * time.Millisecond).Nanoseconds())
+	reused := hdrhistogram.New(1, int64(time.Second), 3)
+	reused.RecordValue((10 * time.Millisecond).Nanoseconds())
+	reused.RecordValue((10 * time.Millisecond).Nanoseconds())
+	reused.RecordValue((20 * time.Millisecond).Nanoseconds())
+	return &dnsbench.ConnStats{Opened: 3, Reused: 4, ClosedByServer: 1, ResetOnError: 1, FirstQuery: first, ReusedQuery: reused}
+}
+
+func testDoHStats() *dnsbench.DoHStats {
+	maxAge := hdrhistogram.New(0, 3600, 3)
+	maxAge.RecordValue(300)
+	maxAge.RecordValue(300)
+	age := hdrhistogram.New(0, 3600, 3)
+	age.RecordValue(20)
+	headerSize := hdrhistogram.New(1, 1<<20, 3)
+	headerSize.RecordValue(120)
+	headerSize.RecordValue(120)
+	headerSize.RecordValue(150)
+	streams := hdrhistogram.New(1, 1<<30, 3)
+	streams.RecordValue(1)
+	streams.RecordValue(2)
+	return &dnsbench.DoHStats{
+		Protocols:      map[string]int64{"HTTP/1.1": 1, "HTTP/2.0": 2},
+		Fallback:       1,
+		CacheControl:   2,
+		MaxAge:         maxAge,
+		Cached:         1,
+		Age:            age,
+		HeaderSize:     headerSize,
+		ReusedConn:     1,
+		StreamsPerConn: streams,
+	}
+}
+
+func testODoHStats() *dnsbench.ODoHStats {
+	crypto := hdrhistogram.New(1, int64(time.Second), 3)
+	crypto.RecordValue((100 * time.Microsecond).Nanoseconds())
+	crypto.RecordValue((200 * time.Microsecond).Nanoseconds())
+	proxied := hdrhistogram.New(1, int64(time.Second), 3)
+	proxied.RecordValue((40 * time.Millisecond).Nanoseconds())
+	proxied.RecordValue((60 * time.Millisecond).Nanoseconds())
+	target := hdrhistogram.New(1, int64(time.Second), 3)
+	target.RecordValue((25 * time.Millisecond).Nanoseconds())
+	target.RecordValue((35 * time.Millisecond).Nanoseconds())
+	proxy := hdrhistogram.New(1, int64(time.Second), 3)
+	proxy.RecordValue((15 * time.Millisecond).Nanoseconds())
+	proxy.RecordValue((25 * time.Millisecond).Nanoseconds())
+	return &dnsbench.ODoHStats{Crypto: crypto, Proxied: proxied, Target: target, Proxy: proxy}
+}
+
+func testDSOStats() *dnsbench.DSOStats {
+	setup := hdrhistogram.New(1, int64(time.Second), 3)
+	setup.RecordValue((2 * time.Millisecond).Nanoseconds())
+	setup.RecordValue((4 * time.Millisecond).Nanoseconds())
+	keepalives := hdrhistogram.New(1, int64(time.Second), 3)
+	keepalives.RecordValue((1 * time.Millisecond).Nanoseconds())
+	return &dnsbench.DSOStats{
+		Sessions:          2,
+		Setup:             setup,
+		InactivityTimeout: 10 * time.Second,
+		KeepaliveInterval: dnsbench.DSOInfinite,
+		Keepalives:        keepalives,
+		KeepaliveUpdates:  1,
+		InactivityCloses:  1,
+		Rejected:          1,
+		RetryDelays:       1,
+	}
+}
+
+func testIdleStats() *dnsbench.IdleStats {
+	return &dnsbench.IdleStats{
+		KeepaliveResponses: 3,
+		KeepaliveTimeout:   10 * time.Second,
+		KeepaliveExpired:   1,
+		Probes:             5,
+		ProbesClosed:       2,
+		LongestOpen:        6 * time.Second,
+		ShortestClosed:     7 * time.Second,
+	}
+}
+
 func testReportDataWithServerDNSErrors(testOutputWriter io.Writer) (dnsbench.Benchmark, dnsbench.ResultStats) {
 	b := dnsbench.Benchmark{
 		HistPre: 1,
@@ -0,0 +1,36 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+)
+
+var testSocketSettings = []dnsbench.SocketSettings{
+	{Network: "tcp4", ReceiveBufferSize: 8388608, SendBufferSize: 425984, DSCP: 46, TCPNoDelay: true, TCPFastOpen: true},
+	{Network: "udp6", ReceiveBufferSize: 7340032, SendBufferSize: 7340032, DSCP: 46},
+}
+
+func Test_printSocketSettings(t *testing.T) {
+	buffer := bytes.Buffer{}
+
+	printSocketSettings(&buffer, testSocketSettings)
+
+	assert.Equal(t, "\nSocket settings:\n"+
+		"\t tcp4:\treceive buffer 8388608 B, send buffer 425984 B, DSCP 46, TCP_NODELAY on, TCP Fast Open on\n"+
+		"\t udp6:\treceive buffer 7340032 B, send buffer 7340032 B, DSCP 46\n", buffer.String())
+}
+
+func Test_newSocketSettings(t *testing.T) {
+	res, err := json.Marshal(newSocketSettings(testSocketSettings))
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"network":"tcp4","receiveBufferBytes":8388608,"sendBufferBytes":425984,"dscp":46,"tcpNoDelay":true,"tcpFastOpen":true},
+		{"network":"udp6","receiveBufferBytes":7340032,"sendBufferBytes":7340032,"dscp":46}
+	]`, string(res))
+}
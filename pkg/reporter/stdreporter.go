@@ -34,6 +34,14 @@ func (s *standardReporter) print(params reportParameters) error {
 		}
 	}
 
+	if len(params.extendedErrorsTotals) > 0 {
+		fmt.Fprintln(params.outputWriter)
+		fmt.Fprintln(params.outputWriter, "Extended DNS errors:")
+		for _, e := range sortedExtendedErrors(params.extendedErrorsTotals) {
+			printutils.ErrPrint(params.outputWriter, "\t%s:\t%d\n", extendedErrorString(e), params.extendedErrorsTotals[e])
+		}
+	}
+
 	var dohResponseStatuses []int
 	for key := range params.dohResponseStatusesTotals {
 		dohResponseStatuses = append(dohResponseStatuses, key)
@@ -60,37 +68,42 @@ func (s *standardReporter) print(params reportParameters) error {
 		}
 	}
 
+	if len(params.wrongAnswersTotals) > 0 {
+		var domains []string
+		for k := range params.wrongAnswersTotals {
+			domains = append(domains, k)
+		}
+		sort.Strings(domains)
+
+		fmt.Fprintln(params.outputWriter)
+		fmt.Fprintln(params.outputWriter, "Wrong answers per domain:")
+		for _, d := range domains {
+			printutils.ErrPrint(params.outputWriter, "\t%s:\t%d\n", d, params.wrongAnswersTotals[d])
+		}
+	}
+
 	if params.benchmark.DNSSEC {
 		fmt.Fprintln(params.outputWriter)
 		fmt.Fprintln(params.outputWriter, "Number of domains secured using DNSSEC:", printutils.HighlightStr(len(params.authenticatedDomains)))
 	}
 
+	if params.benchmark.DNSSECValidation {
+		v := params.dnssecValidationTotals
+		fmt.Fprintln(params.outputWriter)
+		fmt.Fprintln(params.outputWriter, "DNSSEC validation results:")
+		printutils.SuccessPrint(params.outputWriter, "\tsecure:\t\t%d\n", v.Secure)
+		fmt.Fprintf(params.outputWriter, "\tinsecure:\t%d\n", v.Insecure)
+		printutils.ErrPrint(params.outputWriter, "\tbogus:\t\t%d\n", v.Bogus)
+		printutils.ErrPrint(params.outputWriter, "\tindeterminate:\t%d\n", v.Indeterminate)
+	}
+
 	fmt.Fprintln(params.outputWriter)
 
 	fmt.Fprintln(params.outputWriter, "Time taken for tests:\t", printutils.HighlightStr(roundDuration(params.benchmarkDuration).String()))
 	fmt.Fprintf(params.outputWriter, "Questions per second:\t %s", printutils.HighlightStr(fmt.Sprintf("%0.1f", float64(params.totalCounters.Total)/params.benchmarkDuration.Seconds())))
 	fmt.Fprintln(params.outputWriter)
-	min := time.Duration(params.hist.Min())
-	mean := time.Duration(params.hist.Mean())
-	sd := time.Duration(params.hist.StdDev())
-	max := time.Duration(params.hist.Max())
-	p99 := time.Duration(params.hist.ValueAtQuantile(99))
-	p95 := time.Duration(params.hist.ValueAtQuantile(95))
-	p90 := time.Duration(params.hist.ValueAtQuantile(90))
-	p75 := time.Duration(params.hist.ValueAtQuantile(75))
-	p50 := time.Duration(params.hist.ValueAtQuantile(50))
-
 	if tc := params.hist.TotalCount(); tc > 0 {
-		fmt.Fprintln(params.outputWriter, "DNS timings,", printutils.HighlightStr(tc), "datapoints")
-		fmt.Fprintln(params.outputWriter, "\t min:\t\t", printutils.HighlightStr(roundDuration(min)))
-		fmt.Fprintln(params.outputWriter, "\t mean:\t\t", printutils.HighlightStr(roundDuration(mean)))
-		fmt.Fprintln(params.outputWriter, "\t [+/-sd]:\t", printutils.HighlightStr(roundDuration(sd)))
-		fmt.Fprintln(params.outputWriter, "\t max:\t\t", printutils.HighlightStr(roundDuration(max)))
-		fmt.Fprintln(params.outputWriter, "\t p99:\t\t", printutils.HighlightStr(roundDuration(p99)))
-		fmt.Fprintln(params.outputWriter, "\t p95:\t\t", printutils.HighlightStr(roundDuration(p95)))
-		fmt.Fprintln(params.outputWriter, "\t p90:\t\t", printutils.HighlightStr(roundDuration(p90)))
-		fmt.Fprintln(params.outputWriter, "\t p75:\t\t", printutils.HighlightStr(roundDuration(p75)))
-		fmt.Fprintln(params.outputWriter, "\t p50:\t\t", printutils.HighlightStr(roundDuration(p50)))
+		printTimings(params.outputWriter, "DNS timings,", params.hist)
 
 		dist := params.hist.Distribution()
 		if params.benchmark.HistDisplay && tc > 1 {
@@ -101,6 +114,55 @@ func (s *standardReporter) print(params reportParameters) error {
 		}
 	}
 
+	if params.attemptHist != nil && params.attemptHist.TotalCount() > 0 {
+		fmt.Fprintln(params.outputWriter)
+		printTimings(params.outputWriter, "DNS attempt timings (including retries and TCP fallbacks),", params.attemptHist)
+	}
+
+	if params.pipelineDepth != nil && params.pipelineDepth.TotalCount() > 0 {
+		fmt.Fprintln(params.outputWriter)
+		fmt.Fprintln(params.outputWriter, "Pipelined queries in-flight per connection:")
+		fmt.Fprintln(params.outputWriter, "\t mean:\t\t", printutils.HighlightStr(fmt.Sprintf("%.2f", params.pipelineDepth.Mean())))
+		fmt.Fprintln(params.outputWriter, "\t max:\t\t", printutils.HighlightStr(params.pipelineDepth.Max()))
+	}
+
+	if params.phaseHists != nil {
+		printPhaseTimings(params.outputWriter, "Request phase timings, new connections:", params.phaseHists.NewConn)
+		printPhaseTimings(params.outputWriter, "Request phase timings, reused connections:", params.phaseHists.ReusedConn)
+	}
+
+	if h := params.handshakes; h != nil && h.Full.TotalCount()+h.Resumed.TotalCount() > 0 {
+		printHandshakes(params.outputWriter, h)
+	}
+
+	if params.conns != nil {
+		printConns(params.outputWriter, params.conns)
+	}
+
+	if params.doh != nil {
+		printDoH(params.outputWriter, params.doh)
+	}
+
+	if o := params.odoh; o != nil && o.Proxied.TotalCount() > 0 {
+		printODoH(params.outputWriter, o)
+	}
+
+	if params.dso != nil {
+		printDSO(params.outputWriter, params.dso)
+	}
+
+	if params.idle != nil {
+		printIdle(params.outputWriter, params.benchmark, params.idle)
+	}
+
+	if len(params.sources) > 0 {
+		printSources(params.outputWriter, params.sources)
+	}
+
+	if len(params.socketSettings) > 0 {
+		printSocketSettings(params.outputWriter, params.socketSettings)
+	}
+
 	sumerrs := 0
 	for _, v := range params.topErrs.m {
 		sumerrs += v
@@ -117,6 +179,233 @@ func (s *standardReporter) print(params reportParameters) error {
 	return nil
 }
 
+func printTimings(w io.Writer, title string, hist *hdrhistogram.Histogram) {
+	fmt.Fprintln(w, title, printutils.HighlightStr(hist.TotalCount()), "datapoints")
+	fmt.Fprintln(w, "\t min:\t\t", printutils.HighlightStr(roundDuration(time.Duration(hist.Min()))))
+	fmt.Fprintln(w, "\t mean:\t\t", printutils.HighlightStr(roundDuration(time.Duration(hist.Mean()))))
+	fmt.Fprintln(w, "\t [+/-sd]:\t", printutils.HighlightStr(roundDuration(time.Duration(hist.StdDev()))))
+	fmt.Fprintln(w, "\t max:\t\t", printutils.HighlightStr(roundDuration(time.Duration(hist.Max()))))
+	fmt.Fprintln(w, "\t p99:\t\t", printutils.HighlightStr(roundDuration(time.Duration(hist.ValueAtQuantile(99)))))
+	fmt.Fprintln(w, "\t p95:\t\t", printutils.HighlightStr(roundDuration(time.Duration(hist.ValueAtQuantile(95)))))
+	fmt.Fprintln(w, "\t p90:\t\t", printutils.HighlightStr(roundDuration(time.Duration(hist.ValueAtQuantile(90)))))
+	fmt.Fprintln(w, "\t p75:\t\t", printutils.HighlightStr(roundDuration(time.Duration(hist.ValueAtQuantile(75)))))
+	fmt.Fprintln(w, "\t p50:\t\t", printutils.HighlightStr(roundDuration(time.Duration(hist.ValueAtQuantile(50)))))
+}
+
+func printPhaseTimings(w io.Writer, title string, hists map[dnsbench.Phase]*hdrhistogram.Histogram) {
+	if len(hists) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, title)
+	for _, p := range dnsbench.Phases {
+		hist, ok := hists[p]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "\t %-16s %s, %s datapoints\n", p.String()+":", latencySummary(hist), printutils.HighlightStr(hist.TotalCount()))
+	}
+}
+
+func printHandshakes(w io.Writer, h *dnsbench.HandshakeStats) {
+	full, resumed := h.Full.TotalCount(), h.Resumed.TotalCount()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "TLS handshakes:")
+	fmt.Fprintf(w, "\t %-16s %s", "full:", printutils.HighlightStr(full))
+	if full > 0 {
+		fmt.Fprintf(w, ", %s", latencySummary(h.Full))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "\t %-16s %s (%s)", "resumed:", printutils.HighlightStr(resumed),
+		printutils.HighlightStr(fmt.Sprintf("%.2f%%", float64(resumed)/float64(full+resumed)*100)))
+	if resumed > 0 {
+		fmt.Fprintf(w, ", %s", latencySummary(h.Resumed))
+	}
+	fmt.Fprintln(w)
+	if h.ZeroRTT > 0 {
+		fmt.Fprintf(w, "\t %-16s %s\n", "0-RTT:", printutils.HighlightStr(h.ZeroRTT))
+	}
+	if full > 0 && resumed > 0 {
+		diff := time.Duration(h.Resumed.Mean() - h.Full.Mean())
+		if diff < 0 {
+			diff = -roundDuration(-diff)
+		} else {
+			diff = roundDuration(diff)
+		}
+		fmt.Fprintf(w, "\t %-16s %s\n", "resumed - full:", printutils.HighlightStr(diff.String()+" (mean)"))
+	}
+}
+
+func printConns(w io.Writer, c *dnsbench.ConnStats) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Connections:")
+	fmt.Fprintf(w, "\t %-18s %s\n", "opened:", printutils.HighlightStr(c.Opened))
+	fmt.Fprintf(w, "\t %-18s %s\n", "reused:", printutils.HighlightStr(fmt.Sprintf("%d queries", c.Reused)))
+	fmt.Fprintf(w, "\t %-18s %s\n", "closed by server:", printutils.HighlightStr(c.ClosedByServer))
+	fmt.Fprintf(w, "\t %-18s %s\n", "reset on error:", printutils.HighlightStr(c.ResetOnError))
+	if c.FirstQuery.TotalCount() > 0 {
+		fmt.Fprintf(w, "\t %-18s %s\n", "first query:", latencySummary(c.FirstQuery))
+	}
+	if c.ReusedQuery.TotalCount() > 0 {
+		fmt.Fprintf(w, "\t %-18s %s\n", "later queries:", latencySummary(c.ReusedQuery))
+	}
+}
+
+func printDoH(w io.Writer, d *dnsbench.DoHStats) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "DoH HTTP analytics:")
+	protos := make([]string, 0, len(d.Protocols))
+	for k := range d.Protocols {
+		protos = append(protos, k)
+	}
+	sort.Strings(protos)
+	for _, p := range protos {
+		fmt.Fprintf(w, "\t %-22s %s\n", p+":", printutils.HighlightStr(fmt.Sprintf("%d responses", d.Protocols[p])))
+	}
+	fmt.Fprintf(w, "\t %-22s %s\n", "protocol fallback:", printutils.HighlightStr(fmt.Sprintf("%d responses", d.Fallback)))
+	fmt.Fprintf(w, "\t %-22s %s\n", "with Cache-Control:", printutils.HighlightStr(fmt.Sprintf("%d responses", d.CacheControl)))
+	if d.MaxAge.TotalCount() > 0 {
+		fmt.Fprintf(w, "\t %-22s %s\n", "max-age (s):", valueSummary(d.MaxAge))
+	}
+	fmt.Fprintf(w, "\t %-22s %s\n", "cached (with Age):", printutils.HighlightStr(fmt.Sprintf("%d responses", d.Cached)))
+	if d.Age.TotalCount() > 0 {
+		fmt.Fprintf(w, "\t %-22s %s\n", "Age (s):", valueSummary(d.Age))
+	}
+	if d.HeaderSize.TotalCount() > 0 {
+		fmt.Fprintf(w, "\t %-22s %s\n", "header size (B):", valueSummary(d.HeaderSize))
+	}
+	fmt.Fprintf(w, "\t %-22s %s\n", "reused connection:", printutils.HighlightStr(fmt.Sprintf("%d requests", d.ReusedConn)))
+	if d.StreamsPerConn.TotalCount() > 0 {
+		fmt.Fprintf(w, "\t %-22s %s\n", "requests per conn:", valueSummary(d.StreamsPerConn))
+	}
+}
+
+func printODoH(w io.Writer, o *dnsbench.ODoHStats) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "ODoH latency breakdown:")
+	fmt.Fprintf(w, "\t %-18s %s\n", "client crypto:", latencySummary(o.Crypto))
+	fmt.Fprintf(w, "\t %-18s %s\n", "via proxy:", latencySummary(o.Proxied))
+	if o.Target.TotalCount() > 0 {
+		fmt.Fprintf(w, "\t %-18s %s\n", "target (direct):", latencySummary(o.Target))
+		fmt.Fprintf(w, "\t %-18s %s\n", "proxy overhead:", latencySummary(o.Proxy))
+	}
+}
+
+func printDSO(w io.Writer, d *dnsbench.DSOStats) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "DSO sessions:")
+	fmt.Fprintf(w, "\t %-20s %s\n", "established:", printutils.HighlightStr(fmt.Sprintf("%d sessions", d.Sessions)))
+	if d.Setup.TotalCount() > 0 {
+		fmt.Fprintf(w, "\t %-20s %s\n", "setup:", latencySummary(d.Setup))
+		fmt.Fprintf(w, "\t %-20s %s\n", "inactivity timeout:", printutils.HighlightStr(dsoTimeout(d.InactivityTimeout)))
+		fmt.Fprintf(w, "\t %-20s %s\n", "keepalive interval:", printutils.HighlightStr(dsoTimeout(d.KeepaliveInterval)))
+	}
+	fmt.Fprintf(w, "\t %-20s %s\n", "keepalives:", printutils.HighlightStr(fmt.Sprintf("%d requests", d.Keepalives.TotalCount())))
+	if d.Keepalives.TotalCount() > 0 {
+		fmt.Fprintf(w, "\t %-20s %s\n", "keepalive RTT:", latencySummary(d.Keepalives))
+	}
+	fmt.Fprintf(w, "\t %-20s %s\n", "keepalive updates:", printutils.HighlightStr(fmt.Sprintf("%d messages", d.KeepaliveUpdates)))
+	fmt.Fprintf(w, "\t %-20s %s\n", "inactivity closes:", printutils.HighlightStr(fmt.Sprintf("%d sessions", d.InactivityCloses)))
+	fmt.Fprintf(w, "\t %-20s %s\n", "rejected:", printutils.HighlightStr(fmt.Sprintf("%d sessions", d.Rejected)))
+	fmt.Fprintf(w, "\t %-20s %s\n", "retry delays:", printutils.HighlightStr(fmt.Sprintf("%d messages", d.RetryDelays)))
+	fmt.Fprintf(w, "\t %-20s %s\n", "malformed:", printutils.HighlightStr(fmt.Sprintf("%d messages", d.Malformed)))
+}
+
+// dsoTimeout returns the DSO timeout negotiated with the server, which may be infinite.
+func dsoTimeout(d time.Duration) string {
+	if d == dnsbench.DSOInfinite {
+		return "infinite"
+	}
+	return d.String()
+}
+
+func printIdle(w io.Writer, b *dnsbench.Benchmark, i *dnsbench.IdleStats) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Connection idle timeout:")
+	if b.TCPKeepalive {
+		fmt.Fprintf(w, "\t %-20s %s\n", "keepalive responses:", printutils.HighlightStr(fmt.Sprintf("%d responses", i.KeepaliveResponses)))
+		if i.KeepaliveResponses > 0 {
+			fmt.Fprintf(w, "\t %-20s %s\n", "advertised timeout:", printutils.HighlightStr(i.KeepaliveTimeout))
+		}
+		fmt.Fprintf(w, "\t %-20s %s\n", "expired connections:", printutils.HighlightStr(fmt.Sprintf("%d connections", i.KeepaliveExpired)))
+	}
+	if b.IdleProbe > 0 {
+		fmt.Fprintf(w, "\t %-20s %s\n", "idle probes:", printutils.HighlightStr(fmt.Sprintf("%d gaps, %d closed by server", i.Probes, i.ProbesClosed)))
+		if observed := observedIdleTimeout(i); len(observed) != 0 {
+			fmt.Fprintf(w, "\t %-20s %s\n", "observed timeout:", printutils.HighlightStr(observed))
+		}
+	}
+}
+
+// observedIdleTimeout returns the range of the idle timeout observed by the idle timeout probing, empty string is returned when nothing was probed.
+func observedIdleTimeout(i *dnsbench.IdleStats) string {
+	switch {
+	case i.ShortestClosed == 0 && i.LongestOpen == 0:
+		return ""
+	case i.ShortestClosed == 0:
+		return fmt.Sprintf("longer than %s", i.LongestOpen)
+	case i.LongestOpen == 0:
+		return fmt.Sprintf("shorter than %s", i.ShortestClosed)
+	default:
+		return fmt.Sprintf("between %s and %s", i.LongestOpen, i.ShortestClosed)
+	}
+}
+
+func printSources(w io.Writer, sources map[string]*SourceStats) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Source addresses:")
+	width := 0
+	for addr := range sources {
+		width = max(width, len(addr)+1)
+	}
+	for _, addr := range sortedSources(sources) {
+		src := sources[addr]
+		fmt.Fprintf(w, "\t %-*s %s requests, %s success, %s errors, %s I/O errors\n", width, addr+":",
+			printutils.HighlightStr(src.Counters.Total), printutils.HighlightStr(src.Counters.Success),
+			printutils.HighlightStr(src.Counters.Error), printutils.HighlightStr(src.Counters.IOError))
+		if src.Hist.TotalCount() > 0 {
+			fmt.Fprintf(w, "\t %-*s %s\n", width, "", latencySummary(src.Hist))
+		}
+	}
+}
+
+func printSocketSettings(w io.Writer, settings []dnsbench.SocketSettings) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Socket settings:")
+	for _, s := range settings {
+		fmt.Fprintf(w, "\t %s:\treceive buffer %s B, send buffer %s B, DSCP %s", s.Network, printutils.HighlightStr(s.ReceiveBufferSize),
+			printutils.HighlightStr(s.SendBufferSize), printutils.HighlightStr(s.DSCP))
+		if strings.HasPrefix(s.Network, dnsbench.TCPTransport) {
+			fmt.Fprintf(w, ", TCP_NODELAY %s, TCP Fast Open %s", printutils.HighlightStr(onOff(s.TCPNoDelay)), printutils.HighlightStr(onOff(s.TCPFastOpen)))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// valueSummary returns short summary of the histogram of values, which are not durations.
+func valueSummary(hist *hdrhistogram.Histogram) string {
+	return fmt.Sprintf("mean %s, p50 %s, max %s",
+		printutils.HighlightStr(fmt.Sprintf("%.2f", hist.Mean())),
+		printutils.HighlightStr(hist.ValueAtQuantile(50)),
+		printutils.HighlightStr(hist.Max()))
+}
+
+// latencySummary returns short summary of the latency histogram.
+func latencySummary(hist *hdrhistogram.Histogram) string {
+	return fmt.Sprintf("mean %s, p50 %s, p99 %s, max %s",
+		printutils.HighlightStr(roundDuration(time.Duration(hist.Mean()))),
+		printutils.HighlightStr(roundDuration(time.Duration(hist.ValueAtQuantile(50)))),
+		printutils.HighlightStr(roundDuration(time.Duration(hist.ValueAtQuantile(99)))),
+		printutils.HighlightStr(roundDuration(time.Duration(hist.Max()))))
+}
+
 func printProgress(w io.Writer, c dnsbench.Counters) {
 	fmt.Fprintf(w, "\nTotal requests:\t\t%s\n", printutils.HighlightStr(c.Total))
 
@@ -128,6 +417,18 @@ func printProgress(w io.Writer, c dnsbench.Counters) {
 		printutils.ErrPrint(w, "ID mismatch errors:\t%d\n", c.IDmismatch)
 	}
 
+	if c.CaseMismatch > 0 {
+		printutils.ErrPrint(w, "Case mismatch errors:\t%d\n", c.CaseMismatch)
+	}
+
+	if c.QuestionMismatch > 0 {
+		printutils.ErrPrint(w, "Question mismatch errors:\t%d\n", c.QuestionMismatch)
+	}
+
+	if c.WrongAnswer > 0 {
+		printutils.ErrPrint(w, "Wrong answers:\t\t%d\n", c.WrongAnswer)
+	}
+
 	if c.Success > 0 {
 		printutils.SuccessPrint(w, "DNS success responses:\t%d\n", c.Success)
 	}
@@ -141,6 +442,22 @@ func printProgress(w io.Writer, c dnsbench.Counters) {
 	if c.Truncated > 0 {
 		printutils.ErrPrint(w, "Truncated responses:\t%d\n", c.Truncated)
 	}
+
+	if c.Late > 0 {
+		printutils.ErrPrint(w, "Late responses:\t\t%d\n", c.Late)
+	}
+
+	if c.Duplicate > 0 {
+		printutils.ErrPrint(w, "Duplicate responses:\t%d\n", c.Duplicate)
+	}
+
+	if c.Retries > 0 {
+		fmt.Fprintf(w, "Retried requests:\t%d\n", c.Retries)
+	}
+
+	if c.TCPFallback > 0 {
+		fmt.Fprintf(w, "TCP fallbacks:\t\t%d\n", c.TCPFallback)
+	}
 }
 
 func printBars(w io.Writer, bars []hdrhistogram.Bar) {